@@ -0,0 +1,408 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rishu/design/hashing"
+)
+
+var (
+	ErrNotFound = errors.New("key not found")
+	ErrNoNodes  = errors.New("cluster has no nodes")
+)
+
+// Client routes cache operations to the nodes that own a key on the
+// consistent hash ring. Every key is stored on the first `replicas` distinct
+// nodes found walking clockwise from the key's position.
+//
+// While a membership change migrates keys, next holds the ring being moved
+// to: writes go to the owners on both rings and reads fall back to the new
+// owners, so Get and Set keep working without waiting for the migration.
+// Deletes in that window leave tombstones, which stop the migration copying
+// back a value it read before the delete.
+type Client struct {
+	ring          *hashing.ConsistentHashing
+	next          *hashing.ConsistentHashing
+	nodes         map[string]hashing.Node
+	replicas      int
+	virtualFactor int
+	http          *http.Client
+	mu            sync.RWMutex
+	// changing serialises AddNode and RemoveNode.
+	changing sync.Mutex
+}
+
+func NewClient(virtualFactor, replicas int) *Client {
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &Client{
+		ring:          hashing.NewConsistentHashing(virtualFactor, hashing.SHA1hash),
+		nodes:         make(map[string]hashing.Node),
+		replicas:      replicas,
+		virtualFactor: virtualFactor,
+		http:          &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (c *Client) Get(key string) ([]byte, error) {
+	c.mu.RLock()
+	owners := c.owners(c.ring, key)
+	if c.next != nil {
+		owners = union(owners, c.owners(c.next, key))
+	}
+	c.mu.RUnlock()
+	if len(owners) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	var lastErr error = ErrNotFound
+	for _, node := range owners {
+		val, err := c.get(node, key)
+		if err == nil {
+			return val, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) Set(key string, val []byte) error {
+	owners, _ := c.writeOwners(key)
+	if len(owners) == 0 {
+		return ErrNoNodes
+	}
+
+	for _, node := range owners {
+		if err := c.put(node, key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) Delete(key string) error {
+	owners, migrating := c.writeOwners(key)
+
+	for _, node := range owners {
+		if err := c.remove(node, key, migrating); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOwners is every node that must see a write to key: its owners, and
+// during a migration its owners on the next ring too. It also reports
+// whether a migration is under way.
+func (c *Client) writeOwners(key string) ([]hashing.Node, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	owners := c.owners(c.ring, key)
+	if c.next != nil {
+		owners = union(owners, c.owners(c.next, key))
+	}
+	return owners, c.next != nil
+}
+
+// AddNode puts a node on the ring and moves onto it only the keys whose
+// replica set changed. It returns how many key copies were transferred.
+func (c *Client) AddNode(node hashing.Node) (int, error) {
+	c.changing.Lock()
+	defer c.changing.Unlock()
+
+	return c.change(c.buildRing(node, ""), func() { c.nodes[node.Id] = node })
+}
+
+// RemoveNode hands the leaving node's key ranges to their new owners before
+// taking it off the ring, so the node must still be reachable.
+func (c *Client) RemoveNode(nodeId string) (int, error) {
+	c.changing.Lock()
+	defer c.changing.Unlock()
+
+	return c.change(c.buildRing(hashing.Node{}, nodeId), func() { delete(c.nodes, nodeId) })
+}
+
+// change migrates to next and then switches to it, calling commit under the
+// lock. c.mu is only held to publish the rings, never across network calls.
+// Tombstones left by the previous change are cleared first, so they cannot
+// block copies of keys that were written again since.
+func (c *Client) change(next *hashing.ConsistentHashing, commit func()) (int, error) {
+	for _, node := range c.nodes {
+		if err := c.clearTombstones(node); err != nil {
+			return 0, err
+		}
+	}
+
+	c.mu.Lock()
+	current := c.ring
+	c.next = next
+	c.mu.Unlock()
+
+	moved, stale, err := c.migrate(current, next)
+
+	c.mu.Lock()
+	if err == nil {
+		commit()
+		c.ring = next
+	}
+	c.next = nil
+	c.mu.Unlock()
+	if err != nil {
+		return moved, err
+	}
+
+	for key, nodes := range stale {
+		for _, node := range nodes {
+			if err := c.remove(node, key, false); err != nil {
+				return moved, err
+			}
+		}
+	}
+	return moved, nil
+}
+
+// Nodes returns the current members sorted by id.
+func (c *Client) Nodes() []hashing.Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]hashing.Node, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		out = append(out, node)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+// Keys lists the keys held by one node.
+func (c *Client) Keys(node hashing.Node) ([]string, error) {
+	resp, err := c.http.Get(node.Address + "/keys")
+	if err != nil {
+		return nil, err
+	}
+	return decodeKeys(resp, node)
+}
+
+// keysIn lists the keys one node holds in the given ranges.
+func (c *Client) keysIn(node hashing.Node, ranges []KeyRange) ([]string, error) {
+	body, err := json.Marshal(ranges)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Post(node.Address+"/keys", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return decodeKeys(resp, node)
+}
+
+func decodeKeys(resp *http.Response, node hashing.Node) ([]string, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list keys on %s: %s", node.Id, resp.Status)
+	}
+	var keys []string
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *Client) buildRing(add hashing.Node, removeId string) *hashing.ConsistentHashing {
+	ids := make([]string, 0, len(c.nodes)+1)
+	for id := range c.nodes {
+		if id != removeId {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	ring := hashing.NewConsistentHashing(c.virtualFactor, hashing.SHA1hash)
+	for _, id := range ids {
+		ring.AddNode(c.nodes[id])
+	}
+	if add.Id != "" {
+		ring.AddNode(add)
+	}
+	return ring
+}
+
+func (c *Client) owners(ring *hashing.ConsistentHashing, key string) []hashing.Node {
+	return ring.GetNodes(key, c.replicas)
+}
+
+// migrate copies the keys whose replica set differs between the two rings
+// to their new owners. Only the arcs of the ring that changed hands are
+// listed, and only on the nodes that owned them. Copies never overwrite a
+// value a concurrent Set already wrote to the new owner, nor a key a
+// concurrent Delete tombstoned there. It returns the
+// copies made and, per key, the nodes that should drop it once next is in
+// use.
+func (c *Client) migrate(oldRing, newRing *hashing.ConsistentHashing) (int, map[string][]hashing.Node, error) {
+	// Nodes only change under c.changing, which the caller holds.
+	changed := changedRanges(oldRing, newRing, c.replicas)
+	holders := make(map[string][]hashing.Node)
+	for id, ranges := range changed {
+		node := c.nodes[id]
+		keys, err := c.keysIn(node, ranges)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, key := range keys {
+			holders[key] = append(holders[key], node)
+		}
+	}
+
+	moved := 0
+	stale := make(map[string][]hashing.Node)
+	for key, have := range holders {
+		after := c.owners(newRing, key)
+		val, err := c.get(have[0], key)
+		if errors.Is(err, ErrNotFound) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			return moved, nil, err
+		}
+		for _, node := range after {
+			if containsNode(have, node) {
+				continue
+			}
+			stored, err := c.putIfAbsent(node, key, val)
+			if err != nil {
+				return moved, nil, err
+			}
+			if stored {
+				moved++
+			}
+		}
+		for _, node := range have {
+			if !containsNode(after, node) {
+				stale[key] = append(stale[key], node)
+			}
+		}
+	}
+	return moved, stale, nil
+}
+
+func (c *Client) get(node hashing.Node, key string) ([]byte, error) {
+	resp, err := c.http.Get(keyURL(node, key))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("get %q from %s: %s", key, node.Id, resp.Status)
+}
+
+func (c *Client) put(node hashing.Node, key string, val []byte) error {
+	req, err := http.NewRequest(http.MethodPut, keyURL(node, key), bytes.NewReader(val))
+	if err != nil {
+		return err
+	}
+	return c.do(req, node, key)
+}
+
+func (c *Client) putIfAbsent(node hashing.Node, key string, val []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPut, keyURL(node, key), bytes.NewReader(val))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("If-None-Match", "*")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusPreconditionFailed:
+		return false, nil
+	}
+	return false, fmt.Errorf("PUT %q on %s: %s", key, node.Id, resp.Status)
+}
+
+func (c *Client) remove(node hashing.Node, key string, tombstone bool) error {
+	req, err := http.NewRequest(http.MethodDelete, keyURL(node, key), nil)
+	if err != nil {
+		return err
+	}
+	if tombstone {
+		req.Header.Set("X-Tombstone", "1")
+	}
+	return c.do(req, node, key)
+}
+
+func (c *Client) clearTombstones(node hashing.Node) error {
+	req, err := http.NewRequest(http.MethodDelete, node.Address+"/tombstones", nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, node, "/tombstones")
+}
+
+func (c *Client) do(req *http.Request, node hashing.Node, key string) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("%s %q on %s: %s", req.Method, key, node.Id, resp.Status)
+	}
+	return nil
+}
+
+func keyURL(node hashing.Node, key string) string {
+	return node.Address + "/cache/" + url.PathEscape(key)
+}
+
+func sameNodes(a, b []hashing.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, node := range a {
+		if !containsNode(b, node) {
+			return false
+		}
+	}
+	return true
+}
+
+func union(a, b []hashing.Node) []hashing.Node {
+	out := append([]hashing.Node(nil), a...)
+	for _, node := range b {
+		if !containsNode(out, node) {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func containsNode(nodes []hashing.Node, node hashing.Node) bool {
+	for _, n := range nodes {
+		if n.Id == node.Id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rishu/design/generic-cache/cache"
+)
+
+// NodeServer serves one cache node over HTTP:
+//
+//	GET    /cache/{key}  value in the body, 404 if missing
+//	PUT    /cache/{key}  store the request body; with If-None-Match: *,
+//	                     412 if the key is present or tombstoned
+//	DELETE /cache/{key}  with X-Tombstone: 1, also leave a tombstone
+//	DELETE /tombstones   drop every tombstone
+//	GET    /keys         JSON array of every key held by the node
+//	POST   /keys         the keys hashing into the JSON array of KeyRange
+//	                     in the body
+//	GET    /health
+//
+// A tombstone records a delete made while keys were migrating, so that a
+// migration copy read before the delete cannot bring the key back. A plain
+// PUT clears it.
+type NodeServer struct {
+	store      *cache.Cache[string, []byte]
+	tombstones map[string]bool
+	// writes makes the conditional PUT's check and store atomic and guards
+	// tombstones.
+	writes sync.Mutex
+}
+
+func NewNodeServer() *NodeServer {
+	return &NodeServer{
+		store:      cache.New[string, []byte](cache.Config[string]{}),
+		tombstones: make(map[string]bool),
+	}
+}
+
+func (s *NodeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/health":
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Path == "/tombstones" && r.Method == http.MethodDelete:
+		s.writes.Lock()
+		s.tombstones = make(map[string]bool)
+		s.writes.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/keys" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.store.Keys())
+	case r.URL.Path == "/keys" && r.Method == http.MethodPost:
+		var ranges []KeyRange
+		if err := json.NewDecoder(r.Body).Decode(&ranges); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keys := []string{}
+		for _, key := range s.store.Keys() {
+			hash := keyHash(key)
+			for _, kr := range ranges {
+				if kr.Contains(hash) {
+					keys = append(keys, key)
+					break
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	case strings.HasPrefix(r.URL.Path, "/cache/"):
+		s.serveKey(w, r, strings.TrimPrefix(r.URL.Path, "/cache/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *NodeServer) serveKey(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		val, found := s.store.Get(key)
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(val)
+	case http.MethodPut:
+		val, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.writes.Lock()
+		if r.Header.Get("If-None-Match") == "*" {
+			if _, found := s.store.Get(key); found || s.tombstones[key] {
+				s.writes.Unlock()
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+		}
+		delete(s.tombstones, key)
+		s.store.Set(key, val)
+		s.writes.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.writes.Lock()
+		s.store.Delete(key)
+		if r.Header.Get("X-Tombstone") == "1" {
+			s.tombstones[key] = true
+		}
+		s.writes.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"sort"
+
+	"github.com/rishu/design/hashing"
+)
+
+// KeyRange is an arc of the hash ring: hashes after From up to and
+// including To, wrapping past zero when From >= To. From == To is the
+// whole ring.
+type KeyRange struct {
+	From uint32 `json:"from"`
+	To   uint32 `json:"to"`
+}
+
+func (r KeyRange) Contains(hash uint32) bool {
+	if r.From < r.To {
+		return hash > r.From && hash <= r.To
+	}
+	return hash > r.From || hash <= r.To
+}
+
+func keyHash(key string) uint32 {
+	return hashing.SHA1hash([]byte(key))
+}
+
+// changedRanges splits the ring at every virtual node of either ring and
+// returns, per node that owned it before, the arcs whose replica set
+// differs between the two rings. Only those arcs hold keys that move.
+func changedRanges(oldRing, newRing *hashing.ConsistentHashing, replicas int) map[string][]KeyRange {
+	seen := make(map[uint32]bool)
+	var points []uint32
+	for _, ring := range []*hashing.ConsistentHashing{oldRing, newRing} {
+		for _, p := range ring.Points() {
+			if !seen[p] {
+				seen[p] = true
+				points = append(points, p)
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	changed := make(map[string][]KeyRange)
+	for i, to := range points {
+		from := points[(i+len(points)-1)%len(points)]
+		// Every hash in (from, to] finds the same next virtual node on
+		// both rings, so the owners at to are the owners of the arc.
+		before := oldRing.GetNodesAt(to, replicas)
+		if sameNodes(before, newRing.GetNodesAt(to, replicas)) {
+			continue
+		}
+		for _, node := range before {
+			changed[node.Id] = append(changed[node.Id], KeyRange{From: from, To: to})
+		}
+	}
+	return changed
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/rishu/design/cache-cluster/cluster"
+	"github.com/rishu/design/hashing"
+)
+
+// Run with -serve to start a single cache node. Without it, the program acts
+// as a local harness: it starts several node processes on localhost ports,
+// drives a cluster client against them and stops them again.
+func main() {
+	serve := flag.String("serve", "", "address for a single node to listen on, e.g. 127.0.0.1:7001")
+	nodes := flag.Int("nodes", 4, "number of node processes the harness starts")
+	replicas := flag.Int("replicas", 2, "replication factor")
+	flag.Parse()
+
+	if *serve != "" {
+		if err := http.ListenAndServe(*serve, cluster.NewNodeServer()); err != nil {
+			fmt.Println("node stopped:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The harness starts with all but one node and then adds the last.
+	if *nodes < 2 {
+		fmt.Println("-nodes must be at least 2")
+		os.Exit(2)
+	}
+	if err := runHarness(*nodes, *replicas); err != nil {
+		fmt.Println("harness failed:", err)
+		os.Exit(1)
+	}
+}
+
+func runHarness(count, replicas int) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var members []hashing.Node
+	for i := 0; i < count; i++ {
+		addr, err := freeAddr()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(self, "-serve", addr)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		defer cmd.Process.Kill()
+
+		node := hashing.Node{Id: fmt.Sprintf("node-%d", i), Address: "http://" + addr}
+		if err := waitHealthy(node.Address); err != nil {
+			return err
+		}
+		members = append(members, node)
+	}
+
+	client := cluster.NewClient(50, replicas)
+	for _, node := range members[:count-1] {
+		if _, err := client.AddNode(node); err != nil {
+			return err
+		}
+	}
+
+	const keys = 1000
+	for i := 0; i < keys; i++ {
+		if err := client.Set(fmt.Sprintf("key-%d", i), []byte(fmt.Sprint(i))); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("stored %d keys on %d nodes with %d replicas\n", keys, count-1, replicas)
+	if err := printDistribution(client); err != nil {
+		return err
+	}
+
+	joining := members[count-1]
+	moved, err := client.AddNode(joining)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s joined, %d key copies moved (%.1f%% of %d)\n", joining.Id, moved, 100*float64(moved)/float64(keys*replicas), keys*replicas)
+	if err := printDistribution(client); err != nil {
+		return err
+	}
+
+	leaving := members[0]
+	moved, err = client.RemoveNode(leaving.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s left, %d key copies moved\n", leaving.Id, moved)
+	if err := printDistribution(client); err != nil {
+		return err
+	}
+
+	missing := 0
+	for i := 0; i < keys; i++ {
+		val, err := client.Get(fmt.Sprintf("key-%d", i))
+		if err != nil || string(val) != fmt.Sprint(i) {
+			missing++
+		}
+	}
+	fmt.Printf("%d of %d keys readable after membership changes\n", keys-missing, keys)
+	return nil
+}
+
+func printDistribution(client *cluster.Client) error {
+	for _, node := range client.Nodes() {
+		keys, err := client.Keys(node)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s: %d keys\n", node.Id, len(keys))
+	}
+	return nil
+}
+
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func waitHealthy(base string) error {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(base + "/health")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("node at %s did not become healthy", base)
+}
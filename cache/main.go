@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -55,6 +57,7 @@ func (c *LRUCache) Put(key, val string) {
 	if c.size == c.cap {
 		delete(c.items, c.tail.Key)
 		c.removeLast()
+		c.size--
 	}
 	c.items[key] = newItem
 	c.addToFront(newItem)
@@ -97,6 +100,8 @@ func (c *LRUCache) removeLast() {
 		c.tail = c.tail.Prev
 		if c.tail != nil {
 			c.tail.Next = nil
+		} else {
+			c.head = nil
 		}
 	}
 }
@@ -115,4 +120,19 @@ func main() {
 	cache.Put("four", "4") // This should evict "one"
 	_, found = cache.Get("one")
 	fmt.Println(found) // Should print "false"
+
+	path := filepath.Join(os.TempDir(), "cache.snapshot")
+	if err := cache.SaveSnapshot(path); err != nil {
+		fmt.Println("snapshot failed:", err)
+		return
+	}
+	restored := NewLRUCache(2)
+	if err := restored.RestoreSnapshot(path); err != nil {
+		fmt.Println("restore failed:", err)
+		return
+	}
+	restored.Put("five", "5") // This should evict "three", the least recent before the restart
+	_, found = restored.Get("three")
+	fmt.Println(found)                // Should print "false"
+	fmt.Println(restored.Get("four")) // Should print "4 true"
 }
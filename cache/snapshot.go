@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rishu/design/snapshot"
+)
+
+// The payload is the entry count followed by each key and value as a
+// length-prefixed string, from least to most recently used.
+const (
+	snapshotMagic   = "LRUC"
+	snapshotVersion = 2
+)
+
+// SaveSnapshot writes the cache contents and recency order to path.
+func (c *LRUCache) SaveSnapshot(path string) error {
+	var w snapshot.Writer
+	c.lock.Lock()
+	w.Varint(int64(c.size))
+	for item := c.tail; item != nil; item = item.Prev {
+		w.String(item.Key)
+		w.String(item.Val)
+	}
+	c.lock.Unlock()
+
+	return snapshot.WriteFile(path, snapshotMagic, snapshotVersion, w.Bytes())
+}
+
+// RestoreSnapshot loads a snapshot written by SaveSnapshot, replaying entries
+// from least to most recently used so the recency order survives a restart.
+// If the snapshot holds more entries than the cache capacity, the most
+// recently used ones are kept.
+func (c *LRUCache) RestoreSnapshot(path string) error {
+	r, err := snapshot.ReadFile(path, snapshotMagic, snapshotVersion)
+	if err != nil {
+		return err
+	}
+	count, err := r.Varint()
+	if err != nil {
+		return err
+	}
+	for i := int64(0); i < count; i++ {
+		key, err := r.String()
+		if err != nil {
+			return err
+		}
+		val, err := r.String()
+		if err != nil {
+			return err
+		}
+		c.Put(key, val)
+	}
+	return nil
+}
+
+// StartSnapshots saves a snapshot to path every interval, reporting failed
+// saves to onError if it is set. The returned stop function halts the
+// background goroutine and writes one final snapshot.
+func (c *LRUCache) StartSnapshots(path string, interval time.Duration, onError func(error)) (stop func() error) {
+	return snapshot.Every(interval, func() error { return c.SaveSnapshot(path) }, onError)
+}
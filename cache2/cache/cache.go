@@ -12,3 +12,19 @@ type EvictionStrategy interface {
 	OnAccess(c Cache, key string)
 	OnRemove(c Cache, key string)
 }
+
+// VictimSelector is implemented by strategies that can name the key Evict
+// would remove without removing it. Admission needs it to compare a
+// candidate with the victim; with a strategy that does not implement it,
+// every candidate is admitted.
+type VictimSelector interface {
+	Victim(c Cache) (string, bool)
+}
+
+// AdmissionPolicy sits in front of an EvictionStrategy. Record is called on
+// every Get; when the cache is full, a candidate is only stored if Admit
+// says it is worth more than the victim the strategy would evict for it.
+type AdmissionPolicy interface {
+	Record(key string)
+	Admit(candidate, victim string) bool
+}
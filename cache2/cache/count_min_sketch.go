@@ -0,0 +1,70 @@
+package caches
+
+import "hash/fnv"
+
+const sketchDepth = 4
+
+// CountMinSketch estimates how often a key was seen using a few rows of
+// small saturating counters. Estimates never undercount; collisions can
+// only make a key look more popular than it is.
+type CountMinSketch struct {
+	width uint64
+	rows  [sketchDepth][]uint8
+	seeds [sketchDepth]uint64
+}
+
+func NewCountMinSketch(width int) *CountMinSketch {
+	w := uint64(1)
+	for w < uint64(width) {
+		w <<= 1
+	}
+	s := &CountMinSketch{
+		width: w,
+		seeds: [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325},
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+func (s *CountMinSketch) index(h uint64, row int) uint64 {
+	h = (h ^ s.seeds[row]) * 0x9e3779b97f4a7c15
+	return (h >> 32) & (s.width - 1)
+}
+
+func (s *CountMinSketch) Increment(key string) {
+	h := hashKey(key)
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+func (s *CountMinSketch) Estimate(key string) int {
+	h := hashKey(key)
+	min := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return int(min)
+}
+
+// Reset halves every counter so that old popularity fades over time.
+func (s *CountMinSketch) Reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,58 @@
+package caches
+
+// Doorkeeper is a bloom filter that absorbs the first occurrence of every
+// key, so one-hit wonders never reach the count-min sketch.
+type Doorkeeper struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+}
+
+func NewDoorkeeper(size int, hashes int) *Doorkeeper {
+	n := uint64(size+63) / 64
+	if n == 0 {
+		n = 1
+	}
+	return &Doorkeeper{
+		bits:   make([]uint64, n),
+		size:   n * 64,
+		hashes: hashes,
+	}
+}
+
+// Add sets the key's bits and reports whether they were all set already.
+func (d *Doorkeeper) Add(key string) bool {
+	h1, h2 := splitHash(key)
+	present := true
+	for i := 0; i < d.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % d.size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if d.bits[word]&mask == 0 {
+			present = false
+			d.bits[word] |= mask
+		}
+	}
+	return present
+}
+
+func (d *Doorkeeper) Contains(key string) bool {
+	h1, h2 := splitHash(key)
+	for i := 0; i < d.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % d.size
+		if d.bits[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Doorkeeper) Reset() {
+	for i := range d.bits {
+		d.bits[i] = 0
+	}
+}
+
+func splitHash(key string) (uint64, uint64) {
+	h := hashKey(key)
+	return h & 0xffffffff, (h >> 32) | 1
+}
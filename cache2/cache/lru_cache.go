@@ -11,45 +11,106 @@ type item struct {
 }
 
 type LRUCache struct {
-	cap      int
-	items    map[string]*list.Element
-	order    *list.List
-	strategy EvictionStrategy
-	mu       sync.Mutex
+	cap       int
+	items     map[string]*list.Element
+	order     *list.List
+	strategy  EvictionStrategy
+	admission AdmissionPolicy
+
+	// With admission (W-TinyLFU), new keys first land in a small LRU
+	// window. The key falling out of the window only enters the main
+	// region, which the strategy manages, if admission prefers it to the
+	// main region's victim.
+	window      *list.List
+	windowItems map[string]*list.Element
+	windowCap   int
+	mu          sync.Mutex
 }
 
 func NewLRUCache(cap int, strategy EvictionStrategy) *LRUCache {
 	return &LRUCache{
-		cap:      cap,
-		items:    make(map[string]*list.Element),
-		order:    list.New(),
-		strategy: strategy,
+		cap:         cap,
+		items:       make(map[string]*list.Element),
+		order:       list.New(),
+		strategy:    strategy,
+		window:      list.New(),
+		windowItems: make(map[string]*list.Element),
+	}
+}
+
+// NewLRUCacheWithAdmission gives 1% of cap, at least one entry, to the
+// admission window and the rest to the main region. A cache of a single
+// entry has no window.
+func NewLRUCacheWithAdmission(cap int, strategy EvictionStrategy, admission AdmissionPolicy) *LRUCache {
+	c := NewLRUCache(cap, strategy)
+	c.admission = admission
+	if cap > 1 {
+		c.windowCap = cap / 100
+		if c.windowCap < 1 {
+			c.windowCap = 1
+		}
+		c.cap = cap - c.windowCap
 	}
+	return c
 }
 
 func (c *LRUCache) Set(key string, val interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if len(c.items) >= c.cap {
-		c.strategy.Evict(c)
+	if elem, found := c.windowItems[key]; found {
+		c.window.MoveToFront(elem)
+		elem.Value.(*item).val = val
+		return
 	}
-
 	if elem, found := c.items[key]; found {
 		c.order.MoveToFront(elem)
 		elem.Value.(*item).val = val
-	} else {
-		elem = c.order.PushFront(&item{key, val})
-		c.items[key] = elem
+		c.strategy.OnAdd(c, key)
+		return
+	}
+
+	if c.windowCap == 0 {
+		c.admitLocked(&item{key, val})
+		return
+	}
+	c.windowItems[key] = c.window.PushFront(&item{key, val})
+	if c.window.Len() > c.windowCap {
+		elem := c.window.Back()
+		c.window.Remove(elem)
+		candidate := elem.Value.(*item)
+		delete(c.windowItems, candidate.key)
+		c.admitLocked(candidate)
 	}
+}
 
-	c.strategy.OnAdd(c, key)
+// admitLocked stores it in the main region, evicting the strategy's victim
+// if the region is full and admission prefers it to the victim.
+func (c *LRUCache) admitLocked(it *item) {
+	if len(c.items) >= c.cap {
+		if selector, ok := c.strategy.(VictimSelector); ok && c.admission != nil {
+			if victim, ok := selector.Victim(c); ok && !c.admission.Admit(it.key, victim) {
+				return
+			}
+		}
+		c.strategy.Evict(c)
+	}
+	c.items[it.key] = c.order.PushFront(it)
+	c.strategy.OnAdd(c, it.key)
 }
 
 func (c *LRUCache) Get(key string) (interface{}, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.admission != nil {
+		c.admission.Record(key)
+	}
+
+	if elem, found := c.windowItems[key]; found {
+		c.window.MoveToFront(elem)
+		return elem.Value.(*item).val, true
+	}
 	if elem, found := c.items[key]; found {
 		c.order.MoveToFront(elem)
 		c.strategy.OnAccess(c, key)
@@ -62,6 +123,16 @@ func (c *LRUCache) Remove(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if elem, found := c.windowItems[key]; found {
+		delete(c.windowItems, key)
+		c.window.Remove(elem)
+		return
+	}
+	c.removeLocked(key)
+}
+
+// removeLocked is used by strategies during Set, which already holds c.mu.
+func (c *LRUCache) removeLocked(key string) {
 	if elem, found := c.items[key]; found {
 		delete(c.items, key)
 		c.order.Remove(elem)
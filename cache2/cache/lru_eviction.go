@@ -4,12 +4,19 @@ package caches
 type LRUEviction struct{}
 
 func (l *LRUEviction) Evict(c Cache) {
+	if key, ok := l.Victim(c); ok {
+		c.(*LRUCache).removeLocked(key)
+	}
+}
+
+func (l *LRUEviction) Victim(c Cache) (string, bool) {
 	if lruCache, ok := c.(*LRUCache); ok {
 		elem := lruCache.order.Back()
 		if elem != nil {
-			lruCache.Remove(elem.Value.(*item).key)
+			return elem.Value.(*item).key, true
 		}
 	}
+	return "", false
 }
 
 func (l *LRUEviction) OnAdd(c Cache, key string) {
@@ -0,0 +1,48 @@
+package caches
+
+// TinyLFU is an AdmissionPolicy that admits a new key only if its estimated
+// access frequency beats the eviction victim's. Frequencies live in a
+// count-min sketch behind a doorkeeper, and both are aged every sampleSize
+// recorded accesses so the history tracks recent popularity.
+type TinyLFU struct {
+	sketch     *CountMinSketch
+	doorkeeper *Doorkeeper
+	samples    int
+	sampleSize int
+}
+
+// NewTinyLFU sizes the sketch for a cache holding roughly capacity entries.
+func NewTinyLFU(capacity int) *TinyLFU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TinyLFU{
+		sketch:     NewCountMinSketch(capacity),
+		doorkeeper: NewDoorkeeper(capacity*8, 4),
+		sampleSize: capacity * 10,
+	}
+}
+
+func (t *TinyLFU) Record(key string) {
+	if t.doorkeeper.Add(key) {
+		t.sketch.Increment(key)
+	}
+	t.samples++
+	if t.samples >= t.sampleSize {
+		t.sketch.Reset()
+		t.doorkeeper.Reset()
+		t.samples = 0
+	}
+}
+
+func (t *TinyLFU) Estimate(key string) int {
+	estimate := t.sketch.Estimate(key)
+	if t.doorkeeper.Contains(key) {
+		estimate++
+	}
+	return estimate
+}
+
+func (t *TinyLFU) Admit(candidate, victim string) bool {
+	return t.Estimate(candidate) > t.Estimate(victim)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	caches "github.com/rishu/design/cache2/cache"
+)
+
+// Replays a trace of keys against the cache with and without W-TinyLFU
+// admission and prints the hit ratio of each. The trace file holds one key
+// per line (extra whitespace-separated columns are ignored); without one, a
+// synthetic Zipf workload interrupted by large one-off scans is used.
+func main() {
+	tracePath := flag.String("trace", "", "path to a trace file with one key per line")
+	capacity := flag.Int("cap", 1000, "cache capacity")
+	flag.Parse()
+
+	var trace []string
+	var err error
+	if *tracePath != "" {
+		trace, err = loadTrace(*tracePath)
+		if err != nil {
+			fmt.Println("failed to load trace:", err)
+			os.Exit(1)
+		}
+	} else {
+		trace = syntheticTrace(200000, 50000)
+	}
+
+	lru := caches.NewLRUCache(*capacity, &caches.LRUEviction{})
+	tiny := caches.NewLRUCacheWithAdmission(*capacity, &caches.LRUEviction{}, caches.NewTinyLFU(*capacity))
+
+	fmt.Printf("requests: %d, capacity: %d\n", len(trace), *capacity)
+	fmt.Printf("LRU           hit ratio: %.2f%%\n", replay(lru, trace)*100)
+	fmt.Printf("W-TinyLFU+LRU hit ratio: %.2f%%\n", replay(tiny, trace)*100)
+}
+
+func replay(c caches.Cache, trace []string) float64 {
+	hits := 0
+	for _, key := range trace {
+		if _, found := c.Get(key); found {
+			hits++
+			continue
+		}
+		c.Set(key, struct{}{})
+	}
+	if len(trace) == 0 {
+		return 0
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func loadTrace(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var trace []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			trace = append(trace, fields[0])
+		}
+	}
+	return trace, scanner.Err()
+}
+
+func syntheticTrace(n, keySpace int) []string {
+	r := rand.New(rand.NewSource(42))
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(keySpace-1))
+	trace := make([]string, 0, n)
+	scan := 0
+	for len(trace) < n {
+		if r.Intn(10000) == 0 {
+			for i := 0; i < 5000 && len(trace) < n; i++ {
+				trace = append(trace, fmt.Sprintf("scan-%d", scan))
+				scan++
+			}
+			continue
+		}
+		trace = append(trace, fmt.Sprintf("key-%d", zipf.Uint64()))
+	}
+	return trace
+}
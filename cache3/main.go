@@ -1,45 +1,78 @@
 package cache3
 
 import (
+	"container/list"
+	"fmt"
 	"sync"
 	"time"
 )
 
 type ICache interface {
-	Insert(key string, value interface{})
+	Insert(key string, value interface{}, ttl time.Duration)
 	Fetch(key string) (interface{}, bool)
 	Remove(key string)
 }
 
 type EvictionPolicy interface {
 	RecordAccess(key string)
+	Forget(key string)
 	Evict(mp map[string]interface{}) string
 }
 
+type EvictReason int
+
+const (
+	EvictedCapacity EvictReason = iota
+	EvictedExpired
+	EvictedRemoved
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	case EvictedRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
 type LRUPolicy struct {
-	order      []string
-	keyToIndex map[string]int
+	order      *list.List
+	keyToIndex map[string]*list.Element
 }
 
 func NewLRUPolicy() *LRUPolicy {
 	return &LRUPolicy{
-		order:      make([]string, 0),
-		keyToIndex: make(map[string]int),
+		order:      list.New(),
+		keyToIndex: make(map[string]*list.Element),
 	}
 }
 
 func (l *LRUPolicy) RecordAccess(key string) {
-	if ind, ok := l.keyToIndex[key]; ok {
-		l.order = append(l.order[:ind], l.order[ind+1:]...)
+	if elem, ok := l.keyToIndex[key]; ok {
+		l.order.MoveToBack(elem)
+		return
+	}
+	l.keyToIndex[key] = l.order.PushBack(key)
+}
+
+func (l *LRUPolicy) Forget(key string) {
+	if elem, ok := l.keyToIndex[key]; ok {
+		l.order.Remove(elem)
+		delete(l.keyToIndex, key)
 	}
-	l.order = append(l.order, key)
-	l.keyToIndex[key] = len(l.order) - 1
 }
 
 func (l *LRUPolicy) Evict(mp map[string]interface{}) string {
-	evictKey := l.order[0]
-	l.order = l.order[1:]
-	delete(l.keyToIndex, evictKey)
+	elem := l.order.Front()
+	if elem == nil {
+		return ""
+	}
+	evictKey := elem.Value.(string)
+	l.Forget(evictKey)
 	return evictKey
 }
 
@@ -59,6 +92,10 @@ func (t *TimeEvictionPolicy) RecordAccess(key string) {
 	t.timestamps[key] = time.Now()
 }
 
+func (t *TimeEvictionPolicy) Forget(key string) {
+	delete(t.timestamps, key)
+}
+
 func (t *TimeEvictionPolicy) Evict(mp map[string]interface{}) string {
 	oldestKey := ""
 	oldestTime := time.Now()
@@ -78,55 +115,217 @@ func (t *TimeEvictionPolicy) Evict(mp map[string]interface{}) string {
 	return oldestKey
 }
 
+type evicted struct {
+	key    string
+	value  interface{}
+	reason EvictReason
+}
+
 type Cache struct {
 	data           map[string]interface{}
+	expiresAt      map[string]time.Time
+	capacity       int
 	evictionPolicy EvictionPolicy
+	onEvict        func(key string, value interface{}, reason EvictReason)
 	mu             sync.Mutex
+
+	// janitorMu serialises StartJanitor and Close; it is never held
+	// together with mu.
+	janitorMu   sync.Mutex
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
 }
 
-func NewCache(evictionPolicy EvictionPolicy) *Cache {
+// NewCache returns a cache holding at most capacity entries. A capacity
+// below one is treated as one.
+func NewCache(capacity int, evictionPolicy EvictionPolicy) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
 	return &Cache{
 		evictionPolicy: evictionPolicy,
 		data:           make(map[string]interface{}),
+		expiresAt:      make(map[string]time.Time),
+		capacity:       capacity,
 	}
 }
 
-func (c *Cache) Insert(key string, val interface{}) {
+// OnEvict registers a hook that is called, outside the cache lock, whenever
+// an entry leaves the cache for any reason other than being overwritten.
+func (c *Cache) OnEvict(fn func(key string, value interface{}, reason EvictReason)) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if _, ok := c.data[key]; ok {
-		if len(c.data) >= 10 {
+	c.onEvict = fn
+}
+
+// Insert stores val under key. A ttl of zero or less means the entry never
+// expires and only leaves the cache through capacity eviction or Remove.
+func (c *Cache) Insert(key string, val interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	var out []evicted
+	if _, ok := c.data[key]; !ok {
+		for len(c.data) >= c.capacity {
 			evictedKey := c.evictionPolicy.Evict(c.data)
-			delete(c.data, evictedKey)
+			if _, found := c.data[evictedKey]; !found {
+				if evictedKey != "" {
+					// The policy still tracked a key that is gone; drop it
+					// and ask again.
+					c.evictionPolicy.Forget(evictedKey)
+					continue
+				}
+				// The policy has nothing left to offer, so any key will do.
+				for evictedKey = range c.data {
+					break
+				}
+			}
+			out = append(out, c.deleteLocked(evictedKey, EvictedCapacity))
 		}
 	}
 	c.data[key] = val
+	if ttl > 0 {
+		c.expiresAt[key] = time.Now().Add(ttl)
+	} else {
+		delete(c.expiresAt, key)
+	}
 	c.evictionPolicy.RecordAccess(key)
+	c.mu.Unlock()
+
+	c.notify(out)
 }
 
 func (c *Cache) Fetch(key string) (interface{}, bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	val, ok := c.data[key]
+	if ok && c.expiredLocked(key, time.Now()) {
+		out := c.deleteLocked(key, EvictedExpired)
+		c.mu.Unlock()
+		c.notify([]evicted{out})
+		return nil, false
+	}
 	if ok {
 		c.evictionPolicy.RecordAccess(key)
 	}
+	c.mu.Unlock()
 	return val, ok
 }
 
 func (c *Cache) Remove(key string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if _, ok := c.data[key]; !ok {
+		c.mu.Unlock()
+		return
+	}
+	out := c.deleteLocked(key, EvictedRemoved)
+	c.mu.Unlock()
+
+	c.notify([]evicted{out})
+}
 
+// StartJanitor launches a goroutine that removes expired entries every
+// interval until Close is called. Calling it again restarts the janitor
+// with the new interval; an interval of zero or less just stops it, leaving
+// expired entries to be dropped when fetched. It is safe to call
+// concurrently with itself and Close.
+func (c *Cache) StartJanitor(interval time.Duration) {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	c.stopJanitorLocked()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	c.stopJanitor = stop
+	c.janitorDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				c.removeExpired(now)
+			}
+		}
+	}()
+}
+
+// Close stops the janitor, if one is running, and waits for it to exit.
+func (c *Cache) Close() {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	c.stopJanitorLocked()
+}
+
+func (c *Cache) stopJanitorLocked() {
+	stop, done := c.stopJanitor, c.janitorDone
+	c.stopJanitor, c.janitorDone = nil, nil
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (c *Cache) removeExpired(now time.Time) {
+	c.mu.Lock()
+	var out []evicted
+	for key := range c.expiresAt {
+		if c.expiredLocked(key, now) {
+			out = append(out, c.deleteLocked(key, EvictedExpired))
+		}
+	}
+	c.mu.Unlock()
+
+	c.notify(out)
+}
+
+func (c *Cache) expiredLocked(key string, now time.Time) bool {
+	exp, ok := c.expiresAt[key]
+	return ok && !now.Before(exp)
+}
+
+func (c *Cache) deleteLocked(key string, reason EvictReason) evicted {
+	val := c.data[key]
 	delete(c.data, key)
+	delete(c.expiresAt, key)
+	c.evictionPolicy.Forget(key)
+	return evicted{key: key, value: val, reason: reason}
+}
+
+func (c *Cache) notify(out []evicted) {
+	if len(out) == 0 {
+		return
+	}
+	c.mu.Lock()
+	fn := c.onEvict
+	c.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for _, e := range out {
+		fn(e.key, e.value, e.reason)
+	}
 }
 
 func main() {
-	lruCache := NewCache(NewLRUPolicy())
-	timeCache := NewCache(NewTimeEvictionPolicy(5 * time.Second))
+	lruCache := NewCache(10, NewLRUPolicy())
+	timeCache := NewCache(10, NewTimeEvictionPolicy(5*time.Second))
+
+	lruCache.Insert("q", 1, 0)
+	timeCache.Insert("a", 2, time.Minute)
 
-	lruCache.Insert("q", 1)
-	timeCache.Insert("a", 2)
-}
\ No newline at end of file
+	timeCache.OnEvict(func(key string, value interface{}, reason EvictReason) {
+		fmt.Println(key, reason)
+	})
+	timeCache.StartJanitor(10 * time.Millisecond)
+	defer timeCache.Close()
+	timeCache.Insert("b", 3, 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond) // janitor expires "b"
+	timeCache.Remove("a")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	circuit_breaker "github.com/rishu/design/circuit-breaker"
+)
+
+// A backend that fails while down is set, called through the breaker.
+func main() {
+	var down atomic.Bool
+	backend := func(ctx context.Context) (*circuit_breaker.Response, error) {
+		if down.Load() {
+			return &circuit_breaker.Response{StatusCode: 503}, errors.New("503 from backend")
+		}
+		return &circuit_breaker.Response{StatusCode: 200}, nil
+	}
+
+	cb := circuit_breaker.NewCircuitBreaker(circuit_breaker.Config{
+		WindowSize:           10,
+		MinimumRequests:      5,
+		FailureRateThreshold: 0.5,
+		OpenTimeout:          100 * time.Millisecond,
+		HalfOpenMaxRequests:  2,
+		OnStateChange: func(from, to circuit_breaker.State) {
+			fmt.Printf("  state %s -> %s\n", from, to)
+		},
+	})
+	ctx := context.Background()
+
+	fmt.Println("backend goes down")
+	down.Store(true)
+	for i := 0; i < 6; i++ {
+		_, err := cb.Execute(ctx, backend)
+		fmt.Println("call:", err)
+	}
+	// expected: five 503s, then closed -> open and "circuit is opened"
+
+	fmt.Println("a cancelled probe neither closes nor reopens the circuit")
+	time.Sleep(150 * time.Millisecond)
+	cancelled := func(ctx context.Context) (*circuit_breaker.Response, error) {
+		return nil, context.Canceled
+	}
+	_, err := cb.Execute(ctx, cancelled)
+	fmt.Println("probe:", err, "state:", cb.State()) // expected: open -> half-open, state half-open
+
+	fmt.Println("a panicking probe counts as a failure")
+	func() {
+		defer func() { fmt.Println("recovered:", recover()) }()
+		cb.Execute(ctx, func(ctx context.Context) (*circuit_breaker.Response, error) {
+			panic("nil map write in handler")
+		})
+	}()
+	fmt.Println("state:", cb.State()) // expected: half-open -> open
+
+	fmt.Println("backend recovers")
+	down.Store(false)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		resp, err := cb.Execute(ctx, backend)
+		if err != nil {
+			fmt.Println("probe:", err)
+			continue
+		}
+		fmt.Println("probe: HTTP", resp.StatusCode)
+	}
+	fmt.Println("state:", cb.State()) // expected: half-open -> closed after two good probes
+}
@@ -1,49 +1,262 @@
 package circuit_breaker
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"time"
 )
 
+var (
+	ErrCircuitOpen     = errors.New("circuit is opened")
+	ErrTooManyRequests = errors.New("too many requests while circuit is half-open")
+)
+
 type Request struct {
-	exp func() (*Response, error)
+	Exp func() (*Response, error)
 }
 
 type Response struct {
-	statusCode int
+	StatusCode int
+}
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type WindowType int
+
+const (
+	CountWindow WindowType = iota
+	TimeWindow
+)
+
+type Config struct {
+	// WindowType picks between the last WindowSize calls (CountWindow) and
+	// the calls made in the last WindowDuration (TimeWindow).
+	WindowType     WindowType
+	WindowSize     int
+	WindowDuration time.Duration
+	// The circuit opens once the window holds at least MinimumRequests calls
+	// and the share of failures reaches FailureRateThreshold (0 to 1).
+	FailureRateThreshold float64
+	MinimumRequests      int
+	// OpenTimeout is how long the circuit stays open before letting
+	// HalfOpenMaxRequests trial calls through.
+	OpenTimeout         time.Duration
+	HalfOpenMaxRequests int
+	// IsFailure decides which errors count against the circuit. By default
+	// every error except context.Canceled does.
+	IsFailure     func(err error) bool
+	OnStateChange func(from, to State)
 }
 
 type CircuitBreaker struct {
-	maxFailuresAllowed int
-	failed             int
-	circuitOpen        bool
-	circuitOpenedAt    time.Time
+	cfg              Config
+	state            State
+	window           window
+	circuitOpenedAt  time.Time
+	halfOpenInFlight int
+	halfOpenSuccess  int
+	generation       uint64
+	transitions      [][2]State
+	now              func() time.Time
+	mu               sync.Mutex
 }
 
-func NewCircuitBreaker(mxFail int) *CircuitBreaker {
-	return &CircuitBreaker{
-		maxFailuresAllowed: mxFail,
+func NewCircuitBreaker(cfg Config) *CircuitBreaker {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 100
+	}
+	if cfg.WindowDuration <= 0 {
+		cfg.WindowDuration = time.Minute
+	}
+	if cfg.FailureRateThreshold <= 0 {
+		cfg.FailureRateThreshold = 0.5
+	}
+	if cfg.MinimumRequests <= 0 {
+		cfg.MinimumRequests = 10
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Minute
+	}
+	if cfg.HalfOpenMaxRequests <= 0 {
+		cfg.HalfOpenMaxRequests = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return !errors.Is(err, context.Canceled) }
+	}
+
+	cb := &CircuitBreaker{
+		cfg: cfg,
+		now: time.Now,
+	}
+	if cfg.WindowType == TimeWindow {
+		cb.window = newTimeWindow(cfg.WindowDuration)
+	} else {
+		cb.window = newCountWindow(cfg.WindowSize)
 	}
+	return cb
+}
+
+func (s *CircuitBreaker) State() State {
+	s.mu.Lock()
+	defer s.unlock()
+
+	s.refreshLocked(s.now())
+	return s.state
 }
 
 func (s *CircuitBreaker) Check(request Request) (*Response, error) {
-	if s.circuitOpen {
-		// check if circuit is opened for more than 10 minutes
-		if time.Now().After(s.circuitOpenedAt.Add(10 * time.Minute)) {
-			s.failed = 0
-			s.circuitOpen = false
-		} else {
-			return nil, errors.New("circuit is opened")
-		}
+	return s.Execute(context.Background(), func(ctx context.Context) (*Response, error) {
+		return request.Exp()
+	})
+}
+
+// Execute runs fn if the circuit lets the call through and records its
+// outcome. A context that is already done is rejected without touching the
+// circuit.
+func (s *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) (*Response, error)) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	result, err := request.exp()
+	generation, err := s.before()
 	if err != nil {
-		s.failed++
-		if s.failed > s.maxFailuresAllowed {
-			s.circuitOpen = true
-			s.circuitOpenedAt = time.Now()
-		}
 		return nil, err
 	}
-	return result, nil
+
+	// A panicking fn counts as a failure; the deferred call makes sure its
+	// half-open slot is released before the panic propagates.
+	o := failure
+	defer func() { s.after(generation, o) }()
+
+	result, err := fn(ctx)
+	switch {
+	case err == nil:
+		o = success
+	case !s.cfg.IsFailure(err):
+		o = ignored
+	}
+	return result, err
+}
+
+type outcome int
+
+const (
+	success outcome = iota
+	failure
+	// ignored is an error IsFailure rejects, such as a cancelled call: it
+	// frees the call's slot without counting either way.
+	ignored
+)
+
+func (s *CircuitBreaker) before() (uint64, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	s.refreshLocked(s.now())
+	switch s.state {
+	case Open:
+		return 0, ErrCircuitOpen
+	case HalfOpen:
+		if s.halfOpenInFlight >= s.cfg.HalfOpenMaxRequests {
+			return 0, ErrTooManyRequests
+		}
+		s.halfOpenInFlight++
+	}
+	return s.generation, nil
+}
+
+// after records a call outcome. Results from a generation that has since
+// ended, e.g. a slow call that started before the circuit opened, are
+// dropped so they cannot flip the new state.
+func (s *CircuitBreaker) after(generation uint64, o outcome) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	now := s.now()
+	s.refreshLocked(now)
+	if generation != s.generation {
+		return
+	}
+
+	switch s.state {
+	case Closed:
+		if o == ignored {
+			return
+		}
+		s.window.record(now, o == success)
+		total, failures := s.window.counts(now)
+		if total >= s.cfg.MinimumRequests && float64(failures)/float64(total) >= s.cfg.FailureRateThreshold {
+			s.setStateLocked(Open, now)
+		}
+	case HalfOpen:
+		s.halfOpenInFlight--
+		switch o {
+		case ignored:
+			return
+		case failure:
+			s.setStateLocked(Open, now)
+			return
+		}
+		s.halfOpenSuccess++
+		if s.halfOpenSuccess >= s.cfg.HalfOpenMaxRequests {
+			s.setStateLocked(Closed, now)
+		}
+	}
+}
+
+func (s *CircuitBreaker) refreshLocked(now time.Time) {
+	if s.state == Open && !now.Before(s.circuitOpenedAt.Add(s.cfg.OpenTimeout)) {
+		s.setStateLocked(HalfOpen, now)
+	}
+}
+
+func (s *CircuitBreaker) setStateLocked(to State, now time.Time) {
+	from := s.state
+	if from == to {
+		return
+	}
+	s.state = to
+	s.generation++
+	s.halfOpenInFlight = 0
+	s.halfOpenSuccess = 0
+	switch to {
+	case Open:
+		s.circuitOpenedAt = now
+	case Closed:
+		s.window.reset()
+	}
+	if s.cfg.OnStateChange != nil {
+		s.transitions = append(s.transitions, [2]State{from, to})
+	}
+}
+
+// unlock releases the lock and then tells the listener about any state
+// changes made while it was held, so listeners may call back into the
+// breaker.
+func (s *CircuitBreaker) unlock() {
+	transitions := s.transitions
+	s.transitions = nil
+	s.mu.Unlock()
+
+	for _, t := range transitions {
+		s.cfg.OnStateChange(t[0], t[1])
+	}
 }
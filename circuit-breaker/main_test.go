@@ -0,0 +1,269 @@
+package circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errBackend = errors.New("backend down")
+
+type fakeClock struct {
+	t  time.Time
+	mu sync.Mutex
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestBreaker(cfg Config) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	cb := NewCircuitBreaker(cfg)
+	cb.now = clock.now
+	return cb, clock
+}
+
+func call(cb *CircuitBreaker, err error) error {
+	_, got := cb.Execute(context.Background(), func(ctx context.Context) (*Response, error) {
+		return nil, err
+	})
+	return got
+}
+
+func TestOpensAtFailureRate(t *testing.T) {
+	cb, _ := newTestBreaker(Config{WindowSize: 10, MinimumRequests: 4, FailureRateThreshold: 0.5})
+
+	call(cb, nil)
+	call(cb, errBackend)
+	call(cb, nil)
+	if cb.State() != Closed {
+		t.Fatalf("opened before MinimumRequests calls")
+	}
+	call(cb, errBackend)
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open at 2/4 failures", cb.State())
+	}
+	if err := call(cb, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCountWindowSlides(t *testing.T) {
+	cb, _ := newTestBreaker(Config{WindowSize: 4, MinimumRequests: 4, FailureRateThreshold: 0.6})
+
+	for _, err := range []error{errBackend, nil, nil, errBackend, errBackend} {
+		call(cb, err)
+	}
+	// Counting every call, 3 of 5 failed and the circuit would open. The
+	// window holds nil, nil, fail, fail: the first failure has slid out.
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed", cb.State())
+	}
+}
+
+func TestTimeWindowForgetsOldFailures(t *testing.T) {
+	cb, clock := newTestBreaker(Config{WindowType: TimeWindow, WindowDuration: 10 * time.Second, MinimumRequests: 3, FailureRateThreshold: 0.5})
+
+	call(cb, errBackend)
+	call(cb, errBackend)
+	clock.advance(11 * time.Second)
+	call(cb, nil)
+	call(cb, nil)
+	call(cb, errBackend)
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed once old failures left the window", cb.State())
+	}
+}
+
+func openBreaker(t *testing.T, halfOpenMax int) (*CircuitBreaker, *fakeClock) {
+	t.Helper()
+	cb, clock := newTestBreaker(Config{WindowSize: 2, MinimumRequests: 1, OpenTimeout: time.Minute, HalfOpenMaxRequests: halfOpenMax})
+	call(cb, errBackend)
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open", cb.State())
+	}
+	clock.advance(time.Minute)
+	if cb.State() != HalfOpen {
+		t.Fatalf("state = %v, want half-open after OpenTimeout", cb.State())
+	}
+	return cb, clock
+}
+
+func TestHalfOpenLimitsProbesAndCloses(t *testing.T) {
+	cb, _ := openBreaker(t, 2)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.Execute(context.Background(), func(ctx context.Context) (*Response, error) {
+				started <- struct{}{}
+				<-release
+				return nil, nil
+			})
+		}()
+	}
+	<-started
+	<-started
+	if err := call(cb, nil); !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("err = %v, want ErrTooManyRequests", err)
+	}
+	close(release)
+	wg.Wait()
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed after successful probes", cb.State())
+	}
+}
+
+func TestHalfOpenFailureReopens(t *testing.T) {
+	cb, _ := openBreaker(t, 1)
+	call(cb, errBackend)
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open", cb.State())
+	}
+}
+
+func TestPanicReleasesHalfOpenSlot(t *testing.T) {
+	cb, clock := openBreaker(t, 1)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		cb.Execute(context.Background(), func(ctx context.Context) (*Response, error) {
+			panic("boom")
+		})
+	}()
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open: a panic is a failure", cb.State())
+	}
+	clock.advance(time.Minute)
+	if err := call(cb, nil); err != nil {
+		t.Fatalf("probe after panic: %v", err)
+	}
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed", cb.State())
+	}
+}
+
+func TestCancelledProbeIsIgnored(t *testing.T) {
+	cb, _ := openBreaker(t, 1)
+
+	call(cb, context.Canceled)
+	if cb.State() != HalfOpen {
+		t.Fatalf("state = %v, want half-open: a cancelled probe proves nothing", cb.State())
+	}
+	if err := call(cb, nil); err != nil {
+		t.Fatalf("slot not released: %v", err)
+	}
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed", cb.State())
+	}
+}
+
+func TestIgnoredErrorsDoNotCountWhenClosed(t *testing.T) {
+	cb, _ := newTestBreaker(Config{WindowSize: 10, MinimumRequests: 2, FailureRateThreshold: 0.5})
+
+	call(cb, errBackend)
+	call(cb, context.Canceled)
+	call(cb, context.Canceled)
+	if cb.State() != Closed {
+		t.Fatalf("state = %v, want closed", cb.State())
+	}
+	call(cb, nil)
+	if cb.State() != Open {
+		t.Fatalf("state = %v, want open at 1/2 counted failures", cb.State())
+	}
+}
+
+func TestStaleResultDropped(t *testing.T) {
+	cb, _ := newTestBreaker(Config{WindowSize: 2, MinimumRequests: 1, OpenTimeout: time.Minute})
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.Execute(context.Background(), func(ctx context.Context) (*Response, error) {
+			<-release
+			return nil, nil
+		})
+	}()
+	call(cb, errBackend)
+	close(release)
+	<-done
+	if cb.State() != Open {
+		t.Fatalf("state = %v, a success from before the circuit opened must not close it", cb.State())
+	}
+}
+
+func TestStateChangeListener(t *testing.T) {
+	var got [][2]State
+	var cb *CircuitBreaker
+	cb, clock := newTestBreaker(Config{WindowSize: 2, MinimumRequests: 1, OpenTimeout: time.Minute, OnStateChange: func(from, to State) {
+		// Listeners run outside the lock and may call back in.
+		_ = cb.State()
+		got = append(got, [2]State{from, to})
+	}})
+	call(cb, errBackend)
+	clock.advance(time.Minute)
+	call(cb, nil)
+
+	want := [][2]State{{Closed, Open}, {Open, HalfOpen}, {HalfOpen, Closed}}
+	if len(got) != len(want) {
+		t.Fatalf("transitions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestExecuteRejectsDoneContext(t *testing.T) {
+	cb, _ := newTestBreaker(Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	_, err := cb.Execute(ctx, func(ctx context.Context) (*Response, error) {
+		ran = true
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) || ran {
+		t.Fatalf("err = %v, ran = %v", err, ran)
+	}
+}
+
+func TestConcurrentExecute(t *testing.T) {
+	cb, _ := newTestBreaker(Config{WindowSize: 50, MinimumRequests: 10, HalfOpenMaxRequests: 3})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				var err error
+				if (i+j)%3 == 0 {
+					err = errBackend
+				}
+				call(cb, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package circuit_breaker
+
+import "time"
+
+// window keeps the recent call outcomes the failure rate is computed over.
+type window interface {
+	record(now time.Time, success bool)
+	counts(now time.Time) (total, failures int)
+	reset()
+}
+
+// countWindow is a ring buffer over the last size calls.
+type countWindow struct {
+	outcomes []bool
+	next     int
+	filled   int
+	failures int
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{outcomes: make([]bool, size)}
+}
+
+func (w *countWindow) record(now time.Time, success bool) {
+	if w.filled == len(w.outcomes) {
+		if !w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.filled++
+	}
+	w.outcomes[w.next] = success
+	if !success {
+		w.failures++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+}
+
+func (w *countWindow) counts(now time.Time) (int, int) {
+	return w.filled, w.failures
+}
+
+func (w *countWindow) reset() {
+	w.next, w.filled, w.failures = 0, 0, 0
+}
+
+const timeWindowBuckets = 10
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+// timeWindow splits its duration into fixed buckets so that old outcomes
+// age out a bucket at a time without storing every call.
+type timeWindow struct {
+	width   time.Duration
+	buckets [timeWindowBuckets]bucket
+}
+
+func newTimeWindow(d time.Duration) *timeWindow {
+	width := d / timeWindowBuckets
+	if width <= 0 {
+		width = time.Nanosecond
+	}
+	return &timeWindow{width: width}
+}
+
+func (w *timeWindow) record(now time.Time, success bool) {
+	start := now.Truncate(w.width)
+	b := &w.buckets[(start.UnixNano()/int64(w.width))%timeWindowBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	b.total++
+	if !success {
+		b.failures++
+	}
+}
+
+func (w *timeWindow) counts(now time.Time) (int, int) {
+	oldest := now.Truncate(w.width).Add(-w.width * (timeWindowBuckets - 1))
+	total, failures := 0, 0
+	for _, b := range w.buckets {
+		if !b.start.Before(oldest) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *timeWindow) reset() {
+	w.buckets = [timeWindowBuckets]bucket{}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"time"
+
+	"github.com/rishu/design/eventbus"
 )
 
 // UserType represents different types of users in the system.
@@ -150,20 +152,32 @@ func (n *CustomerNotifier) Notify(event string) {
 
 // NotificationService manages observers and sends notifications.
 type NotificationService struct {
-	observers map[int]Observer
+	bus       *eventbus.EventBus[string]
+	observers map[int]eventbus.Subscription
+}
+
+func NewNotificationService() *NotificationService {
+	return &NotificationService{
+		bus:       eventbus.NewEventBus[string](eventbus.Options{}),
+		observers: make(map[int]eventbus.Subscription),
+	}
 }
 
+// RegisterObserver replaces any observer already registered for the user.
 func (s *NotificationService) RegisterObserver(userID int, observer Observer) {
-	if s.observers == nil {
-		s.observers = make(map[int]Observer)
+	s.RemoveObserver(userID)
+	s.observers[userID] = s.bus.Subscribe(observer.Notify)
+}
+
+func (s *NotificationService) RemoveObserver(userID int) {
+	if sub, exists := s.observers[userID]; exists {
+		sub.Unsubscribe()
+		delete(s.observers, userID)
 	}
-	s.observers[userID] = observer
 }
 
 func (s *NotificationService) NotifyObservers(event string) {
-	for _, observer := range s.observers {
-		observer.Notify(event)
-	}
+	s.bus.Publish(event)
 }
 
 func main() {
@@ -172,7 +186,7 @@ func main() {
 	restaurantService := &RestaurantService{}
 	orderService := &OrderService{}
 	deliveryService := &DeliveryService{}
-	notificationService := &NotificationService{}
+	notificationService := NewNotificationService()
 
 	// Create users
 	customer := userFactory.CreateUser(1, "John Doe", Customer)
@@ -0,0 +1,211 @@
+// Package eventbus is a typed, in-process publish/subscribe bus for the
+// observer-style demos in this repository.
+package eventbus
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+type Mode int
+
+const (
+	// Sync calls every handler on the publishing goroutine before Publish
+	// returns.
+	Sync Mode = iota
+	// Async gives every subscription its own queue and goroutine; Publish
+	// only enqueues, blocking while a subscriber's queue is full. A handler
+	// that publishes to its own bus can therefore block on its own full
+	// queue, so size QueueSize for that.
+	Async
+)
+
+type Options struct {
+	Mode Mode
+	// QueueSize bounds each subscription's queue in Async mode.
+	QueueSize int
+	// OnPanic is called with whatever a handler panicked with; by default
+	// it is logged. The panic never reaches the publisher or other handlers.
+	OnPanic func(recovered interface{})
+}
+
+// Subscription is the handle returned by Subscribe.
+type Subscription interface {
+	// Unsubscribe stops delivery. No handler call starts after it returns,
+	// though in Async mode one already running may still be finishing.
+	Unsubscribe()
+}
+
+// EventBus delivers every published event to every subscriber. Each
+// subscriber sees the events of one publishing goroutine in the order they
+// were published; events from concurrent publishers may interleave
+// differently for different subscribers. No lock is held while an event is
+// handled or waits for queue space, so handlers may Publish, Subscribe and
+// Unsubscribe.
+type EventBus[T any] struct {
+	opts   Options
+	subs   []*subscription[T]
+	closed bool
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+}
+
+func NewEventBus[T any](opts Options) *EventBus[T] {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 64
+	}
+	if opts.OnPanic == nil {
+		opts.OnPanic = func(recovered interface{}) {
+			log.Printf("eventbus: handler panicked: %v", recovered)
+		}
+	}
+	return &EventBus[T]{opts: opts}
+}
+
+// In Async mode, mu guards closing queue: senders hold it shared and
+// Unsubscribe or Close take it exclusively. done wakes a sender blocked on
+// a full queue when the subscription goes away, so it drops the lock.
+type subscription[T any] struct {
+	bus     *EventBus[T]
+	handler func(T)
+	queue   chan T
+	done    chan struct{}
+	closed  bool
+	stopped atomic.Bool
+	mu      sync.RWMutex
+}
+
+// Subscribe registers handler for every event published from now on.
+func (b *EventBus[T]) Subscribe(handler func(event T)) Subscription {
+	s := &subscription[T]{bus: b, handler: handler}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.stopped.Store(true)
+		return s
+	}
+	if b.opts.Mode == Async {
+		s.queue = make(chan T, b.opts.QueueSize)
+		s.done = make(chan struct{})
+		b.wg.Add(1)
+		go s.run()
+	}
+	b.subs = append(b.subs, s)
+	return s
+}
+
+// Publish hands the event to every current subscriber. It is a no-op once
+// the bus is closed.
+func (b *EventBus[T]) Publish(event T) {
+	b.mu.RLock()
+	subs := b.subs
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed {
+		return
+	}
+	for _, s := range subs {
+		if b.opts.Mode == Async {
+			s.enqueue(event)
+		} else {
+			s.deliver(event)
+		}
+	}
+}
+
+// Len is the number of current subscribers.
+func (b *EventBus[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subs)
+}
+
+// Close stops accepting events and, in Async mode, waits until every
+// subscriber has handled the events already queued for it.
+func (b *EventBus[T]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.closeQueue()
+	}
+	b.wg.Wait()
+}
+
+func (s *subscription[T]) Unsubscribe() {
+	if s.stopped.Swap(true) {
+		return
+	}
+	b := s.bus
+	b.mu.Lock()
+	for i, other := range b.subs {
+		if other == s {
+			// Copy so a Publish iterating the old slice is unaffected.
+			subs := make([]*subscription[T], 0, len(b.subs)-1)
+			subs = append(subs, b.subs[:i]...)
+			b.subs = append(subs, b.subs[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	if s.done != nil {
+		close(s.done)
+	}
+	s.closeQueue()
+}
+
+// enqueue waits for queue space unless the subscription goes away first.
+func (s *subscription[T]) enqueue(event T) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- event:
+	case <-s.done:
+	}
+}
+
+// closeQueue lets run finish once the events already queued are handled.
+func (s *subscription[T]) closeQueue() {
+	if s.queue == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+}
+
+func (s *subscription[T]) run() {
+	defer s.bus.wg.Done()
+	for event := range s.queue {
+		s.deliver(event)
+	}
+}
+
+func (s *subscription[T]) deliver(event T) {
+	if s.stopped.Load() {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.bus.opts.OnPanic(r)
+		}
+	}()
+	s.handler(event)
+}
@@ -0,0 +1,108 @@
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncKeepsPublishOrder(t *testing.T) {
+	bus := NewEventBus[int](Options{Mode: Async, QueueSize: 4})
+	var got []int
+	bus.Subscribe(func(n int) { got = append(got, n) })
+
+	const events = 1000
+	for i := 0; i < events; i++ {
+		bus.Publish(i)
+	}
+	bus.Close()
+
+	if len(got) != events {
+		t.Fatalf("handled %d events, want %d", len(got), events)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("event %d is %d, want in publish order", i, n)
+		}
+	}
+}
+
+func TestUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	bus := NewEventBus[int](Options{Mode: Async, QueueSize: 1})
+	defer bus.Close()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sub := bus.Subscribe(func(n int) {
+		if n == 1 {
+			close(started)
+			<-release
+		}
+	})
+
+	bus.Publish(1) // taken by the handler, which blocks
+	<-started
+	bus.Publish(2) // fills the queue
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(3) // blocks on the full queue
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	sub.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish still blocked after Unsubscribe")
+	}
+	close(release)
+}
+
+func TestCloseDrainsQueuedEvents(t *testing.T) {
+	bus := NewEventBus[int](Options{Mode: Async, QueueSize: 100})
+	var handled atomic.Int64
+	bus.Subscribe(func(int) {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+	})
+
+	for i := 0; i < 50; i++ {
+		bus.Publish(i)
+	}
+	bus.Close()
+
+	if n := handled.Load(); n != 50 {
+		t.Fatalf("handled %d events before Close returned, want 50", n)
+	}
+	bus.Publish(50)
+	if n := handled.Load(); n != 50 {
+		t.Fatalf("event published after Close was handled")
+	}
+}
+
+func TestPanicIsIsolated(t *testing.T) {
+	for _, mode := range []Mode{Sync, Async} {
+		var mu sync.Mutex
+		var panics []interface{}
+		bus := NewEventBus[int](Options{Mode: mode, OnPanic: func(r interface{}) {
+			mu.Lock()
+			panics = append(panics, r)
+			mu.Unlock()
+		}})
+		var handled atomic.Int64
+		bus.Subscribe(func(int) { panic("boom") })
+		bus.Subscribe(func(int) { handled.Add(1) })
+
+		bus.Publish(1)
+		bus.Publish(2)
+		bus.Close()
+
+		if n := handled.Load(); n != 2 {
+			t.Fatalf("mode %d: other handler saw %d events, want 2", mode, n)
+		}
+		if len(panics) != 2 || panics[0] != "boom" {
+			t.Fatalf("mode %d: OnPanic got %v, want two boom", mode, panics)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Cache is a generic, concurrency-safe cache split into lock-striped shards.
+// Each shard owns its own map, lock and eviction policy, so operations on keys
+// that land on different shards never contend with each other.
+type Cache[K comparable, V any] struct {
+	shards []*shard[K, V]
+	hash   func(key K) uint64
+}
+
+// Config describes how a Cache is built. Capacity is the total number of
+// entries across all shards; a zero or negative capacity means unbounded.
+type Config[K comparable] struct {
+	Capacity int
+	Shards   int
+	Policy   PolicyFactory[K]
+	Hasher   func(key K) uint64
+}
+
+func New[K comparable, V any](cfg Config[K]) *Cache[K, V] {
+	if cfg.Shards <= 0 {
+		cfg.Shards = 16
+	}
+	if cfg.Policy == nil {
+		cfg.Policy = LRU[K]()
+	}
+	if cfg.Hasher == nil {
+		cfg.Hasher = defaultHash[K]
+	}
+	if cfg.Capacity > 0 && cfg.Capacity < cfg.Shards {
+		cfg.Shards = cfg.Capacity
+	}
+
+	c := &Cache[K, V]{
+		shards: make([]*shard[K, V], cfg.Shards),
+		hash:   cfg.Hasher,
+	}
+	for i := range c.shards {
+		perShard := 0
+		if cfg.Capacity > 0 {
+			perShard = cfg.Capacity / cfg.Shards
+			if i < cfg.Capacity%cfg.Shards {
+				perShard++
+			}
+		}
+		c.shards[i] = newShard[K, V](perShard, cfg.Policy())
+	}
+	return c
+}
+
+func (c *Cache[K, V]) shardFor(key K) *shard[K, V] {
+	return c.shards[c.hash(key)%uint64(len(c.shards))]
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	return c.shardFor(key).get(key, time.Now())
+}
+
+func (c *Cache[K, V]) Set(key K, val V) {
+	c.shardFor(key).set(key, val)
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.shardFor(key).remove(key)
+}
+
+func (c *Cache[K, V]) Len() int {
+	total := 0
+	for _, s := range c.shards {
+		total += s.len()
+	}
+	return total
+}
+
+// Keys returns a point-in-time copy of every key, shard by shard.
+func (c *Cache[K, V]) Keys() []K {
+	var keys []K
+	for _, s := range c.shards {
+		keys = s.appendKeys(keys)
+	}
+	return keys
+}
+
+func (c *Cache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.clear()
+	}
+}
+
+func defaultHash[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		return h.Sum64()
+	case int:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	}
+	h := fnv.New64a()
+	fmt.Fprint(h, key)
+	return h.Sum64()
+}
+
+// mix spreads sequential integer keys across shards (splitmix64 finalizer).
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
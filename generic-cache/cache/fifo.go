@@ -0,0 +1,47 @@
+package cache
+
+import "container/list"
+
+// FIFO is the policy factory for first-in-first-out eviction.
+func FIFO[K comparable]() PolicyFactory[K] {
+	return func() EvictionPolicy[K] { return NewFIFOPolicy[K]() }
+}
+
+type FIFOPolicy[K comparable] struct {
+	order *list.List
+	items map[K]*list.Element
+}
+
+func NewFIFOPolicy[K comparable]() *FIFOPolicy[K] {
+	return &FIFOPolicy[K]{
+		order: list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (p *FIFOPolicy[K]) OnAdd(key K) {
+	if _, found := p.items[key]; found {
+		return
+	}
+	p.items[key] = p.order.PushBack(key)
+}
+
+func (p *FIFOPolicy[K]) OnAccess(key K) {
+	// No-op for FIFO
+}
+
+func (p *FIFOPolicy[K]) OnRemove(key K) {
+	if elem, found := p.items[key]; found {
+		p.order.Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *FIFOPolicy[K]) Victim() (K, bool) {
+	elem := p.order.Front()
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	return elem.Value.(K), true
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "container/list"
+
+// LRU is the policy factory for least-recently-used eviction.
+func LRU[K comparable]() PolicyFactory[K] {
+	return func() EvictionPolicy[K] { return NewLRUPolicy[K]() }
+}
+
+type LRUPolicy[K comparable] struct {
+	order *list.List
+	items map[K]*list.Element
+}
+
+func NewLRUPolicy[K comparable]() *LRUPolicy[K] {
+	return &LRUPolicy[K]{
+		order: list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (p *LRUPolicy[K]) OnAdd(key K) {
+	if elem, found := p.items[key]; found {
+		p.order.MoveToFront(elem)
+		return
+	}
+	p.items[key] = p.order.PushFront(key)
+}
+
+func (p *LRUPolicy[K]) OnAccess(key K) {
+	if elem, found := p.items[key]; found {
+		p.order.MoveToFront(elem)
+	}
+}
+
+func (p *LRUPolicy[K]) OnRemove(key K) {
+	if elem, found := p.items[key]; found {
+		p.order.Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *LRUPolicy[K]) Victim() (K, bool) {
+	elem := p.order.Back()
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	return elem.Value.(K), true
+}
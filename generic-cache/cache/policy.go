@@ -0,0 +1,21 @@
+package cache
+
+import "time"
+
+// EvictionPolicy decides which key leaves a shard once it is full. A policy
+// instance belongs to a single shard and is only called with the shard lock
+// held, so implementations do not need their own locking.
+type EvictionPolicy[K comparable] interface {
+	OnAdd(key K)
+	OnAccess(key K)
+	OnRemove(key K)
+	Victim() (K, bool)
+}
+
+// Expirer is implemented by policies that invalidate entries over time.
+type Expirer[K comparable] interface {
+	Expired(key K, now time.Time) bool
+}
+
+// PolicyFactory builds a fresh policy for every shard.
+type PolicyFactory[K comparable] func() EvictionPolicy[K]
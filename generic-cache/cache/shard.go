@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type shard[K comparable, V any] struct {
+	cap    int
+	items  map[K]V
+	policy EvictionPolicy[K]
+	mu     sync.Mutex
+}
+
+func newShard[K comparable, V any](cap int, policy EvictionPolicy[K]) *shard[K, V] {
+	return &shard[K, V]{
+		cap:    cap,
+		items:  make(map[K]V),
+		policy: policy,
+	}
+}
+
+func (s *shard[K, V]) get(key K, now time.Time) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, found := s.items[key]
+	if !found {
+		var zero V
+		return zero, false
+	}
+	if exp, ok := s.policy.(Expirer[K]); ok && exp.Expired(key, now) {
+		s.removeLocked(key)
+		var zero V
+		return zero, false
+	}
+	s.policy.OnAccess(key)
+	return val, true
+}
+
+func (s *shard[K, V]) set(key K, val V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.items[key]; !found && s.cap > 0 {
+		for len(s.items) >= s.cap {
+			victim, ok := s.policy.Victim()
+			if !ok {
+				break
+			}
+			s.removeLocked(victim)
+		}
+	}
+	s.items[key] = val
+	s.policy.OnAdd(key)
+}
+
+func (s *shard[K, V]) remove(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(key)
+}
+
+func (s *shard[K, V]) removeLocked(key K) {
+	if _, found := s.items[key]; found {
+		delete(s.items, key)
+		s.policy.OnRemove(key)
+	}
+}
+
+func (s *shard[K, V]) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
+func (s *shard[K, V]) appendKeys(keys []K) []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *shard[K, V]) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.items {
+		s.removeLocked(key)
+	}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+type timeEntry[K comparable] struct {
+	key     K
+	written time.Time
+}
+
+// TimePolicy evicts the entry that was written longest ago and reports
+// entries older than timeout as expired.
+type TimePolicy[K comparable] struct {
+	timeout time.Duration
+	order   *list.List
+	items   map[K]*list.Element
+	now     func() time.Time
+}
+
+func NewTimePolicy[K comparable](timeout time.Duration) *TimePolicy[K] {
+	return &TimePolicy[K]{
+		timeout: timeout,
+		order:   list.New(),
+		items:   make(map[K]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// TimeBased is the policy factory for write-time based expiry and eviction.
+func TimeBased[K comparable](timeout time.Duration) PolicyFactory[K] {
+	return func() EvictionPolicy[K] { return NewTimePolicy[K](timeout) }
+}
+
+func (p *TimePolicy[K]) OnAdd(key K) {
+	if elem, found := p.items[key]; found {
+		elem.Value.(*timeEntry[K]).written = p.now()
+		p.order.MoveToBack(elem)
+		return
+	}
+	p.items[key] = p.order.PushBack(&timeEntry[K]{key: key, written: p.now()})
+}
+
+func (p *TimePolicy[K]) OnAccess(key K) {
+	// Reads do not extend the lifetime of an entry
+}
+
+func (p *TimePolicy[K]) OnRemove(key K) {
+	if elem, found := p.items[key]; found {
+		p.order.Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *TimePolicy[K]) Victim() (K, bool) {
+	elem := p.order.Front()
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	return elem.Value.(*timeEntry[K]).key, true
+}
+
+func (p *TimePolicy[K]) Expired(key K, now time.Time) bool {
+	elem, found := p.items[key]
+	if !found {
+		return false
+	}
+	return now.Sub(elem.Value.(*timeEntry[K]).written) > p.timeout
+}
@@ -0,0 +1,283 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("loader is closed")
+
+// Cache is the subset of a cache the loader needs. cache.Cache from
+// generic-cache and caches.LRUCache from cache2 both satisfy it.
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, val V)
+}
+
+type WriteMode int
+
+const (
+	WriteThrough WriteMode = iota
+	WriteBehind
+)
+
+type Config struct {
+	Mode WriteMode
+	// BatchSize and FlushInterval only apply to WriteBehind: pending writes
+	// are flushed when BatchSize keys are dirty or every FlushInterval.
+	BatchSize     int
+	FlushInterval time.Duration
+	OnWriteError  func(err error)
+}
+
+// Loader keeps a cache populated from a backing store. Misses are read
+// through the store with concurrent misses for one key collapsed into a
+// single Load, and writes go to the store either synchronously or in
+// batches from a background goroutine.
+type Loader[K comparable, V any] struct {
+	cache Cache[K, V]
+	store Store[K, V]
+	cfg   Config
+	loads group[K, V]
+	// fills tracks keys with a read-through load in flight. Set bumps the
+	// key's version so a load that started before the write does not put
+	// the older value it read back into the cache.
+	fills map[K]*fill
+	// writing serialises write-through Sets per key, so the store and the
+	// cache end up holding the same last write.
+	writing map[K]*keyLock
+	pending map[K]V
+	// flushing is the batch being saved, still visible to Get until the
+	// store has it.
+	flushing map[K]V
+	closed   bool
+	flushCh  chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	closing  sync.Once
+	mu       sync.Mutex
+}
+
+type fill struct {
+	loads   int
+	version uint64
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func New[K comparable, V any](cache Cache[K, V], store Store[K, V], cfg Config) *Loader[K, V] {
+	l := &Loader[K, V]{
+		cache:   cache,
+		store:   store,
+		cfg:     cfg,
+		fills:   make(map[K]*fill),
+		writing: make(map[K]*keyLock),
+	}
+	if cfg.Mode == WriteBehind {
+		if l.cfg.BatchSize <= 0 {
+			l.cfg.BatchSize = 100
+		}
+		if l.cfg.FlushInterval <= 0 {
+			l.cfg.FlushInterval = time.Second
+		}
+		l.pending = make(map[K]V)
+		l.flushCh = make(chan struct{}, 1)
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.writeLoop()
+	}
+	return l
+}
+
+// Get returns the cached value, reading it through the store on a miss.
+// The caller's ctx only bounds its own wait: a load shared with other
+// callers keeps going until all of them have given up.
+func (l *Loader[K, V]) Get(ctx context.Context, key K) (V, error) {
+	if val, found := l.cache.Get(key); found {
+		return val, nil
+	}
+	return l.loads.do(ctx, key, func(ctx context.Context) (V, error) {
+		if val, found := l.cache.Get(key); found {
+			return val, nil
+		}
+		f, version := l.startFill(key)
+		defer l.endFill(key, f)
+
+		val, found := l.pendingValue(key)
+		if !found {
+			var err error
+			if val, err = l.store.Load(ctx, key); err != nil {
+				return val, err
+			}
+		}
+		l.mu.Lock()
+		if f.version == version {
+			l.cache.Set(key, val)
+		}
+		l.mu.Unlock()
+		return val, nil
+	})
+}
+
+func (l *Loader[K, V]) startFill(key K) (*fill, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, found := l.fills[key]
+	if !found {
+		f = &fill{}
+		l.fills[key] = f
+	}
+	f.loads++
+	return f, f.version
+}
+
+func (l *Loader[K, V]) endFill(key K, f *fill) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f.loads--
+	if f.loads == 0 {
+		delete(l.fills, key)
+	}
+}
+
+// setLocked caches a written value and invalidates loads still in flight.
+func (l *Loader[K, V]) setLocked(key K, val V) {
+	if f, found := l.fills[key]; found {
+		f.version++
+	}
+	l.cache.Set(key, val)
+}
+
+// Set writes val to the store and the cache. In WriteBehind mode it
+// returns ErrClosed once Close has been called.
+func (l *Loader[K, V]) Set(ctx context.Context, key K, val V) error {
+	if l.cfg.Mode == WriteThrough {
+		unlock := l.lockKey(key)
+		defer unlock()
+		if err := l.store.Save(ctx, key, val); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.setLocked(key, val)
+		l.mu.Unlock()
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	l.setLocked(key, val)
+	l.pending[key] = val
+	full := len(l.pending) >= l.cfg.BatchSize
+	l.mu.Unlock()
+	if full {
+		select {
+		case l.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// lockKey waits until no other write-through Set of key is in progress and
+// returns the function that lets the next one go.
+func (l *Loader[K, V]) lockKey(key K) func() {
+	l.mu.Lock()
+	k, found := l.writing[key]
+	if !found {
+		k = &keyLock{}
+		l.writing[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		l.mu.Lock()
+		k.refs--
+		if k.refs == 0 {
+			delete(l.writing, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Close flushes outstanding write-behind batches and stops the writer.
+func (l *Loader[K, V]) Close() {
+	if l.stop == nil {
+		return
+	}
+	l.closing.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		l.mu.Unlock()
+		close(l.stop)
+	})
+	<-l.done
+}
+
+func (l *Loader[K, V]) pendingValue(key K) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if val, found := l.pending[key]; found {
+		return val, true
+	}
+	val, found := l.flushing[key]
+	return val, found
+}
+
+func (l *Loader[K, V]) writeLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			l.flush()
+			return
+		case <-ticker.C:
+			l.flush()
+		case <-l.flushCh:
+			l.flush()
+		}
+	}
+}
+
+func (l *Loader[K, V]) flush() {
+	l.mu.Lock()
+	if len(l.pending) == 0 {
+		l.mu.Unlock()
+		return
+	}
+	batch := l.pending
+	l.pending = make(map[K]V)
+	l.flushing = batch
+	l.mu.Unlock()
+
+	err := l.store.SaveBatch(context.Background(), batch)
+
+	l.mu.Lock()
+	l.flushing = nil
+	if err != nil {
+		for key, val := range batch {
+			if _, newer := l.pending[key]; !newer {
+				l.pending[key] = val
+			}
+		}
+	}
+	l.mu.Unlock()
+	if err != nil && l.cfg.OnWriteError != nil {
+		l.cfg.OnWriteError(err)
+	}
+}
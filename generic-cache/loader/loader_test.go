@@ -0,0 +1,153 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rishu/design/generic-cache/cache"
+)
+
+func newCache() *cache.Cache[string, int] {
+	return cache.New[string, int](cache.Config[string]{})
+}
+
+// gatedStore holds every Load until release is closed.
+type gatedStore struct {
+	*MemoryStore[string, int]
+	release chan struct{}
+}
+
+func (s *gatedStore) Load(ctx context.Context, key string) (int, error) {
+	<-s.release
+	return s.MemoryStore.Load(ctx, key)
+}
+
+func TestConcurrentMissesLoadOnce(t *testing.T) {
+	store := &gatedStore{MemoryStore: NewMemoryStore[string, int](), release: make(chan struct{})}
+	store.Save(context.Background(), "k", 7)
+	l := New[string, int](newCache(), store, Config{})
+
+	const callers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := l.Get(context.Background(), "k")
+			if err == nil && val != 7 {
+				err = errors.New("wrong value")
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads, _, _ := store.Calls(); loads != 1 {
+		t.Fatalf("%d loads for %d concurrent misses, want 1", loads, callers)
+	}
+}
+
+func TestWriteBehindFlushesFullBatch(t *testing.T) {
+	store := NewMemoryStore[string, int]()
+	l := New[string, int](newCache(), store, Config{Mode: WriteBehind, BatchSize: 3, FlushInterval: time.Hour})
+	defer l.Close()
+
+	ctx := context.Background()
+	for i, key := range []string{"a", "b", "c"} {
+		if err := l.Set(ctx, key, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if _, _, batches := store.Calls(); batches == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("a full batch was not flushed")
+		}
+	}
+	if val, err := store.Load(ctx, "c"); err != nil || val != 2 {
+		t.Fatalf("store has c = %d, %v; want 2", val, err)
+	}
+	if _, saves, _ := store.Calls(); saves != 0 {
+		t.Fatalf("%d single saves in write-behind mode, want 0", saves)
+	}
+}
+
+func TestCloseFlushesPendingWrites(t *testing.T) {
+	store := NewMemoryStore[string, int]()
+	l := New[string, int](newCache(), store, Config{Mode: WriteBehind, BatchSize: 100, FlushInterval: time.Hour})
+
+	ctx := context.Background()
+	l.Set(ctx, "a", 1)
+	l.Set(ctx, "b", 2)
+	l.Close()
+
+	if _, _, batches := store.Calls(); batches != 1 {
+		t.Fatalf("%d batches after Close, want 1", batches)
+	}
+	for key, want := range map[string]int{"a": 1, "b": 2} {
+		if val, err := store.Load(ctx, key); err != nil || val != want {
+			t.Fatalf("store has %s = %d, %v; want %d", key, val, err, want)
+		}
+	}
+	if err := l.Set(ctx, "c", 3); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Set after Close = %v, want ErrClosed", err)
+	}
+}
+
+// stallingStore pauses a Save of stall after storing it, as a slow
+// acknowledgement would.
+type stallingStore struct {
+	*MemoryStore[string, int]
+	stall   int
+	stored  chan struct{}
+	release chan struct{}
+}
+
+func (s *stallingStore) Save(ctx context.Context, key string, val int) error {
+	err := s.MemoryStore.Save(ctx, key, val)
+	if val == s.stall {
+		close(s.stored)
+		<-s.release
+	}
+	return err
+}
+
+func TestWriteThroughSetsOfOneKeyAgree(t *testing.T) {
+	store := &stallingStore{MemoryStore: NewMemoryStore[string, int](), stall: 1, stored: make(chan struct{}), release: make(chan struct{})}
+	c := newCache()
+	l := New[string, int](c, store, Config{})
+	ctx := context.Background()
+
+	first := make(chan error)
+	go func() { first <- l.Set(ctx, "k", 1) }()
+	<-store.stored
+	second := make(chan error)
+	go func() { second <- l.Set(ctx, "k", 2) }()
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := store.Load(ctx, "k")
+	cached, _ := c.Get("k")
+	if stored != 2 || cached != 2 {
+		t.Fatalf("store has %d and cache has %d, want both 2", stored, cached)
+	}
+}
@@ -0,0 +1,75 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// group collapses concurrent calls for the same key into one execution.
+// The shared call runs in its own goroutine with its own context, so each
+// caller can give up through its own ctx without failing the others; the
+// call is cancelled once every caller has given up.
+type group[K comparable, V any] struct {
+	calls map[K]*call[V]
+	mu    sync.Mutex
+}
+
+func (g *group[K, V]) do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	c, found := g.calls[key]
+	if !found {
+		callCtx, cancel := context.WithCancel(context.Background())
+		c = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			g.forgetLocked(key, c)
+		}
+		g.mu.Unlock()
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(ctx context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("loader: load of %v panicked: %v", key, r)
+		}
+		g.mu.Lock()
+		g.forgetLocked(key, c)
+		g.mu.Unlock()
+		c.cancel()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
+
+func (g *group[K, V]) forgetLocked(key K, c *call[V]) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,72 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNotFound = errors.New("key not found in backing store")
+
+// Store is the system of record behind a cache.
+type Store[K comparable, V any] interface {
+	Load(ctx context.Context, key K) (V, error)
+	Save(ctx context.Context, key K, val V) error
+	SaveBatch(ctx context.Context, batch map[K]V) error
+}
+
+// MemoryStore is an in-memory Store that counts its calls, which makes it
+// easy to check how often a loader really reaches the backing store.
+type MemoryStore[K comparable, V any] struct {
+	data    map[K]V
+	loads   int
+	saves   int
+	batches int
+	mu      sync.Mutex
+}
+
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{
+		data: make(map[K]V),
+	}
+}
+
+func (m *MemoryStore[K, V]) Load(ctx context.Context, key K) (V, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.loads++
+	val, found := m.data[key]
+	if !found {
+		return val, ErrNotFound
+	}
+	return val, nil
+}
+
+func (m *MemoryStore[K, V]) Save(ctx context.Context, key K, val V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saves++
+	m.data[key] = val
+	return nil
+}
+
+func (m *MemoryStore[K, V]) SaveBatch(ctx context.Context, batch map[K]V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batches++
+	for key, val := range batch {
+		m.data[key] = val
+	}
+	return nil
+}
+
+// Calls returns how many Load, Save and SaveBatch calls the store has served.
+func (m *MemoryStore[K, V]) Calls() (loads, saves, batches int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.loads, m.saves, m.batches
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	caches "github.com/rishu/design/cache2/cache"
+	"github.com/rishu/design/cache3"
+	"github.com/rishu/design/generic-cache/cache"
+	"github.com/rishu/design/generic-cache/loader"
+)
+
+// cache3Adapter lets cache3.Cache, which stores entries with a TTL, sit
+// behind a loader.
+type cache3Adapter struct {
+	c   *cache3.Cache
+	ttl time.Duration
+}
+
+func (a cache3Adapter) Get(key string) (interface{}, bool) {
+	return a.c.Fetch(key)
+}
+
+func (a cache3Adapter) Set(key string, val interface{}) {
+	a.c.Insert(key, val, a.ttl)
+}
+
+func main() {
+	lru := cache.New[string, int](cache.Config[string]{Capacity: 2, Shards: 1, Policy: cache.LRU[string]()})
+	lru.Set("one", 1)
+	lru.Set("two", 2)
+	fmt.Println(lru.Get("one")) // 1 true
+	lru.Set("three", 3)         // evicts "two"
+	_, found := lru.Get("two")
+	fmt.Println(found) // false
+
+	fifo := cache.New[int, string](cache.Config[int]{Capacity: 2, Shards: 1, Policy: cache.FIFO[int]()})
+	fifo.Set(1, "a")
+	fifo.Set(2, "b")
+	fifo.Get(1)
+	fifo.Set(3, "c") // evicts 1 even though it was just read
+	_, found = fifo.Get(1)
+	fmt.Println(found) // false
+
+	ttl := cache.New[string, string](cache.Config[string]{Capacity: 10, Policy: cache.TimeBased[string](50 * time.Millisecond)})
+	ttl.Set("session", "abc")
+	time.Sleep(100 * time.Millisecond)
+	_, found = ttl.Get("session")
+	fmt.Println(found) // false
+
+	sharded := cache.New[int, int](cache.Config[int]{Capacity: 1000, Shards: 32})
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				sharded.Set(w*1000+i, i)
+				sharded.Get(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	fmt.Println(sharded.Len() <= 1000) // true
+
+	loaderDemo()
+}
+
+func loaderDemo() {
+	ctx := context.Background()
+
+	store := loader.NewMemoryStore[string, int]()
+	store.Save(ctx, "hot", 42)
+	readThrough := loader.New[string, int](cache.New[string, int](cache.Config[string]{Capacity: 100}), store, loader.Config{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readThrough.Get(ctx, "hot")
+		}()
+	}
+	wg.Wait()
+	loads, _, _ := store.Calls()
+	fmt.Println(loads) // 1: concurrent misses share a single load
+
+	lruStore := loader.NewMemoryStore[string, interface{}]()
+	writeThrough := loader.New[string, interface{}](caches.NewLRUCache(10, &caches.LRUEviction{}), lruStore, loader.Config{Mode: loader.WriteThrough})
+	writeThrough.Set(ctx, "user:1", "alice")
+	_, saves, _ := lruStore.Calls()
+	fmt.Println(saves) // 1
+
+	behindStore := loader.NewMemoryStore[string, interface{}]()
+	writeBehind := loader.New[string, interface{}](cache3Adapter{c: cache3.NewCache(100, cache3.NewLRUPolicy()), ttl: time.Minute}, behindStore, loader.Config{
+		Mode:          loader.WriteBehind,
+		BatchSize:     3,
+		FlushInterval: 50 * time.Millisecond,
+	})
+	for i := 0; i < 7; i++ {
+		writeBehind.Set(ctx, fmt.Sprintf("k%d", i), i)
+	}
+	writeBehind.Close()
+	_, saves, batches := behindStore.Calls()
+	fmt.Println(saves, batches > 0)          // 0 true
+	fmt.Println(behindStore.Load(ctx, "k6")) // 6 <nil>
+}
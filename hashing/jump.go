@@ -0,0 +1,63 @@
+package hashing
+
+import "sync"
+
+// JumpHash implements Lamping and Veach's jump consistent hash. It needs no
+// memory beyond the node list and moves the minimum number of keys when
+// nodes are appended, but buckets are positional: removing any node other
+// than the last shifts the nodes after it and remaps their keys too. Adding
+// a node with an id already present replaces it in place. Node weights are
+// ignored.
+type JumpHash struct {
+	nodes []Node
+	mu    sync.RWMutex
+}
+
+func NewJumpHash() *JumpHash {
+	return &JumpHash{}
+}
+
+func (j *JumpHash) AddNode(node Node) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, n := range j.nodes {
+		if n.Id == node.Id {
+			j.nodes[i] = node
+			return
+		}
+	}
+	j.nodes = append(j.nodes, node)
+}
+
+func (j *JumpHash) Remove(nodeId string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, n := range j.nodes {
+		if n.Id == nodeId {
+			j.nodes = append(j.nodes[:i], j.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (j *JumpHash) GetNode(key string) Node {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if len(j.nodes) == 0 {
+		return Node{}
+	}
+	return j.nodes[JumpConsistentHash(hash64([]byte(key)), len(j.nodes))]
+}
+
+func JumpConsistentHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,166 @@
+package hashing
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrTableTooSmall = errors.New("maglev: table has fewer slots than the nodes' total weight")
+
+// Maglev implements Google's Maglev hashing: each node fills a prime-sized
+// lookup table following its own permutation, giving near-perfect balance
+// and O(1) lookups. The table is rebuilt on every membership change.
+type Maglev struct {
+	tableSize uint64
+	nodes     []Node
+	table     []int
+	mu        sync.RWMutex
+}
+
+// NewMaglev builds an empty table. The permutations only visit every slot
+// when the size is prime, so tableSize is rounded up to the next prime; it
+// should be much larger than the expected number of nodes (65537 is a
+// common choice).
+func NewMaglev(tableSize uint64) *Maglev {
+	return &Maglev{tableSize: nextPrime(tableSize)}
+}
+
+func nextPrime(n uint64) uint64 {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := uint64(3); d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+// AddNode is Add for the Placement interface. Rather than fail when the
+// nodes' total weight outgrows the table, it grows the table to the next
+// prime at least twice as large, which remaps most keys.
+func (m *Maglev) AddNode(node Node) {
+	m.add(node, true)
+}
+
+// Add puts the node in the table, or replaces the node with the same id.
+// Every node needs at least one slot per unit of weight, so it returns
+// ErrTableTooSmall if the total weight would exceed the table size.
+func (m *Maglev) Add(node Node) error {
+	return m.add(node, false)
+}
+
+func (m *Maglev) add(node Node, grow bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodes := make([]Node, 0, len(m.nodes)+1)
+	for _, n := range m.nodes {
+		if n.Id != node.Id {
+			nodes = append(nodes, n)
+		}
+	}
+	nodes = append(nodes, node)
+	total := uint64(0)
+	for _, n := range nodes {
+		total += uint64(weightOf(n))
+	}
+	if total > m.tableSize {
+		if !grow {
+			return ErrTableTooSmall
+		}
+		size := 2 * m.tableSize
+		if size < total {
+			size = total
+		}
+		m.tableSize = nextPrime(size)
+	}
+	m.nodes = nodes
+	m.populate()
+	return nil
+}
+
+func weightOf(node Node) int {
+	if node.Weight <= 0 {
+		return 1
+	}
+	return node.Weight
+}
+
+func (m *Maglev) Remove(nodeId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, n := range m.nodes {
+		if n.Id == nodeId {
+			m.nodes = append(m.nodes[:i], m.nodes[i+1:]...)
+			m.populate()
+			return
+		}
+	}
+}
+
+func (m *Maglev) GetNode(key string) Node {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.nodes) == 0 {
+		return Node{}
+	}
+	return m.nodes[m.table[hash64([]byte(key))%m.tableSize]]
+}
+
+// populate fills the table round-robin: each node in turn claims the next
+// free slot in its permutation (offset + j*skip) mod M, taking one turn per
+// unit of weight. Nodes are sorted by id first so the table does not
+// depend on insertion order.
+func (m *Maglev) populate() {
+	sort.Slice(m.nodes, func(i, j int) bool { return m.nodes[i].Id < m.nodes[j].Id })
+
+	n := len(m.nodes)
+	m.table = make([]int, m.tableSize)
+	if n == 0 {
+		return
+	}
+	for i := range m.table {
+		m.table[i] = -1
+	}
+
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, node := range m.nodes {
+		offsets[i] = hash64([]byte("offset-"+node.Id)) % m.tableSize
+		skips[i] = hash64([]byte("skip-"+node.Id))%(m.tableSize-1) + 1
+	}
+
+	filled := uint64(0)
+	for {
+		for i, node := range m.nodes {
+			for turns := weightOf(node); turns > 0; turns-- {
+				c := (offsets[i] + next[i]*skips[i]) % m.tableSize
+				for m.table[c] >= 0 {
+					next[i]++
+					c = (offsets[i] + next[i]*skips[i]) % m.tableSize
+				}
+				m.table[c] = i
+				next[i]++
+				filled++
+				if filled == m.tableSize {
+					return
+				}
+			}
+		}
+	}
+}
@@ -1,16 +1,20 @@
-package main
+package hashing
 
 import (
 	"crypto/sha1"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"sort"
+	"sync"
 )
 
 type Node struct {
 	Id      string
 	Address string
 	Hash    uint32
+	// Weight scales the node's number of virtual nodes; zero counts as 1.
+	Weight int
 }
 
 type ConsistentHashing struct {
@@ -18,6 +22,13 @@ type ConsistentHashing struct {
 	NodeMap       map[uint32]Node
 	VirtualFactor int
 	HashFunc      func(data []byte) uint32
+
+	vnodes      map[string]int
+	weights     map[string]int
+	loads       map[string]int
+	boundedLoad bool
+	epsilon     float64
+	mu          sync.RWMutex
 }
 
 func NewConsistentHashing(v int, hash func(data []byte) uint32) *ConsistentHashing {
@@ -26,17 +37,41 @@ func NewConsistentHashing(v int, hash func(data []byte) uint32) *ConsistentHashi
 		NodeMap:       make(map[uint32]Node),
 		VirtualFactor: v,
 		HashFunc:      hash,
+		vnodes:        make(map[string]int),
+		weights:       make(map[string]int),
+		loads:         make(map[string]int),
 	}
 }
 
+// EnableBoundedLoad switches GetNode to consistent hashing with bounded
+// loads: a node whose load has reached (1+epsilon) times its fair share of
+// the total is skipped and the walk continues clockwise.
+func (ch *ConsistentHashing) EnableBoundedLoad(epsilon float64) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.boundedLoad = true
+	ch.epsilon = epsilon
+}
+
 func (ch *ConsistentHashing) AddNode(node Node) {
-	for i := 0; i < ch.VirtualFactor; i++ {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	weight := node.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	count := ch.VirtualFactor * weight
+	for i := 0; i < count; i++ {
 		id := fmt.Sprintf("%s-%d", node.Id, i)
 		hash := ch.HashFunc([]byte(id))
 
 		ch.Ring = append(ch.Ring, hash)
 		ch.NodeMap[hash] = node
 	}
+	ch.vnodes[node.Id] = count
+	ch.weights[node.Id] = weight
 
 	sort.Slice(ch.Ring, func(i, j int) bool {
 		return ch.Ring[i] < ch.Ring[j]
@@ -44,7 +79,14 @@ func (ch *ConsistentHashing) AddNode(node Node) {
 }
 
 func (ch *ConsistentHashing) Remove(nodeId string) {
-	for i := 0; i < ch.VirtualFactor; i++ {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	count, ok := ch.vnodes[nodeId]
+	if !ok {
+		count = ch.VirtualFactor
+	}
+	for i := 0; i < count; i++ {
 		id := fmt.Sprintf("%s-%d", nodeId, i)
 		hash := ch.HashFunc([]byte(id))
 
@@ -58,15 +100,175 @@ func (ch *ConsistentHashing) Remove(nodeId string) {
 
 		delete(ch.NodeMap, hash)
 	}
+	delete(ch.vnodes, nodeId)
+	delete(ch.weights, nodeId)
+	delete(ch.loads, nodeId)
 }
 
 func (ch *ConsistentHashing) GetNode(key string) Node {
-	hash := ch.HashFunc([]byte(key))
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	return ch.pick(key)
+}
+
+func (ch *ConsistentHashing) pick(key string) Node {
+	if len(ch.Ring) == 0 {
+		return Node{}
+	}
+	idx := ch.search(key)
+	if !ch.boundedLoad {
+		return ch.NodeMap[ch.Ring[idx]]
+	}
+	for i := 0; i < len(ch.Ring); i++ {
+		node := ch.NodeMap[ch.Ring[(idx+i)%len(ch.Ring)]]
+		if ch.loads[node.Id] < ch.capacity(node.Id) {
+			return node
+		}
+	}
+	return ch.NodeMap[ch.Ring[idx]]
+}
+
+// GetNodes returns up to n distinct nodes, walking clockwise from the key.
+// The first one is the node GetNode would return without bounded loads.
+func (ch *ConsistentHashing) GetNodes(key string, n int) []Node {
+	return ch.GetNodesAt(ch.HashFunc([]byte(key)), n)
+}
+
+// GetNodesAt is GetNodes for a position on the ring rather than a key.
+func (ch *ConsistentHashing) GetNodesAt(hash uint32, n int) []Node {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	if len(ch.Ring) == 0 || n <= 0 {
+		return nil
+	}
+	idx := ch.searchHash(hash)
+	var out []Node
+	seen := make(map[string]bool)
+	for i := 0; i < len(ch.Ring) && len(out) < n; i++ {
+		node := ch.NodeMap[ch.Ring[(idx+i)%len(ch.Ring)]]
+		if !seen[node.Id] {
+			seen[node.Id] = true
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// Assign places a key with GetNode and records one unit of load on the
+// chosen node. Release gives that unit back when the work is done.
+func (ch *ConsistentHashing) Assign(key string) Node {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	node := ch.pick(key)
+	if _, ok := ch.vnodes[node.Id]; ok {
+		ch.loads[node.Id]++
+	}
+	return node
+}
+
+func (ch *ConsistentHashing) Release(nodeId string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.loads[nodeId] > 0 {
+		ch.loads[nodeId]--
+	}
+}
+
+func (ch *ConsistentHashing) Loads() map[string]int {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	out := make(map[string]int, len(ch.vnodes))
+	for id := range ch.vnodes {
+		out[id] = ch.loads[id]
+	}
+	return out
+}
+
+// Points returns a copy of the sorted virtual node positions.
+func (ch *ConsistentHashing) Points() []uint32 {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	return append([]uint32(nil), ch.Ring...)
+}
+
+func (ch *ConsistentHashing) search(key string) int {
+	return ch.searchHash(ch.HashFunc([]byte(key)))
+}
+
+func (ch *ConsistentHashing) searchHash(hash uint32) int {
 	idx := sort.Search(len(ch.Ring), func(i int) bool { return ch.Ring[i] >= hash })
 	if idx == len(ch.Ring) {
 		idx = 0 // Wrap around the ring
 	}
-	return ch.NodeMap[ch.Ring[idx]]
+	return idx
+}
+
+// capacity is ceil((1+epsilon) * (total+1) * weight / totalWeight), the load
+// bound for one node if one more key were placed.
+func (ch *ConsistentHashing) capacity(nodeId string) int {
+	total, totalWeight := 0, 0
+	for id, w := range ch.weights {
+		total += ch.loads[id]
+		totalWeight += w
+	}
+	share := float64(total+1) * float64(ch.weights[nodeId]) / float64(totalWeight)
+	return int(math.Ceil((1 + ch.epsilon) * share))
+}
+
+type DistributionStats struct {
+	Counts map[string]int
+	Mean   float64
+	StdDev float64
+	// MaxOverMean is the busiest node's count divided by the mean; 1.0 is a
+	// perfect spread.
+	MaxOverMean float64
+}
+
+// Distribution places every key with GetNode and summarises how evenly they
+// spread across the nodes on the ring.
+func (ch *ConsistentHashing) Distribution(keys []string) DistributionStats {
+	ch.mu.RLock()
+	counts := make(map[string]int, len(ch.vnodes))
+	for id := range ch.vnodes {
+		counts[id] = 0
+	}
+	ch.mu.RUnlock()
+
+	for _, key := range keys {
+		counts[ch.GetNode(key).Id]++
+	}
+	return Summarize(counts)
+}
+
+func Summarize(counts map[string]int) DistributionStats {
+	stats := DistributionStats{Counts: counts}
+	if len(counts) == 0 {
+		return stats
+	}
+	total, max := 0, 0
+	for _, c := range counts {
+		total += c
+		if c > max {
+			max = c
+		}
+	}
+	stats.Mean = float64(total) / float64(len(counts))
+	variance := 0.0
+	for _, c := range counts {
+		d := float64(c) - stats.Mean
+		variance += d * d
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(counts)))
+	if stats.Mean > 0 {
+		stats.MaxOverMean = float64(max) / stats.Mean
+	}
+	return stats
 }
 
 func SHA1hash(data []byte) uint32 {
@@ -0,0 +1,31 @@
+package hashing
+
+import "hash/fnv"
+
+// Placement maps keys onto a changing set of nodes.
+type Placement interface {
+	AddNode(node Node)
+	Remove(nodeId string)
+	GetNode(key string) Node
+}
+
+var (
+	_ Placement = (*ConsistentHashing)(nil)
+	_ Placement = (*Rendezvous)(nil)
+	_ Placement = (*JumpHash)(nil)
+	_ Placement = (*Maglev)(nil)
+)
+
+// hash64 is FNV-1a followed by a splitmix64 finalizer, so short keys that
+// differ only in their last byte still land far apart.
+func hash64(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,68 @@
+package hashing
+
+import (
+	"math"
+	"sync"
+)
+
+// Rendezvous implements highest-random-weight hashing: every node scores
+// the key and the highest score wins. Weighted nodes use the logarithmic
+// method, score = -weight / ln(h), so shares stay proportional to weight.
+type Rendezvous struct {
+	nodes []Node
+	mu    sync.RWMutex
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+func (r *Rendezvous) AddNode(node Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, n := range r.nodes {
+		if n.Id == node.Id {
+			r.nodes[i] = node
+			return
+		}
+	}
+	r.nodes = append(r.nodes, node)
+}
+
+func (r *Rendezvous) Remove(nodeId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, n := range r.nodes {
+		if n.Id == nodeId {
+			r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *Rendezvous) GetNode(key string) Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best Node
+	bestScore := math.Inf(-1)
+	for _, node := range r.nodes {
+		if score := r.score(node, key); score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+func (r *Rendezvous) score(node Node, key string) float64 {
+	weight := node.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	h := hash64([]byte(node.Id + "\x00" + key))
+	// Map the hash into (0, 1) so the logarithm is finite and negative.
+	u := (float64(h>>11) + 0.5) / float64(uint64(1)<<53)
+	return -float64(weight) / math.Log(u)
+}
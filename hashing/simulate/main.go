@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/rishu/design/hashing"
+)
+
+// Compares placement algorithms: for each one it places a set of keys,
+// reports how evenly they spread, then adds and removes a node and reports
+// what share of keys changed owner. The ideal remap after adding the
+// (n+1)th node is 1/(n+1) of the keys, and after removing one of n nodes it
+// is 1/n.
+func main() {
+	nodes := flag.Int("nodes", 10, "initial number of nodes")
+	keys := flag.Int("keys", 100000, "number of keys to place")
+	vnodes := flag.Int("vnodes", 100, "virtual nodes per ring member")
+	flag.Parse()
+
+	algorithms := []struct {
+		name string
+		make func() hashing.Placement
+	}{
+		{"ring", func() hashing.Placement { return hashing.NewConsistentHashing(*vnodes, hashing.SHA1hash) }},
+		{"rendezvous", func() hashing.Placement { return hashing.NewRendezvous() }},
+		{"jump", func() hashing.Placement { return hashing.NewJumpHash() }},
+		{"maglev", func() hashing.Placement { return hashing.NewMaglev(65537) }},
+	}
+
+	keyset := make([]string, *keys)
+	for i := range keyset {
+		keyset[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	fmt.Printf("%d keys, %d nodes (ideal add remap %.2f%%, ideal remove remap %.2f%%)\n",
+		*keys, *nodes, 100/float64(*nodes+1), 100/float64(*nodes))
+	fmt.Printf("%-11s %10s %10s %12s %12s %12s\n", "algorithm", "stddev", "max/mean", "add remap", "rm last", "rm first")
+	for _, alg := range algorithms {
+		p := alg.make()
+		for i := 0; i < *nodes; i++ {
+			p.AddNode(hashing.Node{Id: fmt.Sprintf("node-%d", i)})
+		}
+		before := assign(p, keyset)
+		stats := hashing.Summarize(count(before))
+
+		p.AddNode(hashing.Node{Id: fmt.Sprintf("node-%d", *nodes)})
+		added := assign(p, keyset)
+		p.Remove(fmt.Sprintf("node-%d", *nodes))
+
+		p.Remove(fmt.Sprintf("node-%d", *nodes-1))
+		removedLast := assign(p, keyset)
+		p.AddNode(hashing.Node{Id: fmt.Sprintf("node-%d", *nodes-1)})
+
+		p.Remove("node-0")
+		removedFirst := assign(p, keyset)
+
+		fmt.Printf("%-11s %9.2f%% %10.3f %11.2f%% %11.2f%% %11.2f%%\n",
+			alg.name,
+			100*stats.StdDev/stats.Mean,
+			stats.MaxOverMean,
+			remapped(before, added),
+			remapped(before, removedLast),
+			remapped(before, removedFirst))
+	}
+}
+
+func assign(p hashing.Placement, keys []string) []string {
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		owners[i] = p.GetNode(key).Id
+	}
+	return owners
+}
+
+func count(owners []string) map[string]int {
+	counts := make(map[string]int)
+	for _, id := range owners {
+		counts[id]++
+	}
+	return counts
+}
+
+func remapped(before, after []string) float64 {
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			moved++
+		}
+	}
+	return 100 * float64(moved) / float64(len(before))
+}
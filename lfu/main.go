@@ -1 +1,193 @@
 package main
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Entry struct {
+	Key   int
+	Value int
+	Freq  int
+}
+
+type Stats struct {
+	Hits      int
+	Misses    int
+	Evictions int
+}
+
+// LFUCache evicts the least frequently used key, breaking ties inside a
+// frequency by recency. Every frequency has its own list so Get and Put are
+// O(1). When decayInterval is set, all frequencies are halved once per
+// interval so keys that were hot a long time ago can eventually be evicted.
+type LFUCache struct {
+	cap           int
+	cache         map[int]*list.Element
+	buckets       map[int]*list.List
+	minFreq       int
+	decayInterval time.Duration
+	lastDecay     time.Time
+	stats         Stats
+	mu            sync.Mutex
+}
+
+func NewLFUCache(cap int) *LFUCache {
+	return &LFUCache{
+		cap:     cap,
+		cache:   make(map[int]*list.Element),
+		buckets: make(map[int]*list.List),
+	}
+}
+
+func NewLFUCacheWithDecay(cap int, decayInterval time.Duration) *LFUCache {
+	l := NewLFUCache(cap)
+	l.decayInterval = decayInterval
+	l.lastDecay = time.Now()
+	return l
+}
+
+func (l *LFUCache) Get(key int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.maybeDecay()
+	ele, found := l.cache[key]
+	if !found {
+		l.stats.Misses++
+		return -1
+	}
+	l.stats.Hits++
+	l.touch(ele)
+	return ele.Value.(*Entry).Value
+}
+
+func (l *LFUCache) Put(key, val int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cap <= 0 {
+		return
+	}
+	l.maybeDecay()
+	if ele, found := l.cache[key]; found {
+		ele.Value.(*Entry).Value = val
+		l.touch(ele)
+		return
+	}
+	if len(l.cache) == l.cap {
+		l.evict()
+	}
+	l.cache[key] = l.bucket(1).PushFront(&Entry{Key: key, Value: val, Freq: 1})
+	l.minFreq = 1
+}
+
+func (l *LFUCache) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+func (l *LFUCache) bucket(freq int) *list.List {
+	b, found := l.buckets[freq]
+	if !found {
+		b = list.New()
+		l.buckets[freq] = b
+	}
+	return b
+}
+
+// touch moves an entry from its frequency bucket into the next one.
+func (l *LFUCache) touch(ele *list.Element) {
+	entry := ele.Value.(*Entry)
+	old := l.buckets[entry.Freq]
+	old.Remove(ele)
+	if old.Len() == 0 {
+		delete(l.buckets, entry.Freq)
+		if l.minFreq == entry.Freq {
+			l.minFreq++
+		}
+	}
+	entry.Freq++
+	l.cache[entry.Key] = l.bucket(entry.Freq).PushFront(entry)
+}
+
+func (l *LFUCache) evict() {
+	b := l.buckets[l.minFreq]
+	if b == nil {
+		return
+	}
+	tail := b.Back()
+	b.Remove(tail)
+	if b.Len() == 0 {
+		delete(l.buckets, l.minFreq)
+	}
+	delete(l.cache, tail.Value.(*Entry).Key)
+	l.stats.Evictions++
+}
+
+// maybeDecay halves every frequency once decayInterval has passed. Buckets
+// are visited from low to high frequency so that, where two buckets merge,
+// entries that used to be hotter end up as the more recent ones.
+func (l *LFUCache) maybeDecay() {
+	if l.decayInterval <= 0 || time.Since(l.lastDecay) < l.decayInterval {
+		return
+	}
+	l.lastDecay = time.Now()
+
+	freqs := make([]int, 0, len(l.buckets))
+	for freq := range l.buckets {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	old := l.buckets
+	l.buckets = make(map[int]*list.List)
+	l.minFreq = 0
+	for _, freq := range freqs {
+		for ele := old[freq].Back(); ele != nil; ele = ele.Prev() {
+			entry := ele.Value.(*Entry)
+			entry.Freq = entry.Freq / 2
+			if entry.Freq < 1 {
+				entry.Freq = 1
+			}
+			l.cache[entry.Key] = l.bucket(entry.Freq).PushFront(entry)
+			if l.minFreq == 0 || entry.Freq < l.minFreq {
+				l.minFreq = entry.Freq
+			}
+		}
+	}
+}
+
+func main() {
+	cache := NewLFUCache(2)
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	fmt.Println(cache.Get(1)) // Output: 1
+	cache.Put(3, 3)           // Evicts key 2 (freq 1)
+	fmt.Println(cache.Get(2)) // Output: -1
+	fmt.Println(cache.Get(3)) // Output: 3
+	cache.Put(4, 4)           // Keys 1 and 3 both have freq 2, evicts 1 as least recent
+	fmt.Println(cache.Get(1)) // Output: -1
+	fmt.Println(cache.Get(3)) // Output: 3
+	fmt.Println(cache.Get(4)) // Output: 4
+	fmt.Printf("%+v\n", cache.Stats())
+
+	aging := NewLFUCacheWithDecay(2, 50*time.Millisecond)
+	aging.Put(1, 1)
+	for i := 0; i < 8; i++ {
+		aging.Get(1)
+	}
+	aging.Put(2, 2)
+	aging.Get(2)
+	time.Sleep(60 * time.Millisecond)
+	aging.Get(2) // decay: key 1 freq 9 -> 4, key 2 freq 2 -> 1, then 2
+	time.Sleep(60 * time.Millisecond)
+	aging.Get(2)              // decay: key 1 freq 4 -> 2, key 2 freq 3 -> 1, then 2
+	aging.Put(3, 3)           // tie at freq 2, evicts key 1 which was hot long ago
+	fmt.Println(aging.Get(1)) // Output: -1
+}
@@ -1,12 +1,26 @@
 package main
 
-import "github.com/rishu/design/library-management/system"
+import (
+	"fmt"
+
+	"github.com/rishu/design/library-management/notification"
+	"github.com/rishu/design/library-management/system"
+)
 
 func main() {
 	sys := system.GetLibrarySystem()
 	memberId := "member1"
 	bookItemId := "bookItem1"
 
+	sub := sys.SubscribeNotifications(memberId, func(n notification.Notification) {
+		fmt.Println("push to", n.MemberId+":", n.Message)
+	})
+
 	sys.CheckoutBook(memberId, bookItemId)
+	sys.AddNotification(memberId, "bookItem1 is due in 14 days")
+	sys.AddNotification("member2", "your reservation is ready")
+
+	sub.Unsubscribe()
+	sys.AddNotification(memberId, "bookItem1 is due tomorrow") // stored, not pushed
 	sys.ShowNotification(memberId)
 }
@@ -1,6 +1,29 @@
 package notification
 
+import "github.com/rishu/design/eventbus"
+
 type Notification struct {
 	MemberId string
 	Message  string
 }
+
+// Service delivers notifications to whoever subscribed for the member.
+type Service struct {
+	bus *eventbus.EventBus[Notification]
+}
+
+func NewService() *Service {
+	return &Service{bus: eventbus.NewEventBus[Notification](eventbus.Options{})}
+}
+
+func (s *Service) Subscribe(memberId string, handler func(n Notification)) eventbus.Subscription {
+	return s.bus.Subscribe(func(n Notification) {
+		if n.MemberId == memberId {
+			handler(n)
+		}
+	})
+}
+
+func (s *Service) Notify(n Notification) {
+	s.bus.Publish(n)
+}
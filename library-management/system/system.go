@@ -2,6 +2,7 @@ package system
 
 import (
 	"fmt"
+	"github.com/rishu/design/eventbus"
 	"github.com/rishu/design/library-management/book"
 	"github.com/rishu/design/library-management/member"
 	"github.com/rishu/design/library-management/notification"
@@ -14,6 +15,7 @@ type LibrarySystem struct {
 	Members       []*member.Member
 	Notifications []*notification.Notification
 	Search        search.Search
+	notifier      *notification.Service
 	mu            sync.Mutex
 }
 
@@ -22,7 +24,7 @@ var once sync.Once
 
 func GetLibrarySystem() *LibrarySystem {
 	once.Do(func() {
-		instance = &LibrarySystem{}
+		instance = &LibrarySystem{notifier: notification.NewService()}
 	})
 	return instance
 }
@@ -40,12 +42,20 @@ func (ls *LibrarySystem) ReturnBook(memberId, bookItemId string) {
 }
 
 func (ls *LibrarySystem) AddNotification(memberId, message string) {
-	ls.mu.Lock()
-	defer ls.mu.Unlock()
-	ls.Notifications = append(ls.Notifications, &notification.Notification{
+	n := notification.Notification{
 		MemberId: memberId,
 		Message:  message,
-	})
+	}
+	ls.mu.Lock()
+	ls.Notifications = append(ls.Notifications, &n)
+	ls.mu.Unlock()
+	ls.notifier.Notify(n)
+}
+
+// SubscribeNotifications calls handler for every new notification for the
+// member until the returned subscription is cancelled.
+func (ls *LibrarySystem) SubscribeNotifications(memberId string, handler func(n notification.Notification)) eventbus.Subscription {
+	return ls.notifier.Subscribe(memberId, handler)
 }
 
 func (ls *LibrarySystem) ShowNotification(memberId string) {
@@ -3,12 +3,17 @@ package main
 import (
 	"container/list"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 type LRUCache struct {
 	cap   int
 	cache map[int]*list.Element
 	list  *list.List
+	mu    sync.Mutex
 }
 
 type Entry struct {
@@ -25,6 +30,9 @@ func NewLRUCache(cap int) *LRUCache {
 }
 
 func (l *LRUCache) Get(key int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if ele, found := l.cache[key]; found {
 		l.list.MoveToFront(ele)
 		return ele.Value.(*Entry).Value
@@ -33,6 +41,13 @@ func (l *LRUCache) Get(key int) int {
 }
 
 func (l *LRUCache) Put(key, val int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.put(key, val)
+}
+
+func (l *LRUCache) put(key, val int) {
 	if ele, found := l.cache[key]; found {
 		ele.Value.(*Entry).Value = val
 		l.list.MoveToFront(ele)
@@ -65,4 +80,23 @@ func main() {
 	fmt.Println(cache.Get(1)) // Output: -1
 	fmt.Println(cache.Get(3)) // Output: 3
 	fmt.Println(cache.Get(4)) // Output: 4
+
+	path := filepath.Join(os.TempDir(), "lru.snapshot")
+	if err := cache.SaveSnapshot(path); err != nil {
+		fmt.Println("snapshot failed:", err)
+		return
+	}
+	restored := NewLRUCache(2)
+	if err := restored.RestoreSnapshot(path); err != nil {
+		fmt.Println("restore failed:", err)
+		return
+	}
+	restored.Put(5, 5)           // Evicts key 3, the least recent before the restart
+	fmt.Println(restored.Get(3)) // Output: -1
+	fmt.Println(restored.Get(4)) // Output: 4
+
+	stop := restored.StartSnapshots(path, time.Minute, func(err error) {
+		fmt.Println("periodic snapshot failed:", err)
+	})
+	stop()
 }
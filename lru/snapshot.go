@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rishu/design/snapshot"
+)
+
+// The payload is the entry count followed by each key and value as a
+// varint, from least to most recently used.
+const (
+	snapshotMagic   = "LRUS"
+	snapshotVersion = 2
+)
+
+// SaveSnapshot writes the entries and their recency order to path, holding
+// the lock only while the list is encoded.
+func (l *LRUCache) SaveSnapshot(path string) error {
+	var w snapshot.Writer
+	l.mu.Lock()
+	w.Varint(int64(l.list.Len()))
+	for ele := l.list.Back(); ele != nil; ele = ele.Prev() {
+		entry := ele.Value.(*Entry)
+		w.Varint(int64(entry.Key))
+		w.Varint(int64(entry.Value))
+	}
+	l.mu.Unlock()
+
+	return snapshot.WriteFile(path, snapshotMagic, snapshotVersion, w.Bytes())
+}
+
+// RestoreSnapshot replays a snapshot from SaveSnapshot under a single lock,
+// so readers never see a half-restored cache. Entries beyond the capacity
+// evict the oldest restored ones, as live Puts would.
+func (l *LRUCache) RestoreSnapshot(path string) error {
+	r, err := snapshot.ReadFile(path, snapshotMagic, snapshotVersion)
+	if err != nil {
+		return err
+	}
+	count, err := r.Varint()
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := int64(0); i < count; i++ {
+		key, err := r.Varint()
+		if err != nil {
+			return err
+		}
+		val, err := r.Varint()
+		if err != nil {
+			return err
+		}
+		l.put(int(key), int(val))
+	}
+	return nil
+}
+
+// StartSnapshots saves to path every interval until the returned function
+// is called, which also takes a final snapshot. Failed periodic saves are
+// passed to onError if it is set.
+func (l *LRUCache) StartSnapshots(path string, interval time.Duration, onError func(error)) (stop func() error) {
+	return snapshot.Every(interval, func() error { return l.SaveSnapshot(path) }, onError)
+}
@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/rishu/design/eventbus"
+)
 
 type Observer interface {
 	Update(temp float64)
@@ -13,34 +17,40 @@ type Subject interface {
 }
 
 type WeatherStation struct {
-	observers []Observer
+	bus       *eventbus.EventBus[float64]
+	observers map[Observer]eventbus.Subscription
 	temp      float64
 }
 
+func NewWeatherStation() *WeatherStation {
+	return &WeatherStation{
+		bus:       eventbus.NewEventBus[float64](eventbus.Options{}),
+		observers: make(map[Observer]eventbus.Subscription),
+	}
+}
+
 func (w *WeatherStation) RegisterObserver(observer Observer) {
-	w.observers = append(w.observers, observer)
+	if _, exists := w.observers[observer]; exists {
+		return
+	}
+	w.observers[observer] = w.bus.Subscribe(observer.Update)
 }
 
 func (w *WeatherStation) RemoveObserver(observer Observer) {
-	res := make([]Observer, 0)
-	for _, obs := range w.observers {
-		if obs == observer {
-			continue
-		}
-		res = append(res, obs)
+	if sub, exists := w.observers[observer]; exists {
+		sub.Unsubscribe()
+		delete(w.observers, observer)
 	}
-	w.observers = res
 }
 
 func (w *WeatherStation) NotifyAll() {
-
+	w.bus.Publish(w.temp)
 }
 
 func (w *WeatherStation) ChangeTemperature(temp float64) {
 	fmt.Println("temperature changes")
-	for _, obs := range w.observers {
-		obs.Update(temp)
-	}
+	w.temp = temp
+	w.NotifyAll()
 }
 
 type Android struct {
@@ -60,10 +70,11 @@ func (a *IOS) Update(temp float64) {
 func main() {
 	and := &Android{}
 	ios := &IOS{}
-	ws := &WeatherStation{
-		observers: make([]Observer, 0),
-	}
+	ws := NewWeatherStation()
 	ws.RegisterObserver(and)
 	ws.RegisterObserver(ios)
 	ws.ChangeTemperature(34)
+
+	ws.RemoveObserver(ios)
+	ws.ChangeTemperature(30) // only android
 }
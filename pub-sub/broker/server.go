@@ -0,0 +1,159 @@
+// Package broker serves a pubsub.PubSubService over the network, speaking
+// the frames of package protocol over TCP and WebSocket.
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/pub-sub/protocol"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+var ErrServerClosed = errors.New("broker: server closed")
+
+// sessionIds numbers connections process-wide, so servers sharing one
+// PubSubService never hand out the same subscriber id.
+var sessionIds uint64
+
+type Config struct {
+	// HeartbeatInterval is how often the server pings each client. A
+	// client that sends nothing for three intervals is disconnected.
+	HeartbeatInterval time.Duration
+	// SendBuffer bounds the frames queued for one client before delivery
+	// to it blocks.
+	SendBuffer int
+	// AllowedOrigins lists the Origin headers WebSocket upgrades are
+	// accepted from, e.g. "https://app.example.com"; "*" accepts any. When
+	// empty only same-host browser pages may connect. Clients that send no
+	// Origin, such as package client, are always accepted.
+	AllowedOrigins []string
+}
+
+type Server struct {
+	svc       *pubsub.PubSubService
+	cfg       Config
+	upgrader  websocket.Upgrader
+	listeners map[net.Listener]struct{}
+	sessions  map[*session]struct{}
+	closed    bool
+	mu        sync.Mutex
+}
+
+func NewServer(svc *pubsub.PubSubService, cfg Config) *Server {
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 5 * time.Second
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = 256
+	}
+	return &Server{
+		svc:       svc,
+		cfg:       cfg,
+		upgrader:  websocket.Upgrader{CheckOrigin: checkOrigin(cfg.AllowedOrigins)},
+		listeners: make(map[net.Listener]struct{}),
+		sessions:  make(map[*session]struct{}),
+	}
+}
+
+// checkOrigin returns nil, gorilla's same-host check, when no origins are
+// configured.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(a, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ServeTCP accepts framed TCP connections until the listener fails or the
+// server is closed.
+func (s *Server) ServeTCP(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.serve(protocol.NewStreamConn(conn))
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket and serves it like a TCP
+// connection.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.serve(protocol.NewWebSocketConn(ws))
+}
+
+// Close stops the listeners and drops every client. The PubSubService is
+// left open.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var sessions []*session
+	for l := range s.listeners {
+		l.Close()
+	}
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.close()
+	}
+	return nil
+}
+
+func (s *Server) serve(conn protocol.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	sess := newSession(s, fmt.Sprintf("conn-%d", atomic.AddUint64(&sessionIds, 1)), conn)
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+
+	sess.run()
+
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+}
@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+func TestAllowedOrigins(t *testing.T) {
+	svc := pubsub.NewPubSubService(pubsub.Config{})
+	defer svc.Close()
+
+	for _, tc := range []struct {
+		allowed []string
+		origin  string
+		ok      bool
+	}{
+		{nil, "", true},
+		{nil, "https://evil.example", false},
+		{[]string{"https://app.example"}, "https://app.example", true},
+		{[]string{"https://app.example"}, "https://evil.example", false},
+		{[]string{"*"}, "https://evil.example", true},
+	} {
+		server := NewServer(svc, Config{AllowedOrigins: tc.allowed})
+		ts := httptest.NewServer(server)
+		header := http.Header{}
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+		if (err == nil) != tc.ok {
+			t.Errorf("allowed %v, origin %q: err = %v, want ok = %v", tc.allowed, tc.origin, err, tc.ok)
+		}
+		if ws != nil {
+			ws.Close()
+		}
+		server.Close()
+		ts.Close()
+	}
+}
@@ -0,0 +1,250 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rishu/design/pub-sub/protocol"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+type ackKey struct {
+	subscription string
+	topic        string
+	partition    int
+	offset       int64
+}
+
+// session is one client connection. Frames to the client go through a
+// single writer goroutine; messages it has not acked yet are kept so an ACK
+// frame can be matched back to them.
+type session struct {
+	server  *Server
+	id      string
+	conn    protocol.Conn
+	out     chan protocol.Frame
+	done    chan struct{}
+	once    sync.Once
+	subs    map[string]*remoteSub
+	unacked map[ackKey]pubsub.Message
+	mu      sync.Mutex
+}
+
+func newSession(server *Server, id string, conn protocol.Conn) *session {
+	return &session{
+		server:  server,
+		id:      id,
+		conn:    conn,
+		out:     make(chan protocol.Frame, server.cfg.SendBuffer),
+		done:    make(chan struct{}),
+		subs:    make(map[string]*remoteSub),
+		unacked: make(map[ackKey]pubsub.Message),
+	}
+}
+
+func (s *session) run() {
+	go s.write()
+	defer s.close()
+
+	interval := s.server.cfg.HeartbeatInterval
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(3 * interval))
+		f, err := s.conn.ReadFrame()
+		if err != nil {
+			return
+		}
+		s.handle(f)
+	}
+}
+
+func (s *session) write() {
+	ticker := time.NewTicker(s.server.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		var f protocol.Frame
+		select {
+		case <-s.done:
+			return
+		case f = <-s.out:
+		case <-ticker.C:
+			f = protocol.Frame{Type: protocol.Ping}
+		}
+		if err := s.conn.WriteFrame(f); err != nil {
+			s.close()
+			return
+		}
+	}
+}
+
+// send queues a frame for the client, giving up once the session ends.
+func (s *session) send(f protocol.Frame) bool {
+	select {
+	case s.out <- f:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *session) reply(req protocol.Frame, err error) {
+	if err != nil {
+		s.send(protocol.Frame{Type: protocol.Error, Id: req.Id, Error: err.Error()})
+		return
+	}
+	s.send(protocol.Frame{Type: protocol.OK, Id: req.Id})
+}
+
+func (s *session) handle(f protocol.Frame) {
+	svc := s.server.svc
+	switch f.Type {
+	case protocol.Ping:
+		s.send(protocol.Frame{Type: protocol.Pong})
+	case protocol.Pong:
+	case protocol.Publish:
+		msg, err := svc.Publish(pubsub.Message{Topic: f.Topic, Key: f.Key, Headers: f.Headers, Payload: f.Payload})
+		if err != nil {
+			s.reply(f, err)
+			return
+		}
+		s.send(protocol.Frame{Type: protocol.OK, Id: f.Id, Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset})
+	case protocol.Subscribe:
+		positions, err := s.subscribe(f)
+		if err != nil {
+			s.reply(f, err)
+			return
+		}
+		s.send(protocol.Frame{Type: protocol.OK, Id: f.Id, Positions: positions})
+	case protocol.Unsubscribe:
+		s.unsubscribe(f.Subscription)
+		s.reply(f, nil)
+	case protocol.Ack, protocol.Nack:
+		key := ackKey{subscription: f.Subscription, topic: f.Topic, partition: f.Partition, offset: f.Offset}
+		s.mu.Lock()
+		msg, ok := s.unacked[key]
+		delete(s.unacked, key)
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		if f.Type == protocol.Ack {
+			msg.Ack()
+		} else {
+			msg.Nack()
+		}
+	default:
+		s.reply(f, fmt.Errorf("unknown frame type %q", f.Type))
+	}
+}
+
+// subscribe returns where each partition of a plain subscription starts;
+// consumer groups start from their committed offsets instead.
+func (s *session) subscribe(f protocol.Frame) ([]protocol.Position, error) {
+	if f.Subscription == "" {
+		return nil, fmt.Errorf("subscription name is required")
+	}
+	if f.Group != "" && f.Filter != "" {
+		return nil, fmt.Errorf("filters are not supported for consumer groups")
+	}
+	s.unsubscribe(f.Subscription)
+
+	rs := &remoteSub{session: s, name: f.Subscription, pattern: f.Topic, group: f.Group}
+	var positions []protocol.Position
+	if f.Group != "" {
+		if err := s.server.svc.JoinGroup(f.Topic, f.Group, rs); err != nil {
+			return nil, err
+		}
+	} else {
+		resume := make([]pubsub.Position, len(f.Positions))
+		for i, pos := range f.Positions {
+			resume[i] = pubsub.Position{Topic: pos.Topic, Partition: pos.Partition, Offset: pos.Offset}
+		}
+		starts, err := s.server.svc.SubscribeAt(f.Topic, f.Filter, rs, f.From, resume)
+		if err != nil {
+			return nil, err
+		}
+		for _, pos := range starts {
+			positions = append(positions, protocol.Position{Topic: pos.Topic, Partition: pos.Partition, Offset: pos.Offset})
+		}
+	}
+	s.mu.Lock()
+	s.subs[rs.name] = rs
+	s.mu.Unlock()
+	return positions, nil
+}
+
+func (s *session) unsubscribe(name string) {
+	s.mu.Lock()
+	rs, ok := s.subs[name]
+	delete(s.subs, name)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	rs.cancel()
+
+	s.mu.Lock()
+	for key := range s.unacked {
+		if key.subscription == name {
+			delete(s.unacked, key)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// close ends the session and drops its subscriptions; messages it had not
+// acked are redelivered by the service after their ack timeout.
+func (s *session) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.conn.Close()
+
+		s.mu.Lock()
+		subs := s.subs
+		s.subs = make(map[string]*remoteSub)
+		s.mu.Unlock()
+		for _, rs := range subs {
+			rs.cancel()
+		}
+	})
+}
+
+// remoteSub is a client's subscription as seen by the PubSubService.
+type remoteSub struct {
+	session *session
+	name    string
+	pattern string
+	group   string
+}
+
+func (rs *remoteSub) GetId() string {
+	return rs.session.id + "/" + rs.name
+}
+
+func (rs *remoteSub) Consume(msg pubsub.Message) {
+	s := rs.session
+	key := ackKey{subscription: rs.name, topic: msg.Topic, partition: msg.Partition, offset: msg.Offset}
+	s.mu.Lock()
+	s.unacked[key] = msg
+	s.mu.Unlock()
+
+	s.send(protocol.Frame{
+		Type:         protocol.Message,
+		Subscription: rs.name,
+		Topic:        msg.Topic,
+		Key:          msg.Key,
+		Headers:      msg.Headers,
+		Payload:      msg.Payload,
+		Partition:    msg.Partition,
+		Offset:       msg.Offset,
+		Attempt:      msg.Attempt,
+	})
+}
+
+func (rs *remoteSub) cancel() {
+	if rs.group != "" {
+		rs.session.server.svc.LeaveGroup(rs.pattern, rs.group, rs.GetId())
+	} else {
+		rs.session.server.svc.RemoveSubscriber(rs.pattern, rs.GetId())
+	}
+}
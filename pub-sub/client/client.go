@@ -0,0 +1,304 @@
+// Package client talks to a pub-sub broker over TCP or WebSocket. It
+// answers the broker's heartbeats, notices a dead connection, reconnects
+// with backoff and re-establishes every subscription.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/pub-sub/protocol"
+)
+
+var (
+	ErrClosed       = errors.New("client: closed")
+	ErrDisconnected = errors.New("client: disconnected")
+)
+
+type Options struct {
+	// HeartbeatTimeout is how long the connection may stay silent before
+	// it is considered dead; it should be a few broker heartbeat intervals.
+	HeartbeatTimeout time.Duration
+	// Reconnect attempts back off from MinBackoff, doubling up to
+	// MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnStateChange, if set, is told whenever the connection goes down or
+	// comes back up; it reports up once subscriptions are re-established.
+	// Subscriptions the broker rejects on the way are dropped, and err says
+	// which and why.
+	OnStateChange func(connected bool, err error)
+}
+
+type Client struct {
+	addr      string
+	opts      Options
+	conn      protocol.Conn
+	connected chan struct{}
+	pending   map[uint64]chan protocol.Frame
+	subs      map[string]*Subscription
+	nextId    uint64
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+}
+
+// Dial connects to a broker at "host:port" (or "tcp://host:port") or at a
+// "ws://" or "wss://" URL. Only the first connection attempt can fail;
+// after that the client keeps reconnecting until it is closed.
+func Dial(addr string, opts Options) (*Client, error) {
+	if opts.HeartbeatTimeout <= 0 {
+		opts.HeartbeatTimeout = 15 * time.Second
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	c := &Client{
+		addr:      addr,
+		opts:      opts,
+		connected: make(chan struct{}),
+		pending:   make(map[uint64]chan protocol.Frame),
+		subs:      make(map[string]*Subscription),
+		closed:    make(chan struct{}),
+	}
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.setConn(conn)
+	go c.run(conn)
+	return c, nil
+}
+
+func dial(addr string) (protocol.Conn, error) {
+	if strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://") {
+		ws, _, err := websocket.DefaultDialer.Dial(addr, nil)
+		if err != nil {
+			return nil, err
+		}
+		return protocol.NewWebSocketConn(ws), nil
+	}
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(addr, "tcp://"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewStreamConn(conn), nil
+}
+
+func (c *Client) setConn(conn protocol.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	close(c.connected)
+	c.mu.Unlock()
+}
+
+// run reads from the connection until it fails, then reconnects and
+// resubscribes, until the client is closed.
+func (c *Client) run(conn protocol.Conn) {
+	for {
+		c.read(conn)
+		c.disconnect(conn)
+		if c.opts.OnStateChange != nil {
+			c.opts.OnStateChange(false, nil)
+		}
+
+		conn = c.reconnect()
+		if conn == nil {
+			return
+		}
+		c.setConn(conn)
+		go c.resubscribe()
+	}
+}
+
+func (c *Client) read(conn protocol.Conn) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.opts.HeartbeatTimeout))
+		f, err := conn.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch f.Type {
+		case protocol.Ping:
+			conn.WriteFrame(protocol.Frame{Type: protocol.Pong})
+		case protocol.OK, protocol.Error:
+			c.mu.Lock()
+			reply, ok := c.pending[f.Id]
+			delete(c.pending, f.Id)
+			c.mu.Unlock()
+			if ok {
+				reply <- f
+			}
+		case protocol.Message:
+			// deliver never blocks, so a slow handler cannot keep this loop
+			// from answering pings.
+			c.mu.Lock()
+			sub, ok := c.subs[f.Subscription]
+			c.mu.Unlock()
+			if ok {
+				sub.deliver(c.message(f))
+			}
+		}
+	}
+}
+
+// disconnect fails every request waiting on the dead connection.
+func (c *Client) disconnect(conn protocol.Conn) {
+	conn.Close()
+	c.mu.Lock()
+	c.conn = nil
+	c.connected = make(chan struct{})
+	for id, reply := range c.pending {
+		reply <- protocol.Frame{Type: protocol.Error, Error: ErrDisconnected.Error()}
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+func (c *Client) reconnect() protocol.Conn {
+	backoff := c.opts.MinBackoff
+	for {
+		select {
+		case <-c.closed:
+			return nil
+		case <-time.After(backoff):
+		}
+		conn, err := dial(c.addr)
+		if err == nil {
+			return conn
+		}
+		backoff *= 2
+		if backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// resubscribe sets every subscription up again on a new connection. If that
+// connection drops too, run reconnects and resubscribes once more.
+func (c *Client) resubscribe() {
+	c.mu.Lock()
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.mu.Unlock()
+
+	var rejected []string
+	for _, sub := range subs {
+		r, err := c.request(context.Background(), sub.frame(true))
+		switch {
+		case err == nil:
+			sub.track(r.Positions)
+		case err == ErrDisconnected || err == ErrClosed:
+			return
+		default:
+			c.mu.Lock()
+			delete(c.subs, sub.name)
+			c.mu.Unlock()
+			sub.stop()
+			rejected = append(rejected, fmt.Sprintf("%q: %v", sub.pattern, err))
+		}
+	}
+	if c.opts.OnStateChange != nil {
+		var err error
+		if len(rejected) > 0 {
+			err = fmt.Errorf("client: subscriptions rejected on reconnect: %s", strings.Join(rejected, "; "))
+		}
+		c.opts.OnStateChange(true, err)
+	}
+}
+
+// request sends a frame that expects an OK or ERROR reply, waiting for a
+// connection first if there is none.
+func (c *Client) request(ctx context.Context, f protocol.Frame) (protocol.Frame, error) {
+	var conn protocol.Conn
+	for conn == nil {
+		c.mu.Lock()
+		conn = c.conn
+		connected := c.connected
+		c.mu.Unlock()
+		if conn != nil {
+			break
+		}
+		select {
+		case <-connected:
+		case <-ctx.Done():
+			return protocol.Frame{}, ctx.Err()
+		case <-c.closed:
+			return protocol.Frame{}, ErrClosed
+		}
+	}
+
+	reply := make(chan protocol.Frame, 1)
+	c.mu.Lock()
+	c.nextId++
+	f.Id = c.nextId
+	c.pending[f.Id] = reply
+	c.mu.Unlock()
+
+	if err := conn.WriteFrame(f); err != nil {
+		c.forget(f.Id)
+		conn.Close()
+		return protocol.Frame{}, ErrDisconnected
+	}
+	select {
+	case r := <-reply:
+		if r.Type == protocol.Error {
+			if r.Error == ErrDisconnected.Error() {
+				return r, ErrDisconnected
+			}
+			return r, errors.New(r.Error)
+		}
+		return r, nil
+	case <-ctx.Done():
+		c.forget(f.Id)
+		return protocol.Frame{}, ctx.Err()
+	case <-c.closed:
+		return protocol.Frame{}, ErrClosed
+	}
+}
+
+func (c *Client) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// send writes a frame that gets no reply; it is dropped while disconnected.
+func (c *Client) send(f protocol.Frame) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		conn.WriteFrame(f)
+	}
+}
+
+// Close disconnects and stops every subscription.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mu.Lock()
+		conn := c.conn
+		subs := c.subs
+		c.subs = make(map[string]*Subscription)
+		c.mu.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+		for _, sub := range subs {
+			sub.stop()
+		}
+	})
+	return nil
+}
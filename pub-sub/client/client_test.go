@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rishu/design/pub-sub/broker"
+	"github.com/rishu/design/pub-sub/protocol"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+var testBroker = broker.Config{HeartbeatInterval: 50 * time.Millisecond}
+
+func listen(t *testing.T, addr string) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func receive(t *testing.T, ch <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	svc := pubsub.NewPubSubService(pubsub.Config{})
+	defer svc.Close()
+	server := broker.NewServer(svc, testBroker)
+	defer server.Close()
+	l := listen(t, "127.0.0.1:0")
+	go server.ServeTCP(l)
+	ws := httptest.NewServer(server)
+	defer ws.Close()
+
+	ctx := context.Background()
+	for _, addr := range []string{l.Addr().String(), "ws" + strings.TrimPrefix(ws.URL, "http")} {
+		c, err := Dial(addr, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan Message, 4)
+		if _, err := c.Subscribe(ctx, "orders.#", SubscribeOptions{Filter: "region = 'eu'", From: Latest}, func(msg Message) {
+			msg.Ack()
+			received <- msg
+		}); err != nil {
+			t.Fatal(err)
+		}
+		c.Publish(ctx, Message{Topic: "orders.web", Headers: map[string]string{"region": "us"}, Payload: "us"})
+		if _, err := c.Publish(ctx, Message{Topic: "orders.web", Headers: map[string]string{"region": "eu"}, Payload: "eu"}); err != nil {
+			t.Fatal(err)
+		}
+		if msg := receive(t, received); msg.Payload != "eu" {
+			t.Fatalf("%s: got %q, want the eu order", addr, msg.Payload)
+		}
+		c.Close()
+	}
+}
+
+func TestResubscribeResumesWhereItLeftOff(t *testing.T) {
+	svc := pubsub.NewPubSubService(pubsub.Config{Partitions: 2})
+	defer svc.Close()
+	server := broker.NewServer(svc, testBroker)
+	l := listen(t, "127.0.0.1:0")
+	addr := l.Addr().String()
+	go server.ServeTCP(l)
+
+	up := make(chan bool, 4)
+	c, err := Dial(addr, Options{
+		HeartbeatTimeout: 200 * time.Millisecond,
+		MinBackoff:       10 * time.Millisecond,
+		OnStateChange:    func(connected bool, err error) { up <- connected },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	received := make(chan Message, 16)
+	if _, err := c.Subscribe(ctx, "alerts", SubscribeOptions{From: Latest}, func(msg Message) {
+		msg.Ack()
+		received <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Publish(ctx, Message{Topic: "alerts", Key: "a", Payload: "before"})
+	receive(t, received)
+
+	server.Close()
+	if <-up {
+		t.Fatal("expected a disconnect")
+	}
+	// Published while the client is away, to both partitions.
+	svc.Publish(pubsub.Message{Topic: "alerts", Key: "a", Payload: "missed 1"})
+	svc.Publish(pubsub.Message{Topic: "alerts", Key: "b", Payload: "missed 2"})
+
+	server = broker.NewServer(svc, testBroker)
+	defer server.Close()
+	go server.ServeTCP(listen(t, addr))
+	if !<-up {
+		t.Fatal("expected a reconnect")
+	}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		got[receive(t, received).Payload] = true
+	}
+	if !got["missed 1"] || !got["missed 2"] {
+		t.Fatalf("after reconnect got %v, want both messages published while disconnected", got)
+	}
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected redelivery of %q", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRejectedResubscribeIsReported(t *testing.T) {
+	// A broker that accepts the first SUBSCRIBE, drops the connection and
+	// rejects the subscription from then on.
+	l := listen(t, "127.0.0.1:0")
+	defer l.Close()
+	go func() {
+		for accepted := 0; ; accepted++ {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn := protocol.NewStreamConn(nc)
+			go func(first bool) {
+				defer conn.Close()
+				for {
+					f, err := conn.ReadFrame()
+					if err != nil || f.Type != protocol.Subscribe {
+						return
+					}
+					if !first {
+						conn.WriteFrame(protocol.Frame{Type: protocol.Error, Id: f.Id, Error: "group is full"})
+						continue
+					}
+					conn.WriteFrame(protocol.Frame{Type: protocol.OK, Id: f.Id})
+					return
+				}
+			}(accepted == 0)
+		}
+	}()
+
+	type state struct {
+		connected bool
+		err       error
+	}
+	states := make(chan state, 4)
+	c, err := Dial(l.Addr().String(), Options{
+		MinBackoff:    10 * time.Millisecond,
+		OnStateChange: func(connected bool, err error) { states <- state{connected, err} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Subscribe(context.Background(), "jobs", SubscribeOptions{Group: "workers"}, func(Message) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := <-states; s.connected {
+		t.Fatal("expected a disconnect")
+	}
+	s := <-states
+	if !s.connected || s.err == nil || !strings.Contains(s.err.Error(), "group is full") {
+		t.Fatalf("reconnect reported (%v, %v), want up with the broker's rejection", s.connected, s.err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subs) != 0 {
+		t.Fatalf("%d subscriptions kept after the broker rejected them", len(c.subs))
+	}
+}
+
+func TestSlowHandlerDoesNotStopHeartbeats(t *testing.T) {
+	svc := pubsub.NewPubSubService(pubsub.Config{QueueSize: 1000})
+	defer svc.Close()
+	server := broker.NewServer(svc, testBroker)
+	defer server.Close()
+	l := listen(t, "127.0.0.1:0")
+	go server.ServeTCP(l)
+
+	down := make(chan struct{}, 1)
+	c, err := Dial(l.Addr().String(), Options{
+		HeartbeatTimeout: 200 * time.Millisecond,
+		OnStateChange: func(connected bool, err error) {
+			if !connected {
+				down <- struct{}{}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	release := make(chan struct{})
+	handled := make(chan Message, 1000)
+	c.Subscribe(ctx, "jobs", SubscribeOptions{From: Latest}, func(msg Message) {
+		<-release
+		msg.Ack()
+		handled <- msg
+	})
+	const n = 500 // more than a subscription used to buffer
+	for i := 0; i < n; i++ {
+		if _, err := c.Publish(ctx, Message{Topic: "jobs", Payload: "job"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-down:
+		t.Fatal("connection dropped while the handler was busy")
+	case <-time.After(500 * time.Millisecond):
+	}
+	close(release)
+	for i := 0; i < n; i++ {
+		receive(t, handled)
+	}
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rishu/design/pub-sub/protocol"
+)
+
+// Start positions for SubscribeOptions.From, as in package pubsub.
+const (
+	Earliest int64 = 0
+	Latest   int64 = -1
+)
+
+type Message struct {
+	Topic     string
+	Key       string
+	Headers   map[string]string
+	Payload   string
+	Partition int
+	Offset    int64
+	Attempt   int
+
+	client       *Client
+	subscription string
+}
+
+// Ack tells the broker the message was processed.
+func (m Message) Ack() {
+	m.client.send(m.ackFrame(protocol.Ack))
+}
+
+// Nack asks the broker to redeliver the message.
+func (m Message) Nack() {
+	m.client.send(m.ackFrame(protocol.Nack))
+}
+
+func (m Message) ackFrame(t protocol.FrameType) protocol.Frame {
+	return protocol.Frame{Type: t, Subscription: m.subscription, Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+}
+
+func (c *Client) message(f protocol.Frame) Message {
+	return Message{
+		Topic:        f.Topic,
+		Key:          f.Key,
+		Headers:      f.Headers,
+		Payload:      f.Payload,
+		Partition:    f.Partition,
+		Offset:       f.Offset,
+		Attempt:      f.Attempt,
+		client:       c,
+		subscription: f.Subscription,
+	}
+}
+
+// Publish sends the message and returns it with the partition and offset
+// the broker stored it at.
+func (c *Client) Publish(ctx context.Context, msg Message) (Message, error) {
+	r, err := c.request(ctx, protocol.Frame{
+		Type:    protocol.Publish,
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Headers: msg.Headers,
+		Payload: msg.Payload,
+	})
+	if err != nil {
+		return msg, err
+	}
+	msg.Partition = r.Partition
+	msg.Offset = r.Offset
+	return msg, nil
+}
+
+type SubscribeOptions struct {
+	// Filter is a header filter expression, e.g. "region = 'eu'".
+	Filter string
+	// Group joins a consumer group instead of receiving every message.
+	Group string
+	// From is where a plain subscription starts reading. After a reconnect
+	// it resumes each partition after the last message it received, so
+	// nothing published while disconnected is missed as long as the broker
+	// kept its log. A Group resumes from its committed offsets instead.
+	From int64
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// Subscription hands messages to its handler one at a time, in the order
+// they arrived, on its own goroutine. Messages wait in an unbounded buffer
+// so the connection's reader never blocks on a slow handler; the broker's
+// in-flight limit bounds it as long as the handler acks.
+type Subscription struct {
+	client    *Client
+	name      string
+	pattern   string
+	opts      SubscribeOptions
+	handler   func(Message)
+	buffered  []Message
+	ready     chan struct{}
+	positions map[partitionKey]int64
+	done      chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+}
+
+// Subscribe registers handler for topics matching pattern. If the
+// connection drops while subscribing, the subscription is still kept and
+// set up again once the client reconnects.
+func (c *Client) Subscribe(ctx context.Context, pattern string, opts SubscribeOptions, handler func(Message)) (*Subscription, error) {
+	c.mu.Lock()
+	c.nextId++
+	sub := &Subscription{
+		client:    c,
+		name:      fmt.Sprintf("sub-%d", c.nextId),
+		pattern:   pattern,
+		opts:      opts,
+		handler:   handler,
+		ready:     make(chan struct{}, 1),
+		positions: make(map[partitionKey]int64),
+		done:      make(chan struct{}),
+	}
+	c.subs[sub.name] = sub
+	c.mu.Unlock()
+	go sub.run()
+
+	r, err := c.request(ctx, sub.frame(false))
+	if err != nil && err != ErrDisconnected {
+		c.mu.Lock()
+		delete(c.subs, sub.name)
+		c.mu.Unlock()
+		sub.stop()
+		return nil, err
+	}
+	sub.track(r.Positions)
+	return sub, nil
+}
+
+// frame builds the SUBSCRIBE request. On a resubscription every partition
+// seen so far resumes at its next offset; topics that appeared meanwhile
+// start from their beginning, as they would have without the reconnect.
+func (s *Subscription) frame(resubscribe bool) protocol.Frame {
+	f := protocol.Frame{
+		Type:         protocol.Subscribe,
+		Subscription: s.name,
+		Topic:        s.pattern,
+		Filter:       s.opts.Filter,
+		Group:        s.opts.Group,
+		From:         s.opts.From,
+	}
+	if !resubscribe || s.opts.Group != "" {
+		return f
+	}
+	f.From = Earliest
+	s.mu.Lock()
+	for key, offset := range s.positions {
+		f.Positions = append(f.Positions, protocol.Position{Topic: key.topic, Partition: key.partition, Offset: offset})
+	}
+	s.mu.Unlock()
+	return f
+}
+
+// track records the broker's start positions for partitions the
+// subscription has not received anything from yet.
+func (s *Subscription) track(positions []protocol.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pos := range positions {
+		key := partitionKey{pos.Topic, pos.Partition}
+		if _, ok := s.positions[key]; !ok {
+			s.positions[key] = pos.Offset
+		}
+	}
+}
+
+// Unsubscribe stops the subscription on the broker and locally.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	c := s.client
+	c.mu.Lock()
+	delete(c.subs, s.name)
+	c.mu.Unlock()
+	s.stop()
+
+	_, err := c.request(ctx, protocol.Frame{Type: protocol.Unsubscribe, Subscription: s.name})
+	if err == ErrDisconnected {
+		return nil
+	}
+	return err
+}
+
+// deliver buffers the message for the handler and moves the partition's
+// resume position past it.
+func (s *Subscription) deliver(msg Message) {
+	s.mu.Lock()
+	s.buffered = append(s.buffered, msg)
+	if s.opts.Group == "" {
+		key := partitionKey{msg.Topic, msg.Partition}
+		if next, ok := s.positions[key]; !ok || msg.Offset >= next {
+			s.positions[key] = msg.Offset + 1
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.ready:
+		}
+		for {
+			s.mu.Lock()
+			if len(s.buffered) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			msg := s.buffered[0]
+			s.buffered[0] = Message{}
+			s.buffered = s.buffered[1:]
+			s.mu.Unlock()
+
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.handler(msg)
+		}
+	}
+}
+
+func (s *Subscription) stop() {
+	s.once.Do(func() { close(s.done) })
+}
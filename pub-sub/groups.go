@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+// GroupWorker is one member of a consumer group.
+type GroupWorker struct {
+	Id    string
+	wg    *sync.WaitGroup
+	count int
+	mu    sync.Mutex
+}
+
+func (gw *GroupWorker) Consume(msg pubsub.Message) {
+	gw.mu.Lock()
+	gw.count++
+	gw.mu.Unlock()
+	msg.Ack()
+	gw.wg.Done()
+}
+
+func (gw *GroupWorker) GetId() string {
+	return gw.Id
+}
+
+func (gw *GroupWorker) Count() int {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	return gw.count
+}
+
+func publishOrders(svc *pubsub.PubSubService, wg *sync.WaitGroup, from, to int) {
+	wg.Add(to - from + 1)
+	for i := from; i <= to; i++ {
+		svc.Publish(pubsub.Message{Topic: "orders", Key: fmt.Sprintf("order-%d", i), Payload: fmt.Sprintf("order %d", i)})
+	}
+	wg.Wait()
+}
+
+func groupDemo(dir string) {
+	svc := pubsub.NewPubSubService(pubsub.Config{Dir: dir, Partitions: 4})
+
+	var wg sync.WaitGroup
+	w1 := &GroupWorker{Id: "w1", wg: &wg}
+	w2 := &GroupWorker{Id: "w2", wg: &wg}
+
+	svc.JoinGroup("orders", "billing", w1)
+	fmt.Println(svc.Assignment("orders", "billing")) // map[w1:[0 1 2 3]]
+	publishOrders(svc, &wg, 1, 8)
+
+	svc.JoinGroup("orders", "billing", w2)
+	fmt.Println(svc.Assignment("orders", "billing")) // map[w1:[0 2] w2:[1 3]]
+	publishOrders(svc, &wg, 9, 40)
+	fmt.Println("both workers busy:", w1.Count() > 8 && w2.Count() > 0) // true
+	fmt.Println("each order once:", w1.Count()+w2.Count())              // 40
+
+	svc.LeaveGroup("orders", "billing", "w1")
+	fmt.Println(svc.Assignment("orders", "billing")) // map[w2:[0 1 2 3]]
+
+	var total int64
+	for _, offset := range svc.Committed("orders", "billing") {
+		total += offset
+	}
+	fmt.Println("committed:", total) // 40
+	svc.Close()
+
+	// A restarted consumer resumes from the group's committed offsets.
+	restarted := pubsub.NewPubSubService(pubsub.Config{Dir: dir, Partitions: 4})
+	defer restarted.Close()
+	w3 := &GroupWorker{Id: "w3", wg: &wg}
+	restarted.JoinGroup("orders", "billing", w3)
+	publishOrders(restarted, &wg, 41, 42)
+	fmt.Println("after restart:", w3.Count()) // 2
+}
@@ -2,108 +2,135 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"sync"
-)
-
-type Message struct {
-	Topic   string
-	Payload string
-}
-
-type Subscriber interface {
-	Consume(msg Message)
-	GetId() string
-}
+	"time"
 
-type Publisher interface {
-	Publish(msg Message)
-	AddSubscriber(topic string, sub Subscriber)
-	RemoveSubscriber(topic string, subId string)
-}
+	"github.com/rishu/design/pub-sub/pubsub"
+)
 
 type ConcreteSubscriber struct {
 	Id string
+	wg *sync.WaitGroup
 }
 
-func (cs *ConcreteSubscriber) Consume(msg Message) {
-	fmt.Printf("Subscriber %s received message: %s on topic: %s\n", cs.Id, msg.Payload, msg.Topic)
+func (cs *ConcreteSubscriber) Consume(msg pubsub.Message) {
+	fmt.Printf("Subscriber %s received message: %s on topic: %s (offset %d)\n", cs.Id, msg.Payload, msg.Topic, msg.Offset)
+	msg.Ack()
+	if cs.wg != nil {
+		cs.wg.Done()
+	}
 }
 
 func (cs *ConcreteSubscriber) GetId() string {
 	return cs.Id
 }
 
-type PubSubService struct {
-	subs map[string]map[string]Subscriber
-	lock sync.Mutex
+// SlowSubscriber takes a while per message; it must not hold up anyone else.
+type SlowSubscriber struct {
+	Id    string
+	Delay time.Duration
+	wg    *sync.WaitGroup
 }
 
-func NewPubSubService() *PubSubService {
-	return &PubSubService{
-		subs: make(map[string]map[string]Subscriber),
-	}
+func (ss *SlowSubscriber) Consume(msg pubsub.Message) {
+	time.Sleep(ss.Delay)
+	fmt.Printf("Slow subscriber %s finished %s\n", ss.Id, msg.Payload)
+	msg.Ack()
+	ss.wg.Done()
 }
 
-func (ps *PubSubService) Publish(msg Message) {
-	ps.lock.Lock()
-	defer ps.lock.Unlock()
-
-	subs, exist := ps.subs[msg.Topic]
-	if !exist {
-		fmt.Printf("No subscribers for topic: %s\n", msg.Topic)
-		return
-	}
-	for _, sub := range subs {
-		sub.Consume(msg)
-	}
+func (ss *SlowSubscriber) GetId() string {
+	return ss.Id
 }
 
-func (ps *PubSubService) AddSubscriber(topic string, sub Subscriber) {
-	ps.lock.Lock()
-	defer ps.lock.Unlock()
-
-	if _, exists := ps.subs[topic]; !exists {
-		ps.subs[topic] = make(map[string]Subscriber)
-	}
-
-	ps.subs[topic][sub.GetId()] = sub
-	fmt.Printf("Subscriber %s added to topic %s\n", sub.GetId(), topic)
+// FlakySubscriber never acks, so its messages end up in the dead-letter topic.
+type FlakySubscriber struct {
+	Id string
 }
 
-func (ps *PubSubService) RemoveSubscriber(topic string, subID string) {
-	ps.lock.Lock()
-	defer ps.lock.Unlock()
+func (fs *FlakySubscriber) Consume(msg pubsub.Message) {
+	fmt.Printf("Subscriber %s failed %s (attempt %d)\n", fs.Id, msg.Payload, msg.Attempt)
+	msg.Nack()
+}
 
-	if _, exists := ps.subs[topic]; exists {
-		delete(ps.subs[topic], subID)
-		fmt.Printf("Subscriber %s removed from topic %s\n", subID, topic)
-		if len(ps.subs[topic]) == 0 {
-			delete(ps.subs, topic)
-		}
-	}
+func (fs *FlakySubscriber) GetId() string {
+	return fs.Id
 }
 
 func main() {
-	pubSub := NewPubSubService()
-	sub1 := &ConcreteSubscriber{
-		Id: "id1",
-	}
-	sub2 := &ConcreteSubscriber{
-		Id: "id2",
+	dir, err := os.MkdirTemp("", "pubsub")
+	if err != nil {
+		panic(err)
 	}
+	defer os.RemoveAll(dir)
 
+	pubSub := pubsub.NewPubSubService(pubsub.Config{
+		Dir:           dir,
+		AckTimeout:    200 * time.Millisecond,
+		MaxDeliveries: 3,
+	})
+
+	var wg, slowWg sync.WaitGroup
+	sub1 := &ConcreteSubscriber{Id: "id1", wg: &wg}
+	slow := &SlowSubscriber{Id: "slow", Delay: 150 * time.Millisecond, wg: &slowWg}
 	pubSub.AddSubscriber("topic1", sub1)
-	pubSub.AddSubscriber("topic1", sub2)
-	pubSub.AddSubscriber("topic2", sub1)
+	pubSub.AddSubscriber("topic1", slow)
+
+	wg.Add(3)
+	slowWg.Add(3)
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		pubSub.Publish(pubsub.Message{Topic: "topic1", Payload: fmt.Sprintf("Hello Topic 1 #%d", i)})
+	}
+	fmt.Println("published without waiting:", time.Since(start) < 100*time.Millisecond) // true
+	wg.Wait()
+	fmt.Println("fast subscriber done before slow one:", time.Since(start) < 150*time.Millisecond) // true
+
+	// A late subscriber can replay the topic from the start.
+	wg.Add(3)
+	pubSub.Subscribe("topic1", &ConcreteSubscriber{Id: "replay", wg: &wg}, pubsub.Earliest)
+	wg.Wait()
+
+	// Unacked messages are redelivered, then dead-lettered.
+	dead := make(chan pubsub.Message, 1)
+	pubSub.Subscribe("$dlq.topic2", subscriberFunc("dlq", func(msg pubsub.Message) {
+		msg.Ack()
+		dead <- msg
+	}), pubsub.Earliest)
+	pubSub.AddSubscriber("topic2", &FlakySubscriber{Id: "flaky"})
+	pubSub.Publish(pubsub.Message{Topic: "topic2", Payload: "poison"})
+	fmt.Println("dead-lettered:", (<-dead).Payload) // poison
+	slowWg.Wait()
+
+	if err := pubSub.Close(); err != nil {
+		panic(err)
+	}
+
+	// The log survives a restart.
+	reopened := pubsub.NewPubSubService(pubsub.Config{Dir: dir})
+	defer reopened.Close()
+	wg.Add(3)
+	reopened.Subscribe("topic1", &ConcreteSubscriber{Id: "after-restart", wg: &wg}, pubsub.Earliest)
+	wg.Wait()
 
-	pubSub.Publish(Message{Topic: "topic1", Payload: "Hello Topic 1"})
-	pubSub.Publish(Message{Topic: "topic2", Payload: "Hello Topic 2"})
-	pubSub.Publish(Message{Topic: "topic3", Payload: "No Subscribers Here"})
+	groupDemo(dir)
+	wildcardDemo()
+}
 
-	pubSub.RemoveSubscriber("topic1", "sub2")
-	pubSub.Publish(Message{Topic: "topic1", Payload: "Hello Topic 1 after removal"})
+type funcSubscriber struct {
+	id string
+	fn func(pubsub.Message)
 }
 
+func subscriberFunc(id string, fn func(pubsub.Message)) pubsub.Subscriber {
+	return &funcSubscriber{id: id, fn: fn}
+}
+
+func (f *funcSubscriber) Consume(msg pubsub.Message) { f.fn(msg) }
+
+func (f *funcSubscriber) GetId() string { return f.id }
+
 //
 //import (
 //	"fmt"
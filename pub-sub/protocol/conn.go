@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WriteTimeout bounds a single frame write so a peer that stopped reading
+// cannot block the writer forever.
+const WriteTimeout = 10 * time.Second
+
+// Conn reads and writes frames. ReadFrame must only be called from one
+// goroutine; WriteFrame is safe for concurrent use.
+type Conn interface {
+	ReadFrame() (Frame, error)
+	WriteFrame(f Frame) error
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+type streamConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// NewStreamConn frames messages over a byte stream such as a TCP connection.
+func NewStreamConn(conn net.Conn) Conn {
+	return &streamConn{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *streamConn) ReadFrame() (Frame, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return Frame{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return Frame{}, ErrFrameTooLarge
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return Frame{}, err
+	}
+	var f Frame
+	err := json.Unmarshal(body, &f)
+	return f, err
+}
+
+func (c *streamConn) WriteFrame(f Frame) error {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	copy(buf[4:], body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	_, err = c.conn.Write(buf)
+	return err
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *streamConn) Close() error {
+	return c.conn.Close()
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// NewWebSocketConn sends each frame as one WebSocket text message.
+func NewWebSocketConn(conn *websocket.Conn) Conn {
+	conn.SetReadLimit(MaxFrameSize)
+	return &wsConn{conn: conn}
+}
+
+func (c *wsConn) ReadFrame() (Frame, error) {
+	var f Frame
+	err := c.conn.ReadJSON(&f)
+	return f, err
+}
+
+func (c *wsConn) WriteFrame(f Frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return c.conn.WriteJSON(f)
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,69 @@
+// Package protocol is the wire format spoken between the pub-sub broker and
+// its clients. Every frame is a JSON object; over TCP each one is prefixed
+// with its 4-byte big-endian length, over WebSocket each one is a single
+// text message.
+package protocol
+
+import "errors"
+
+type FrameType string
+
+const (
+	// Client to server.
+	Publish     FrameType = "PUBLISH"
+	Subscribe   FrameType = "SUBSCRIBE"
+	Unsubscribe FrameType = "UNSUBSCRIBE"
+	Ack         FrameType = "ACK"
+	Nack        FrameType = "NACK"
+
+	// Server to client.
+	Message FrameType = "MESSAGE"
+	OK      FrameType = "OK"
+	Error   FrameType = "ERROR"
+
+	// Either direction; a PING is answered with a PONG.
+	Ping FrameType = "PING"
+	Pong FrameType = "PONG"
+)
+
+// MaxFrameSize bounds a single frame so a bad peer cannot make the other
+// side allocate without limit.
+const MaxFrameSize = 4 << 20
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// Frame carries every message of the protocol; which fields are set depends
+// on Type. Requests carry an Id the server echoes in its OK or ERROR reply.
+type Frame struct {
+	Type FrameType `json:"type"`
+	Id   uint64    `json:"id,omitempty"`
+
+	// Subscription names a client's subscription; the client picks it and
+	// reuses it when resubscribing after a reconnect.
+	Subscription string `json:"subscription,omitempty"`
+	// Topic is the topic of PUBLISH and MESSAGE and the pattern of
+	// SUBSCRIBE and UNSUBSCRIBE.
+	Topic  string `json:"topic,omitempty"`
+	Filter string `json:"filter,omitempty"`
+	Group  string `json:"group,omitempty"`
+	From   int64  `json:"from,omitempty"`
+	// Positions on SUBSCRIBE overrides From for the partitions it lists; on
+	// the OK reply it says where each partition matching so far starts.
+	Positions []Position `json:"positions,omitempty"`
+
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Payload   string            `json:"payload,omitempty"`
+	Partition int               `json:"partition,omitempty"`
+	Offset    int64             `json:"offset,omitempty"`
+	Attempt   int               `json:"attempt,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Position is the next offset to read in one partition of a topic.
+type Position struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
@@ -0,0 +1,40 @@
+package pubsub
+
+import "context"
+
+// Broker is the delivery contract shared by PubSubService and the Redis
+// Streams broker in redis-pub-sub. Consume delivers the topic to sub until
+// ctx is cancelled or the broker is closed. Subscribers in the same group
+// share the topic's messages; an empty group gets every message.
+type Broker interface {
+	Publish(msg Message) (Message, error)
+	Consume(ctx context.Context, topic, group string, sub Subscriber) error
+	Close() error
+}
+
+var _ Broker = (*PubSubService)(nil)
+
+// Consume joins the group, or subscribes from the end of the topic when
+// group is empty, and leaves again once ctx is done.
+func (ps *PubSubService) Consume(ctx context.Context, topic, group string, sub Subscriber) error {
+	var err error
+	if group == "" {
+		err = ps.AddSubscriber(topic, sub)
+	} else {
+		err = ps.JoinGroup(topic, group, sub)
+	}
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-ps.done:
+	}
+	if group == "" {
+		ps.RemoveSubscriber(topic, sub.GetId())
+	} else {
+		ps.LeaveGroup(topic, group, sub.GetId())
+	}
+	return nil
+}
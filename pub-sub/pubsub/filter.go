@@ -0,0 +1,351 @@
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter decides from a message's headers whether a subscriber wants it.
+type Filter interface {
+	Match(headers map[string]string) bool
+}
+
+// ParseFilter compiles an SQL-like expression over message headers, e.g.
+//
+//	region = 'eu' AND (priority >= 5 OR customer IN ('acme', 'globex'))
+//
+// Supported are =, != (or <>), <, <=, >, >=, IN, AND, OR, NOT and
+// parentheses. Values are quoted strings or numbers; two values that both
+// parse as numbers are ordered numerically. A comparison against a header
+// the message does not carry is false.
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("filter: unexpected %q", p.peek().text)
+	}
+	return f, nil
+}
+
+type andFilter []Filter
+
+func (f andFilter) Match(headers map[string]string) bool {
+	for _, sub := range f {
+		if !sub.Match(headers) {
+			return false
+		}
+	}
+	return true
+}
+
+type orFilter []Filter
+
+func (f orFilter) Match(headers map[string]string) bool {
+	for _, sub := range f {
+		if sub.Match(headers) {
+			return true
+		}
+	}
+	return false
+}
+
+type notFilter struct{ f Filter }
+
+func (f notFilter) Match(headers map[string]string) bool {
+	return !f.f.Match(headers)
+}
+
+type compareFilter struct {
+	header string
+	op     string
+	value  string
+}
+
+func (f compareFilter) Match(headers map[string]string) bool {
+	actual, ok := headers[f.header]
+	if !ok {
+		return false
+	}
+	c := compare(actual, f.value)
+	switch f.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type inFilter struct {
+	header string
+	values []string
+}
+
+func (f inFilter) Match(headers map[string]string) bool {
+	actual, ok := headers[f.header]
+	if !ok {
+		return false
+	}
+	for _, v := range f.values {
+		if compare(actual, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")"})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ","})
+			i++
+		case c == '\'':
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(expr) {
+					return nil, fmt.Errorf("filter: unterminated string")
+				}
+				if expr[i] == '\'' {
+					// '' is an escaped quote.
+					if i+1 < len(expr) && expr[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(expr[i])
+				i++
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+		case strings.ContainsRune("=!<>", c):
+			op := string(c)
+			if i+1 < len(expr) {
+				switch expr[i : i+2] {
+				case "<=", ">=", "!=", "<>":
+					op = expr[i : i+2]
+				}
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("filter: unexpected '!'")
+			}
+			if op == "<>" {
+				tokens = append(tokens, token{tokOp, "!="})
+			} else {
+				tokens = append(tokens, token{tokOp, op})
+			}
+			i += len(op)
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(expr) && (unicode.IsDigit(rune(expr[j])) || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, expr[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || expr[j] == '-' || expr[j] == '.' ||
+				unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, expr[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("filter: unexpected %q", c)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	filters := orFilter{f}
+	for p.keyword("OR") {
+		f, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return filters, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	filters := andFilter{f}
+	for p.keyword("AND") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return filters, nil
+}
+
+func (p *parser) unary() (Filter, error) {
+	if p.keyword("NOT") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("filter: missing ')'")
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Filter, error) {
+	header := p.next()
+	if header.kind != tokIdent {
+		return nil, fmt.Errorf("filter: expected header name, got %q", header.text)
+	}
+	if p.keyword("IN") {
+		if p.next().kind != tokLParen {
+			return nil, fmt.Errorf("filter: expected '(' after IN")
+		}
+		f := inFilter{header: header.text}
+		for {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			f.values = append(f.values, v)
+			t := p.next()
+			if t.kind == tokRParen {
+				return f, nil
+			}
+			if t.kind != tokComma {
+				return nil, fmt.Errorf("filter: expected ',' or ')' in IN list")
+			}
+		}
+	}
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, fmt.Errorf("filter: expected operator after %s", header.text)
+	}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return compareFilter{header: header.text, op: op.text, value: v}, nil
+}
+
+func (p *parser) value() (string, error) {
+	t := p.next()
+	if t.kind != tokString && t.kind != tokNumber {
+		return "", fmt.Errorf("filter: expected a value, got %q", t.text)
+	}
+	return t.text, nil
+}
@@ -0,0 +1,159 @@
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// consumerGroup shares a topic's partitions between its members: every
+// partition is owned by exactly one member, which reads it from the group's
+// committed offset.
+//
+// Each handover bumps the partition's generation, and a subscription only
+// commits while the generation it was started with is current, so late acks
+// from a revoked owner cannot move the new owner's offset. commitMu orders
+// those commits against the bump; it is separate from mu because rebalancing
+// waits for the old owner's worker, which may be committing.
+type consumerGroup struct {
+	name        string
+	topic       *topic
+	offsets     *offsetStore
+	members     map[string]Subscriber
+	owners      []string
+	owned       []*subscription
+	generations []uint64
+	mu          sync.Mutex
+	commitMu    sync.Mutex
+}
+
+func newConsumerGroup(name string, t *topic, offsets *offsetStore) *consumerGroup {
+	return &consumerGroup{
+		name:        name,
+		topic:       t,
+		offsets:     offsets,
+		members:     make(map[string]Subscriber),
+		owners:      make([]string, len(t.partitions)),
+		owned:       make([]*subscription, len(t.partitions)),
+		generations: make([]uint64, len(t.partitions)),
+	}
+}
+
+func (g *consumerGroup) join(service *PubSubService, sub Subscriber) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// A member re-joining under its id may be a new Subscriber, so its
+	// partitions are restarted rather than left delivering to the old one.
+	id := sub.GetId()
+	if _, ok := g.members[id]; ok {
+		for p, owner := range g.owners {
+			if owner == id && g.owned[p] != nil {
+				g.revoke(p)
+				g.owned[p].close()
+				g.owned[p] = nil
+			}
+		}
+	}
+	g.members[id] = sub
+	g.rebalanceLocked(service)
+}
+
+func (g *consumerGroup) leave(service *PubSubService, subID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.members[subID]; !ok {
+		return false
+	}
+	delete(g.members, subID)
+	g.rebalanceLocked(service)
+	return true
+}
+
+// rebalanceLocked deals partitions out round-robin over the members sorted
+// by id. Only partitions that change owner are stopped and restarted; the
+// new owner picks up from the committed offset, so messages the old owner
+// had not acked yet are delivered again.
+func (g *consumerGroup) rebalanceLocked(service *PubSubService) {
+	ids := make([]string, 0, len(g.members))
+	for id := range g.members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for p := range g.owners {
+		owner := ""
+		if len(ids) > 0 {
+			owner = ids[p%len(ids)]
+		}
+		if owner == g.owners[p] && g.owned[p] != nil {
+			continue
+		}
+		generation := g.revoke(p)
+		if g.owned[p] != nil {
+			g.owned[p].close()
+			g.owned[p] = nil
+		}
+		g.owners[p] = owner
+		if owner == "" {
+			continue
+		}
+		partition := p
+		g.owned[p] = newSubscription(service, g.topic.partitions[p], g.members[owner], g.offsets.Get(p), nil, func(offset int64) {
+			g.commit(partition, generation, offset)
+		})
+	}
+}
+
+// revoke starts a new generation for the partition, after which commits
+// from its current owner are dropped, and returns it.
+func (g *consumerGroup) revoke(partition int) uint64 {
+	g.commitMu.Lock()
+	defer g.commitMu.Unlock()
+
+	g.generations[partition]++
+	return g.generations[partition]
+}
+
+func (g *consumerGroup) commit(partition int, generation uint64, offset int64) {
+	g.commitMu.Lock()
+	defer g.commitMu.Unlock()
+
+	if g.generations[partition] != generation {
+		return
+	}
+	if err := g.offsets.Commit(partition, offset); err != nil {
+		fmt.Printf("Failed to commit %s/%s partition %d: %v\n", g.topic.name, g.name, partition, err)
+	}
+}
+
+// assignment maps each member to the partitions it owns.
+func (g *consumerGroup) assignment() map[string][]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	assigned := make(map[string][]int, len(g.members))
+	for id := range g.members {
+		assigned[id] = nil
+	}
+	for p, owner := range g.owners {
+		if owner != "" {
+			assigned[owner] = append(assigned[owner], p)
+		}
+	}
+	return assigned
+}
+
+func (g *consumerGroup) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for p, s := range g.owned {
+		if s != nil {
+			g.revoke(p)
+			s.close()
+			g.owned[p] = nil
+		}
+	}
+}
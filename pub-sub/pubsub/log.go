@@ -0,0 +1,314 @@
+package pubsub
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxRecordSize bounds the encoded size of one message in a topic log.
+const MaxRecordSize = 16 << 20
+
+var (
+	ErrOffsetOutOfRange = errors.New("offset out of range")
+	ErrMessageTooLarge  = fmt.Errorf("message is larger than %d bytes once encoded", MaxRecordSize)
+)
+
+// SyncPolicy says when a topic log is fsynced. Until then an appended
+// message survives a crash of the process but not of the machine.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs before every Publish returns. It is the default.
+	SyncAlways SyncPolicy = iota
+	// SyncBatch fsyncs once every SyncBatchSize appends.
+	SyncBatch
+	// SyncInterval fsyncs in the background every SyncInterval while there
+	// are unsynced appends.
+	SyncInterval
+)
+
+// topicLog is the append-only sequence of messages of one topic partition.
+// Each record is framed as a 4-byte length, a 4-byte CRC32 of the body and
+// the JSON-encoded message. The offset of a record is its index in the log.
+// With no directory the log only lives in memory.
+type topicLog struct {
+	file      *os.File
+	positions []int64
+	size      int64
+	memory    []Message
+	watchers  map[*subscription]struct{}
+	policy    SyncPolicy
+	batchSize int
+	unsynced  int
+	stop      chan struct{}
+	stopped   chan struct{}
+	mu        sync.RWMutex
+}
+
+func openTopicLog(cfg Config, topic string, partition int) (*topicLog, error) {
+	l := &topicLog{
+		watchers:  make(map[*subscription]struct{}),
+		policy:    cfg.Sync,
+		batchSize: cfg.SyncBatchSize,
+	}
+	if cfg.Dir == "" {
+		return l, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(cfg.Dir, fmt.Sprintf("%s-%d.log", url.PathEscape(topic), partition))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	dropped, err := l.recover()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if dropped > 0 {
+		log.Printf("pubsub: %s: dropped %d trailing bytes that did not hold intact records; the log resumes at offset %d", path, dropped, len(l.positions))
+	}
+	if l.policy == SyncInterval {
+		l.stop = make(chan struct{})
+		l.stopped = make(chan struct{})
+		go l.syncEvery(cfg.SyncInterval)
+	}
+	return l, nil
+}
+
+// recover indexes every intact record and truncates the log after the last
+// one, returning how many bytes were dropped. Usually that is a record torn
+// by a crash in the middle of an append, but a corrupt record further back
+// loses every record after it too, since the framing can no longer be
+// trusted.
+func (l *topicLog) recover() (dropped int64, err error) {
+	info, err := l.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(l.file)
+	var pos int64
+	for {
+		body, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		l.positions = append(l.positions, pos)
+		pos += int64(8 + len(body))
+	}
+	l.size = pos
+	if pos == info.Size() {
+		return 0, nil
+	}
+	return info.Size() - pos, l.file.Truncate(pos)
+}
+
+// Append stores the message at the next offset, syncs the file as the
+// log's SyncPolicy asks, and wakes every subscription reading this log.
+func (l *topicLog) Append(msg Message) (Message, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	msg.Offset = int64(len(l.positions) + len(l.memory))
+	if l.file == nil {
+		l.memory = append(l.memory, msg)
+		l.notifyLocked()
+		return msg, nil
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return msg, err
+	}
+	if len(body) > MaxRecordSize {
+		return msg, ErrMessageTooLarge
+	}
+	record := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+	copy(record[8:], body)
+	if _, err := l.file.WriteAt(record, l.size); err != nil {
+		return msg, err
+	}
+	l.positions = append(l.positions, l.size)
+	l.size += int64(len(record))
+	l.unsynced++
+	if l.policy == SyncAlways || (l.policy == SyncBatch && l.unsynced >= l.batchSize) {
+		if err := l.syncLocked(); err != nil {
+			return msg, err
+		}
+	}
+	l.notifyLocked()
+	return msg, nil
+}
+
+func (l *topicLog) syncLocked() error {
+	if l.unsynced == 0 {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.unsynced = 0
+	return nil
+}
+
+func (l *topicLog) syncEvery(interval time.Duration) {
+	defer close(l.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			err := l.syncLocked()
+			l.mu.Unlock()
+			if err != nil {
+				log.Printf("pubsub: sync %s: %v", l.file.Name(), err)
+			}
+		}
+	}
+}
+
+func (l *topicLog) notifyLocked() {
+	for s := range l.watchers {
+		s.notify()
+	}
+}
+
+func (l *topicLog) watch(s *subscription) {
+	l.mu.Lock()
+	l.watchers[s] = struct{}{}
+	l.mu.Unlock()
+}
+
+func (l *topicLog) unwatch(s *subscription) {
+	l.mu.Lock()
+	delete(l.watchers, s)
+	l.mu.Unlock()
+}
+
+func (l *topicLog) Read(offset int64) (Message, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.file == nil {
+		if offset < 0 || offset >= int64(len(l.memory)) {
+			return Message{}, ErrOffsetOutOfRange
+		}
+		return l.memory[offset], nil
+	}
+	if offset < 0 || offset >= int64(len(l.positions)) {
+		return Message{}, ErrOffsetOutOfRange
+	}
+	body, err := readRecord(io.NewSectionReader(l.file, l.positions[offset], l.size-l.positions[offset]))
+	if err != nil {
+		return Message{}, err
+	}
+	var msg Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+// Len is the offset the next appended message will get.
+func (l *topicLog) Len() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return int64(len(l.positions) + len(l.memory))
+}
+
+func (l *topicLog) Sync() error {
+	if l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.syncLocked()
+}
+
+func (l *topicLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	if l.stop != nil {
+		close(l.stop)
+		<-l.stopped
+	}
+	return l.file.Close()
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[0:4])
+	if n > MaxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes is over the %d byte limit", n, MaxRecordSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("corrupt record")
+	}
+	return body, nil
+}
+
+// listTopicLogs finds the topics that already have logs in dir and how many
+// partitions each has.
+func listTopicLogs(dir string) (map[string]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	topics := make(map[string]int)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".log")
+		dash := strings.LastIndexByte(name, '-')
+		if e.IsDir() || name == e.Name() || dash < 0 {
+			continue
+		}
+		partition, err := strconv.Atoi(name[dash+1:])
+		if err != nil {
+			continue
+		}
+		topic, err := url.PathUnescape(name[:dash])
+		if err != nil {
+			continue
+		}
+		if partition+1 > topics[topic] {
+			topics[topic] = partition + 1
+		}
+	}
+	return topics, nil
+}
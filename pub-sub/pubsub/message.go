@@ -0,0 +1,53 @@
+package pubsub
+
+import "time"
+
+type Message struct {
+	Topic   string `json:"topic"`
+	Payload string `json:"payload"`
+	// Key picks the partition: messages with the same key land in the same
+	// partition and keep their order. Messages without a key are spread
+	// round-robin.
+	Key string `json:"key,omitempty"`
+	// Headers carry attributes subscribers can filter on without decoding
+	// the payload.
+	Headers   map[string]string `json:"headers,omitempty"`
+	Partition int               `json:"partition"`
+	// ID is set by brokers that identify messages by something other than
+	// partition and offset, such as a Redis stream entry ID.
+	ID        string    `json:"id,omitempty"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+	// Attempt counts deliveries of this message to the current subscriber,
+	// starting at 1.
+	Attempt int `json:"-"`
+
+	acker Acker
+}
+
+// Acker settles delivered messages on behalf of the broker that delivered
+// them. Brokers attach one with WithAcker.
+type Acker interface {
+	Ack(msg Message)
+	Nack(msg Message)
+}
+
+// WithAcker returns a copy of the message that Ack and Nack through a.
+func (m Message) WithAcker(a Acker) Message {
+	m.acker = a
+	return m
+}
+
+// Ack marks the message as processed so it is not redelivered.
+func (m Message) Ack() {
+	if m.acker != nil {
+		m.acker.Ack(m)
+	}
+}
+
+// Nack asks for the message to be redelivered right away.
+func (m Message) Nack() {
+	if m.acker != nil {
+		m.acker.Nack(m)
+	}
+}
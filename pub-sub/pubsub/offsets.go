@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// offsetStore keeps a consumer group's committed offset per partition. With
+// a directory it is saved as JSON next to the topic logs, so a restarted
+// group resumes where it left off. Topic and group are joined by a comma,
+// which PathEscape always encodes, so no two pairs share a file.
+type offsetStore struct {
+	path    string
+	offsets map[int]int64
+	mu      sync.Mutex
+}
+
+func openOffsetStore(dir, topic, group string) (*offsetStore, error) {
+	o := &offsetStore{offsets: make(map[int]int64)}
+	if dir == "" {
+		return o, nil
+	}
+	o.path = filepath.Join(dir, fmt.Sprintf("%s,%s.offsets", url.PathEscape(topic), url.PathEscape(group)))
+	data, err := os.ReadFile(o.path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &o.offsets); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *offsetStore) Get(partition int) int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.offsets[partition]
+}
+
+// Commit records offset for the partition. Commits never move backwards.
+func (o *offsetStore) Commit(partition int, offset int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if offset <= o.offsets[partition] {
+		return nil
+	}
+	o.offsets[partition] = offset
+	if o.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(o.offsets)
+	if err != nil {
+		return err
+	}
+	return writeFileSynced(o.path, data)
+}
+
+// writeFileSynced replaces path with data by way of a synced temporary file
+// and syncs the directory after the rename, so a crash leaves either the
+// old contents or the new ones.
+func writeFileSynced(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (o *offsetStore) Snapshot(partitions int) []int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	offsets := make([]int64, partitions)
+	for p := range offsets {
+		offsets[p] = o.offsets[p]
+	}
+	return offsets
+}
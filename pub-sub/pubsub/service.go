@@ -0,0 +1,448 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Earliest and Latest are start positions for Subscribe.
+	Earliest int64 = 0
+	Latest   int64 = -1
+)
+
+var ErrPartitionMismatch = errors.New("topic already exists with a different partition count")
+
+// Subscriber receives messages. Removing a subscription waits for a Consume
+// call in progress on it to return, so Consume must not itself call
+// RemoveSubscriber, JoinGroup or LeaveGroup, which can stop the very
+// subscription delivering to it and deadlock. Hand such calls to another
+// goroutine instead.
+type Subscriber interface {
+	Consume(msg Message)
+	GetId() string
+}
+
+type Publisher interface {
+	Publish(msg Message) (Message, error)
+	AddSubscriber(topic string, sub Subscriber) error
+	RemoveSubscriber(topic string, subId string)
+}
+
+type Config struct {
+	// Dir holds one append-only log file per topic partition plus the
+	// committed offsets of each consumer group; empty keeps everything in
+	// memory only.
+	Dir string
+	// Partitions is the partition count of topics created on first use;
+	// topics already on disk keep the count they were created with.
+	Partitions int
+	// QueueSize bounds each subscriber's delivery queue and MaxInFlight the
+	// number of delivered but unacknowledged messages per subscriber and
+	// partition.
+	QueueSize   int
+	MaxInFlight int
+	// A message not acked within AckTimeout is redelivered, and after
+	// MaxDeliveries attempts it is moved to its dead-letter topic, the
+	// original topic behind DeadLetterPrefix.
+	AckTimeout       time.Duration
+	MaxDeliveries    int
+	DeadLetterPrefix string
+	// Sync says when topic logs are fsynced: SyncAlways (the default) before
+	// every Publish returns, SyncBatch every SyncBatchSize appends or
+	// SyncInterval every SyncInterval. Logs are always synced on Close.
+	Sync          SyncPolicy
+	SyncBatchSize int
+	SyncInterval  time.Duration
+}
+
+// PubSubService stores every published message in a partitioned per-topic
+// log and delivers it asynchronously to each subscriber whose pattern
+// matches the topic and to one member of each consumer group on it.
+type PubSubService struct {
+	cfg      Config
+	topics   map[string]*topic
+	patterns *topicTrie
+	done     chan struct{}
+	once     sync.Once
+	lock     sync.RWMutex
+}
+
+func NewPubSubService(cfg Config) *PubSubService {
+	if cfg.Partitions <= 0 {
+		cfg.Partitions = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 64
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = cfg.QueueSize
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = 30 * time.Second
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 5
+	}
+	if cfg.DeadLetterPrefix == "" {
+		cfg.DeadLetterPrefix = "$dlq."
+	}
+	if cfg.SyncBatchSize <= 0 {
+		cfg.SyncBatchSize = 100
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+	return &PubSubService{
+		cfg:      cfg,
+		topics:   make(map[string]*topic),
+		patterns: newTopicTrie(),
+		done:     make(chan struct{}),
+	}
+}
+
+// CreateTopic opens a topic with its own partition count instead of the
+// configured default.
+func (ps *PubSubService) CreateTopic(name string, partitions int) error {
+	if partitions <= 0 {
+		partitions = ps.cfg.Partitions
+	}
+	t, err := ps.topic(name, partitions)
+	if err != nil {
+		return err
+	}
+	if len(t.partitions) != partitions {
+		return ErrPartitionMismatch
+	}
+	return nil
+}
+
+// Publish appends the message to the partition its key maps to and returns
+// it with its partition and offset filled in. It never waits for
+// subscribers.
+func (ps *PubSubService) Publish(msg Message) (Message, error) {
+	if err := validateTopic(msg.Topic); err != nil {
+		return msg, err
+	}
+	t, err := ps.topic(msg.Topic, ps.cfg.Partitions)
+	if err != nil {
+		return msg, err
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg.Partition = t.partition(msg.Key)
+	return t.partitions[msg.Partition].Append(msg)
+}
+
+// AddSubscriber subscribes from the end of the topic, so only messages
+// published from now on are delivered.
+func (ps *PubSubService) AddSubscriber(topic string, sub Subscriber) error {
+	return ps.Subscribe(topic, sub, Latest)
+}
+
+// Subscribe starts delivering every partition of every topic matching the
+// pattern to sub from the given offset; Earliest replays the whole log and
+// Latest starts at its end. Topics created later that match the pattern
+// are delivered from their beginning. Partitions and topics are delivered
+// concurrently, so Consume must be safe to call from several goroutines.
+func (ps *PubSubService) Subscribe(pattern string, sub Subscriber, from int64) error {
+	return ps.SubscribeWhere(pattern, "", sub, from)
+}
+
+// SubscribeWhere is Subscribe restricted to messages whose headers match
+// the filter expression (see ParseFilter). An empty filter matches all.
+func (ps *PubSubService) SubscribeWhere(pattern, filter string, sub Subscriber, from int64) error {
+	_, err := ps.SubscribeAt(pattern, filter, sub, from, nil)
+	return err
+}
+
+// Position is an offset in one partition of a topic.
+type Position struct {
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// SubscribeAt is SubscribeWhere with a start offset per partition: those
+// listed in resume start there, the rest at from. It returns where every
+// partition of the topics matching now starts, so a caller that keeps these
+// moving as messages arrive can subscribe again later without a gap.
+func (ps *PubSubService) SubscribeAt(pattern, filter string, sub Subscriber, from int64, resume []Position) ([]Position, error) {
+	levels, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var f Filter
+	if filter != "" {
+		if f, err = ParseFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	if err := ps.discover(levels); err != nil {
+		return nil, err
+	}
+
+	entry := &patternSub{
+		key:    subKey{pattern: strings.Join(levels, levelSeparator), id: sub.GetId()},
+		sub:    sub,
+		filter: f,
+		from:   from,
+	}
+	if len(resume) > 0 {
+		entry.resume = make(map[partitionKey]int64, len(resume))
+		for _, pos := range resume {
+			entry.resume[partitionKey{pos.Topic, pos.Partition}] = pos.Offset
+		}
+	}
+	ps.lock.Lock()
+	var old []*subscription
+	var starts []Position
+	ps.patterns.Insert(levels, entry)
+	for name, t := range ps.topics {
+		if matchPattern(levels, name) {
+			old = append(old, t.subs[entry.key]...)
+			starts = append(starts, ps.attachLocked(t, entry)...)
+		}
+	}
+	ps.lock.Unlock()
+
+	for _, s := range old {
+		s.close()
+	}
+	fmt.Printf("Subscriber %s added to topic %s\n", sub.GetId(), pattern)
+	return starts, nil
+}
+
+// discover opens the topics a subscription will match: the topic itself
+// for an exact pattern, and every topic with a log on disk for a wildcard
+// one, so that Earliest can replay them.
+func (ps *PubSubService) discover(levels []string) error {
+	if !isPattern(levels) {
+		_, err := ps.topic(strings.Join(levels, levelSeparator), ps.cfg.Partitions)
+		return err
+	}
+	if ps.cfg.Dir == "" {
+		return nil
+	}
+	onDisk, err := listTopicLogs(ps.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for name := range onDisk {
+		if matchPattern(levels, name) {
+			if _, err := ps.topic(name, ps.cfg.Partitions); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// attachLocked starts delivering every partition of the topic for entry and
+// returns where each one starts.
+func (ps *PubSubService) attachLocked(t *topic, entry *patternSub) []Position {
+	subs := make([]*subscription, len(t.partitions))
+	starts := make([]Position, len(t.partitions))
+	for p, log := range t.partitions {
+		start := entry.from
+		if offset, ok := entry.resume[partitionKey{t.name, p}]; ok {
+			start = offset
+		}
+		if start == Latest || start > log.Len() {
+			start = log.Len()
+		}
+		subs[p] = newSubscription(ps, log, entry.sub, start, entry.filter, nil)
+		starts[p] = Position{Topic: t.name, Partition: p, Offset: start}
+	}
+	t.subs[entry.key] = subs
+	return starts
+}
+
+// RemoveSubscriber undoes Subscribe; pattern must be the one subscribed.
+// It waits for deliveries in progress, so it must not be called from
+// Subscriber.Consume.
+func (ps *PubSubService) RemoveSubscriber(pattern string, subID string) {
+	levels, err := parsePattern(pattern)
+	if err != nil {
+		return
+	}
+	key := subKey{pattern: strings.Join(levels, levelSeparator), id: subID}
+
+	ps.lock.Lock()
+	removed := ps.patterns.Remove(levels, key)
+	var subs []*subscription
+	for _, t := range ps.topics {
+		subs = append(subs, t.subs[key]...)
+		delete(t.subs, key)
+	}
+	ps.lock.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+	if removed != nil {
+		fmt.Printf("Subscriber %s removed from topic %s\n", subID, pattern)
+	}
+}
+
+// JoinGroup adds sub to the consumer group and rebalances the topic's
+// partitions over the group's members. Rebalancing waits for deliveries in
+// progress on the partitions that move, so it must not be called from
+// Subscriber.Consume; neither may LeaveGroup.
+func (ps *PubSubService) JoinGroup(topic, group string, sub Subscriber) error {
+	g, err := ps.group(topic, group)
+	if err != nil {
+		return err
+	}
+	g.join(ps, sub)
+	fmt.Printf("Subscriber %s joined group %s on topic %s\n", sub.GetId(), group, topic)
+	return nil
+}
+
+// LeaveGroup removes the member and hands its partitions to the others.
+func (ps *PubSubService) LeaveGroup(topic, group, subID string) {
+	ps.lock.RLock()
+	var g *consumerGroup
+	if t, exists := ps.topics[topic]; exists {
+		g = t.groups[group]
+	}
+	ps.lock.RUnlock()
+
+	if g != nil && g.leave(ps, subID) {
+		fmt.Printf("Subscriber %s left group %s on topic %s\n", subID, group, topic)
+	}
+}
+
+// Assignment reports which partitions each member of the group owns.
+func (ps *PubSubService) Assignment(topic, group string) map[string][]int {
+	g, err := ps.group(topic, group)
+	if err != nil {
+		return nil
+	}
+	return g.assignment()
+}
+
+// Committed returns the group's committed offset for every partition: the
+// offset its next owner will start reading from.
+func (ps *PubSubService) Committed(topic, group string) []int64 {
+	g, err := ps.group(topic, group)
+	if err != nil {
+		return nil
+	}
+	return g.offsets.Snapshot(len(g.topic.partitions))
+}
+
+// Close stops every subscription and group and flushes and closes the
+// topic logs.
+func (ps *PubSubService) Close() error {
+	ps.once.Do(func() { close(ps.done) })
+	ps.lock.Lock()
+	var subs []*subscription
+	var groups []*consumerGroup
+	for _, t := range ps.topics {
+		for _, s := range t.subs {
+			subs = append(subs, s...)
+		}
+		for _, g := range t.groups {
+			groups = append(groups, g)
+		}
+		t.subs = make(map[subKey][]*subscription)
+	}
+	ps.lock.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+	for _, g := range groups {
+		g.close()
+	}
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	var firstErr error
+	for _, t := range ps.topics {
+		if err := t.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (ps *PubSubService) topic(name string, partitions int) (*topic, error) {
+	ps.lock.RLock()
+	t, exists := ps.topics[name]
+	ps.lock.RUnlock()
+	if exists {
+		return t, nil
+	}
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if t, exists := ps.topics[name]; exists {
+		return t, nil
+	}
+	if ps.cfg.Dir != "" {
+		onDisk, err := listTopicLogs(ps.cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		if n := onDisk[name]; n > 0 {
+			partitions = n
+		}
+	}
+	t, err := openTopic(ps.cfg, name, partitions)
+	if err != nil {
+		return nil, err
+	}
+	ps.topics[name] = t
+	for _, entry := range ps.patterns.Match(name) {
+		ps.attachLocked(t, entry)
+	}
+	return t, nil
+}
+
+func (ps *PubSubService) group(topic, name string) (*consumerGroup, error) {
+	t, err := ps.topic(topic, ps.cfg.Partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if g, exists := t.groups[name]; exists {
+		return g, nil
+	}
+	offsets, err := openOffsetStore(ps.cfg.Dir, topic, name)
+	if err != nil {
+		return nil, err
+	}
+	g := newConsumerGroup(name, t, offsets)
+	t.groups[name] = g
+	return g, nil
+}
+
+func (ps *PubSubService) deadLetter(msg Message, subId string) {
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["dead-letter-subscriber"] = subId
+	dead := Message{
+		Topic:   ps.cfg.DeadLetterPrefix + msg.Topic,
+		Key:     msg.Key,
+		Headers: headers,
+		Payload: msg.Payload,
+	}
+	if _, err := ps.Publish(dead); err != nil {
+		fmt.Printf("Failed to dead-letter %s/%d@%d for %s: %v\n", msg.Topic, msg.Partition, msg.Offset, subId, err)
+	}
+}
@@ -0,0 +1,259 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type pending struct {
+	msg      Message
+	deadline time.Time
+	attempts int
+	queued   bool
+	started  bool
+}
+
+// subscription delivers one partition log to one subscriber. A dispatcher
+// goroutine reads the log from the subscriber's cursor into a bounded queue
+// and a worker goroutine hands queued messages to the subscriber, so a slow
+// subscriber only ever delays itself.
+//
+// committed is the offset below which every message has been acked or
+// dead-lettered; onCommit, if set, is told each time it moves forward.
+type subscription struct {
+	service   *PubSubService
+	log       *topicLog
+	sub       Subscriber
+	next      int64
+	committed int64
+	done      map[int64]bool
+	onCommit  func(offset int64)
+	filter    Filter
+	inflight  map[int64]*pending
+	retry     []int64
+	queue     chan Message
+	wake      chan struct{}
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+}
+
+func newSubscription(service *PubSubService, log *topicLog, sub Subscriber, start int64, filter Filter, onCommit func(int64)) *subscription {
+	s := &subscription{
+		service:   service,
+		log:       log,
+		sub:       sub,
+		next:      start,
+		committed: start,
+		done:      make(map[int64]bool),
+		onCommit:  onCommit,
+		filter:    filter,
+		inflight:  make(map[int64]*pending),
+		queue:     make(chan Message, service.cfg.QueueSize),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	log.watch(s)
+	s.wg.Add(2)
+	go s.dispatch()
+	go s.work()
+	return s
+}
+
+func (s *subscription) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) close() {
+	s.log.unwatch(s)
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *subscription) dispatch() {
+	defer s.wg.Done()
+	defer close(s.queue)
+
+	ticker := time.NewTicker(s.service.cfg.AckTimeout / 2)
+	defer ticker.Stop()
+	for {
+		s.expire(time.Now())
+		for {
+			msg, filtered, ok := s.nextMessage()
+			if !ok {
+				break
+			}
+			if filtered {
+				s.finish(msg.Offset)
+				continue
+			}
+			select {
+			case s.queue <- msg:
+			case <-s.stop:
+				return
+			}
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// nextMessage picks a redelivery if one is due, otherwise the next message
+// from the log as long as fewer than MaxInFlight messages are unacked. A
+// message the subscription's filter rejects is reported as filtered so the
+// caller can finish it without delivery.
+func (s *subscription) nextMessage() (Message, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.retry) > 0 {
+		offset := s.retry[0]
+		s.retry = s.retry[1:]
+		p, ok := s.inflight[offset]
+		if !ok {
+			continue
+		}
+		return s.deliverLocked(p), false, true
+	}
+
+	if len(s.inflight) >= s.service.cfg.MaxInFlight || s.next >= s.log.Len() {
+		return Message{}, false, false
+	}
+	msg, err := s.log.Read(s.next)
+	if err != nil {
+		return Message{}, false, false
+	}
+	s.next++
+	p := &pending{msg: msg}
+	s.inflight[msg.Offset] = p
+	if s.filter != nil && !s.filter.Match(msg.Headers) {
+		return msg, true, true
+	}
+	return s.deliverLocked(p), false, true
+}
+
+func (s *subscription) deliverLocked(p *pending) Message {
+	p.attempts++
+	p.queued = false
+	p.started = false
+	msg := p.msg
+	msg.Attempt = p.attempts
+	msg.acker = s
+	return msg
+}
+
+// expire schedules redelivery of messages whose ack deadline passed and
+// moves those out of attempts to the dead-letter topic.
+func (s *subscription) expire(now time.Time) {
+	var dead []Message
+	s.mu.Lock()
+	for offset, p := range s.inflight {
+		if p.queued || !p.started || now.Before(p.deadline) {
+			continue
+		}
+		if p.attempts >= s.service.cfg.MaxDeliveries {
+			dead = append(dead, p.msg)
+			continue
+		}
+		p.queued = true
+		s.retry = append(s.retry, offset)
+	}
+	s.mu.Unlock()
+
+	for _, msg := range dead {
+		s.service.deadLetter(msg, s.sub.GetId())
+		s.finish(msg.Offset)
+	}
+}
+
+func (s *subscription) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case msg, ok := <-s.queue:
+			if !ok {
+				return
+			}
+			s.touch(msg.Offset)
+			s.consume(msg)
+		}
+	}
+}
+
+// consume treats a panicking subscriber like a nack.
+func (s *subscription) consume(msg Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Subscriber %s panicked on %s@%d: %v\n", s.sub.GetId(), msg.Topic, msg.Offset, r)
+			s.nack(msg.Offset)
+		}
+	}()
+	s.sub.Consume(msg)
+}
+
+// touch starts the ack deadline once the subscriber actually gets the
+// message, so time spent waiting in the queue does not count against it.
+func (s *subscription) touch(offset int64) {
+	s.mu.Lock()
+	if p, ok := s.inflight[offset]; ok {
+		p.deadline = time.Now().Add(s.service.cfg.AckTimeout)
+		p.started = true
+	}
+	s.mu.Unlock()
+}
+
+func (s *subscription) Ack(msg Message) {
+	s.ack(msg.Offset)
+}
+
+func (s *subscription) Nack(msg Message) {
+	s.nack(msg.Offset)
+}
+
+func (s *subscription) ack(offset int64) {
+	s.finish(offset)
+	s.notify()
+}
+
+// finish drops the message from the in-flight set and advances the
+// committed offset past every finished message in a row.
+func (s *subscription) finish(offset int64) {
+	s.mu.Lock()
+	if _, ok := s.inflight[offset]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.inflight, offset)
+	s.done[offset] = true
+	before := s.committed
+	for s.done[s.committed] {
+		delete(s.done, s.committed)
+		s.committed++
+	}
+	committed := s.committed
+	s.mu.Unlock()
+
+	if committed != before && s.onCommit != nil {
+		s.onCommit(committed)
+	}
+}
+
+func (s *subscription) nack(offset int64) {
+	s.mu.Lock()
+	if p, ok := s.inflight[offset]; ok {
+		p.deadline = time.Time{}
+	}
+	s.mu.Unlock()
+	s.notify()
+}
@@ -0,0 +1,58 @@
+package pubsub
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// topic is split into partitions, each its own log with its own offsets.
+// Plain subscribers read every partition; a consumer group splits them
+// between its members.
+type topic struct {
+	name       string
+	partitions []*topicLog
+	subs       map[subKey][]*subscription
+	groups     map[string]*consumerGroup
+	next       uint32
+}
+
+func openTopic(cfg Config, name string, partitions int) (*topic, error) {
+	t := &topic{
+		name:   name,
+		subs:   make(map[subKey][]*subscription),
+		groups: make(map[string]*consumerGroup),
+	}
+	for p := 0; p < partitions; p++ {
+		log, err := openTopicLog(cfg, name, p)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.partitions = append(t.partitions, log)
+	}
+	return t, nil
+}
+
+// partition hashes the key, or spreads keyless messages round-robin.
+func (t *topic) partition(key string) int {
+	n := uint32(len(t.partitions))
+	if key == "" {
+		return int(atomic.AddUint32(&t.next, 1) % n)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % n)
+}
+
+func (t *topic) close() error {
+	var firstErr error
+	for _, log := range t.partitions {
+		if err := log.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := log.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
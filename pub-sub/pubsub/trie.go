@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+)
+
+// Topics are hierarchical, with levels separated by dots, e.g.
+// "orders.eu.created". Subscription patterns may use MQTT-style wildcards:
+// "+" (or "*") matches exactly one level and "#", only allowed as the last
+// level, matches any number of levels including none. Wildcards in the first
+// level do not match topics starting with "$", which keeps internal topics
+// such as dead letters out of catch-all subscriptions.
+const (
+	levelSeparator = "."
+	singleLevel    = "+"
+	multiLevel     = "#"
+)
+
+var (
+	ErrInvalidTopic   = errors.New("invalid topic")
+	ErrInvalidPattern = errors.New("invalid topic pattern")
+)
+
+func validateTopic(topic string) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	for _, level := range strings.Split(topic, levelSeparator) {
+		if level == "" || level == singleLevel || level == multiLevel || level == "*" {
+			return ErrInvalidTopic
+		}
+	}
+	return nil
+}
+
+// parsePattern splits a pattern into levels, normalising "*" to "+".
+func parsePattern(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, ErrInvalidPattern
+	}
+	levels := strings.Split(pattern, levelSeparator)
+	for i, level := range levels {
+		switch {
+		case level == "":
+			return nil, ErrInvalidPattern
+		case level == "*":
+			levels[i] = singleLevel
+		case level == multiLevel && i != len(levels)-1:
+			return nil, ErrInvalidPattern
+		}
+	}
+	return levels, nil
+}
+
+func isPattern(levels []string) bool {
+	for _, level := range levels {
+		if level == singleLevel || level == multiLevel {
+			return true
+		}
+	}
+	return false
+}
+
+// subKey identifies one Subscribe call: the same subscriber may hold
+// several patterns at once.
+type subKey struct {
+	pattern string
+	id      string
+}
+
+type patternSub struct {
+	key    subKey
+	sub    Subscriber
+	filter Filter
+	from   int64
+	resume map[partitionKey]int64
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	subs     map[subKey]*patternSub
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children: make(map[string]*trieNode),
+		subs:     make(map[subKey]*patternSub),
+	}
+}
+
+// topicTrie indexes subscriptions by pattern level so the subscribers of a
+// topic are found without testing every pattern.
+type topicTrie struct {
+	root *trieNode
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: newTrieNode()}
+}
+
+func (t *topicTrie) Insert(levels []string, ps *patternSub) {
+	node := t.root
+	for _, level := range levels {
+		child, ok := node.children[level]
+		if !ok {
+			child = newTrieNode()
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.subs[ps.key] = ps
+}
+
+// Remove deletes the subscription and prunes nodes left empty.
+func (t *topicTrie) Remove(levels []string, key subKey) *patternSub {
+	var removed *patternSub
+	var walk func(node *trieNode, i int) bool
+	walk = func(node *trieNode, i int) bool {
+		if i == len(levels) {
+			removed = node.subs[key]
+			delete(node.subs, key)
+		} else if child, ok := node.children[levels[i]]; ok && walk(child, i+1) {
+			delete(node.children, levels[i])
+		}
+		return len(node.subs) == 0 && len(node.children) == 0
+	}
+	walk(t.root, 0)
+	return removed
+}
+
+// Match returns every subscription whose pattern matches the topic.
+func (t *topicTrie) Match(topic string) []*patternSub {
+	levels := strings.Split(topic, levelSeparator)
+	internal := strings.HasPrefix(topic, "$")
+	var matches []*patternSub
+	var walk func(node *trieNode, i int)
+	walk = func(node *trieNode, i int) {
+		wildcards := i > 0 || !internal
+		if child, ok := node.children[multiLevel]; ok && wildcards {
+			for _, ps := range child.subs {
+				matches = append(matches, ps)
+			}
+		}
+		if i == len(levels) {
+			for _, ps := range node.subs {
+				matches = append(matches, ps)
+			}
+			return
+		}
+		if child, ok := node.children[levels[i]]; ok {
+			walk(child, i+1)
+		}
+		if child, ok := node.children[singleLevel]; ok && wildcards {
+			walk(child, i+1)
+		}
+	}
+	walk(t.root, 0)
+	return matches
+}
+
+// matchPattern reports whether a single topic matches the pattern, using the
+// same rules as topicTrie.Match.
+func matchPattern(levels []string, topic string) bool {
+	trie := newTopicTrie()
+	trie.Insert(levels, &patternSub{})
+	return len(trie.Match(topic)) > 0
+}
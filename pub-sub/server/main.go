@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rishu/design/pub-sub/broker"
+	"github.com/rishu/design/pub-sub/client"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+func main() {
+	serve := flag.Bool("serve", false, "run a standalone broker instead of the localhost demo")
+	tcpAddr := flag.String("tcp", "127.0.0.1:7070", "TCP listen address")
+	wsAddr := flag.String("ws", "127.0.0.1:7071", "WebSocket listen address, served at /ws")
+	dir := flag.String("dir", "", "directory for topic logs; empty keeps them in memory")
+	partitions := flag.Int("partitions", 1, "default partitions per topic")
+	origins := flag.String("origins", "", "comma-separated origins allowed to open a WebSocket; empty allows the same host only")
+	flag.Parse()
+
+	if !*serve {
+		demo()
+		return
+	}
+
+	svc := pubsub.NewPubSubService(pubsub.Config{Dir: *dir, Partitions: *partitions})
+	defer svc.Close()
+	var allowed []string
+	if *origins != "" {
+		allowed = strings.Split(*origins, ",")
+	}
+	server := broker.NewServer(svc, broker.Config{AllowedOrigins: allowed})
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", server)
+	go func() {
+		log.Fatal(http.ListenAndServe(*wsAddr, mux))
+	}()
+
+	l, err := net.Listen("tcp", *tcpAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("broker listening on tcp://%s and ws://%s/ws", *tcpAddr, *wsAddr)
+	log.Fatal(server.ServeTCP(l))
+}
+
+// demo runs a broker and two clients on localhost, then restarts the broker
+// to show the TCP client reconnecting and resubscribing.
+func demo() {
+	svc := pubsub.NewPubSubService(pubsub.Config{Partitions: 2})
+	defer svc.Close()
+	cfg := broker.Config{HeartbeatInterval: 100 * time.Millisecond}
+
+	server := broker.NewServer(svc, cfg)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tcpAddr := l.Addr().String()
+	go server.ServeTCP(l)
+
+	wl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	go http.Serve(wl, server)
+
+	up := make(chan bool, 4)
+	opts := client.Options{
+		HeartbeatTimeout: 500 * time.Millisecond,
+		MinBackoff:       50 * time.Millisecond,
+		OnStateChange: func(connected bool, err error) {
+			if err != nil {
+				log.Println(err)
+			}
+			up <- connected
+		},
+	}
+	tcpClient, err := client.Dial(tcpAddr, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tcpClient.Close()
+	wsClient, err := client.Dial("ws://"+wl.Addr().String()+"/ws", client.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	received := make(chan client.Message, 16)
+	handler := func(msg client.Message) {
+		msg.Ack()
+		received <- msg
+	}
+	wsClient.Subscribe(ctx, "orders.#", client.SubscribeOptions{Filter: "region = 'eu'", From: client.Latest}, handler)
+	tcpClient.Subscribe(ctx, "alerts", client.SubscribeOptions{From: client.Latest}, handler)
+
+	tcpClient.Publish(ctx, client.Message{Topic: "orders.web.created", Headers: map[string]string{"region": "us"}, Payload: "order 1"})
+	tcpClient.Publish(ctx, client.Message{Topic: "orders.web.created", Headers: map[string]string{"region": "eu"}, Payload: "order 2"})
+	fmt.Println("over websocket:", (<-received).Payload) // order 2
+
+	wsClient.Publish(ctx, client.Message{Topic: "alerts", Payload: "disk full"})
+	fmt.Println("over tcp:", (<-received).Payload) // disk full
+	wsClient.Close()
+
+	// Sit idle across several heartbeats: the connection stays up.
+	time.Sleep(time.Second)
+	fmt.Println("still connected:", len(up) == 0) // true
+
+	// Restart the broker on the same address.
+	server.Close()
+	fmt.Println("connected:", <-up) // false
+	server = broker.NewServer(svc, cfg)
+	defer server.Close()
+	if l, err = net.Listen("tcp", tcpAddr); err != nil {
+		log.Fatal(err)
+	}
+	go server.ServeTCP(l)
+	fmt.Println("connected:", <-up) // true
+
+	tcpClient.Publish(ctx, client.Message{Topic: "alerts", Payload: "back online"})
+	fmt.Println("after reconnect:", (<-received).Payload) // back online
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+// Collector records the topics it receives.
+type Collector struct {
+	Id     string
+	wg     *sync.WaitGroup
+	topics []string
+	mu     sync.Mutex
+}
+
+func (c *Collector) Consume(msg pubsub.Message) {
+	c.mu.Lock()
+	c.topics = append(c.topics, msg.Topic+" "+msg.Headers["region"])
+	c.mu.Unlock()
+	msg.Ack()
+	c.wg.Done()
+}
+
+func (c *Collector) GetId() string {
+	return c.Id
+}
+
+func (c *Collector) Topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.Strings(c.topics)
+	return c.topics
+}
+
+func wildcardDemo() {
+	svc := pubsub.NewPubSubService(pubsub.Config{})
+	defer svc.Close()
+
+	var wg sync.WaitGroup
+	created := &Collector{Id: "created-eu", wg: &wg}
+	everything := &Collector{Id: "everything", wg: &wg}
+	svc.SubscribeWhere("orders.*.created", "region = 'eu'", created, pubsub.Earliest)
+	svc.Subscribe("orders.#", everything, pubsub.Earliest)
+
+	events := []pubsub.Message{
+		{Topic: "orders.web.created", Headers: map[string]string{"region": "eu"}},
+		{Topic: "orders.web.created", Headers: map[string]string{"region": "us"}},
+		{Topic: "orders.app.created", Headers: map[string]string{"region": "eu"}},
+		{Topic: "orders.app.shipped", Headers: map[string]string{"region": "eu"}},
+		{Topic: "payments.web.created", Headers: map[string]string{"region": "eu"}},
+	}
+	wg.Add(2 + 4)
+	for _, msg := range events {
+		svc.Publish(msg)
+	}
+	wg.Wait()
+
+	fmt.Println(created.Topics())         // [orders.app.created eu orders.web.created eu]
+	fmt.Println(len(everything.Topics())) // 4
+
+	_, err := svc.Publish(pubsub.Message{Topic: "orders.+.created"})
+	fmt.Println(err) // invalid topic
+	err = svc.SubscribeWhere("orders.#", "region = ", created, pubsub.Latest)
+	fmt.Println(err != nil) // true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+// simulatedBackend serves `capacity` requests in parallel at baseLatency;
+// beyond that requests queue and latency grows with the overload.
+type simulatedBackend struct {
+	capacity    int64
+	baseLatency time.Duration
+	inflight    int64
+}
+
+func (b *simulatedBackend) call() {
+	n := atomic.AddInt64(&b.inflight, 1)
+	defer atomic.AddInt64(&b.inflight, -1)
+
+	latency := b.baseLatency
+	if n > b.capacity {
+		latency = time.Duration(float64(b.baseLatency) * float64(n) / float64(b.capacity))
+	}
+	time.Sleep(latency)
+}
+
+// adaptiveDemo drives 100 eager clients through each algorithm against a
+// backend that can handle 20 concurrent requests and prints where the limit
+// settles.
+func adaptiveDemo() {
+	bounds := limiter.LimitBounds{Initial: 5, Min: 1, Max: 200}
+	algorithms := []struct {
+		name      string
+		algorithm limiter.LimitAlgorithm
+	}{
+		{"aimd", limiter.NewWindowed(limiter.NewAIMD(bounds, 0.9, 11*time.Millisecond), 20*time.Millisecond, 10)},
+		{"vegas", limiter.NewWindowed(limiter.NewVegas(bounds), 20*time.Millisecond, 10)},
+		{"gradient", limiter.NewWindowed(limiter.NewGradient(bounds, 0.2), 20*time.Millisecond, 10)},
+	}
+
+	for _, alg := range algorithms {
+		backend := &simulatedBackend{capacity: 20, baseLatency: 10 * time.Millisecond}
+		adaptive := limiter.NewAdaptiveLimiter(alg.algorithm)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					release, err := adaptive.Acquire(ctx)
+					if err != nil {
+						return
+					}
+					backend.call()
+					release(true)
+				}
+			}()
+		}
+
+		var samples []int
+		ticker := time.NewTicker(250 * time.Millisecond)
+		for len(samples) < 7 {
+			<-ticker.C
+			samples = append(samples, adaptive.Limit())
+		}
+		ticker.Stop()
+		wg.Wait()
+		cancel()
+		fmt.Printf("%-8s limit over time: %v (backend capacity %d)\n", alg.name, samples, backend.capacity)
+	}
+}
@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"github.com/rishu/design/rate-limiter/config"
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+// LayeredBuilder composes per-user, per-tenant and global token buckets
+// into one limiter. SetRate and SetBucketSize configure a per-user layer so
+// the builder also works with the plain director.
+type LayeredBuilder struct {
+	Layers        []limiter.Layer
+	EndpointCosts map[string]int
+	rate          int
+	bucketSize    int
+	maxBuckets    int
+}
+
+func NewLayeredBuilder() *LayeredBuilder {
+	return &LayeredBuilder{
+		EndpointCosts: make(map[string]int),
+	}
+}
+
+func (b *LayeredBuilder) SetRate(rate int) RateLimiterBuilder {
+	b.rate = rate
+	return b
+}
+
+func (b *LayeredBuilder) SetBucketSize(size int) RateLimiterBuilder {
+	b.bucketSize = size
+	return b
+}
+
+func (b *LayeredBuilder) AddLayer(name string, scope limiter.Scope, rate float64, bucketSize int) *LayeredBuilder {
+	b.Layers = append(b.Layers, limiter.Layer{
+		Name:       name,
+		Scope:      scope,
+		Rate:       rate,
+		BucketSize: bucketSize,
+	})
+	return b
+}
+
+// SetEndpointCost charges cost tokens per request to endpoint. The cost
+// must be positive and fit in every layer added so far; BuildLayered checks
+// it again against the final layers.
+func (b *LayeredBuilder) SetEndpointCost(endpoint string, cost int) error {
+	if err := limiter.ValidateCost(b.layers(), endpoint, cost); err != nil {
+		return err
+	}
+	b.EndpointCosts[endpoint] = cost
+	return nil
+}
+
+// SetMaxBuckets bounds the number of user and tenant buckets the limiter
+// keeps; zero means limiter.DefaultMaxBuckets.
+func (b *LayeredBuilder) SetMaxBuckets(n int) *LayeredBuilder {
+	b.maxBuckets = n
+	return b
+}
+
+// FromConfig replaces the builder's layers and costs with those in cfg.
+func (b *LayeredBuilder) FromConfig(cfg *config.QuotaConfig) error {
+	b.Layers = nil
+	b.EndpointCosts = make(map[string]int)
+	for _, layer := range cfg.Layers {
+		b.AddLayer(layer.Name, limiter.Scope(layer.Scope), layer.Rate, layer.BucketSize)
+	}
+	for endpoint, cost := range cfg.EndpointCosts {
+		if err := b.SetEndpointCost(endpoint, cost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Build returns nil if the endpoint costs do not fit the layers;
+// BuildLayered reports why.
+func (b *LayeredBuilder) Build() limiter.RateLimiter {
+	l, err := b.BuildLayered()
+	if err != nil {
+		return nil
+	}
+	return l
+}
+
+func (b *LayeredBuilder) BuildLayered() (*limiter.LayeredLimiter, error) {
+	return limiter.NewLayeredLimiter(b.layers(), b.costs(), b.maxBuckets)
+}
+
+// Apply reconfigures l with the builder's current layers and costs.
+func (b *LayeredBuilder) Apply(l *limiter.LayeredLimiter) error {
+	return l.Reconfigure(b.layers(), b.costs())
+}
+
+func (b *LayeredBuilder) layers() []limiter.Layer {
+	layers := append([]limiter.Layer(nil), b.Layers...)
+	if b.bucketSize > 0 {
+		layers = append(layers, limiter.Layer{
+			Name:       "per-user-default",
+			Scope:      limiter.ScopeUser,
+			Rate:       float64(b.rate),
+			BucketSize: b.bucketSize,
+		})
+	}
+	return layers
+}
+
+func (b *LayeredBuilder) costs() map[string]int {
+	costs := make(map[string]int, len(b.EndpointCosts))
+	for endpoint, cost := range b.EndpointCosts {
+		costs[endpoint] = cost
+	}
+	return costs
+}
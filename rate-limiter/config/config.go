@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// QuotaConfig is the file format for layered limits. Files ending in .yaml
+// or .yml are read as YAML and everything else as JSON, e.g.
+//
+//	{
+//	  "layers": [
+//	    {"name": "per-user", "scope": "user", "rate": 5, "bucket_size": 10},
+//	    {"name": "per-tenant", "scope": "tenant", "rate": 50, "bucket_size": 100},
+//	    {"name": "global", "scope": "global", "rate": 500, "bucket_size": 1000}
+//	  ],
+//	  "endpoint_costs": {"/search": 5}
+//	}
+//
+// or the same in YAML:
+//
+//	layers:
+//	  - {name: per-user, scope: user, rate: 5, bucket_size: 10}
+//	  - {name: global, scope: global, rate: 500, bucket_size: 1000}
+//	endpoint_costs:
+//	  /search: 5
+type QuotaConfig struct {
+	Layers        []LayerConfig  `json:"layers" yaml:"layers"`
+	EndpointCosts map[string]int `json:"endpoint_costs" yaml:"endpoint_costs"`
+}
+
+type LayerConfig struct {
+	Name       string  `json:"name" yaml:"name"`
+	Scope      string  `json:"scope" yaml:"scope"`
+	Rate       float64 `json:"rate" yaml:"rate"`
+	BucketSize int     `json:"bucket_size" yaml:"bucket_size"`
+}
+
+func Load(path string) (*QuotaConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(path, data)
+}
+
+// parse rejects unknown keys in either format, so a misspelt field is an
+// error rather than silently left at zero.
+func parse(path string, data []byte) (*QuotaConfig, error) {
+	var cfg QuotaConfig
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &cfg)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *QuotaConfig) Validate() error {
+	// An empty file, e.g. one caught half way through being rewritten,
+	// would otherwise lift every limit.
+	if len(c.Layers) == 0 {
+		return fmt.Errorf("no layers configured")
+	}
+	names := make(map[string]bool)
+	for _, layer := range c.Layers {
+		if layer.Name == "" || names[layer.Name] {
+			return fmt.Errorf("layer names must be unique and non-empty, got %q", layer.Name)
+		}
+		names[layer.Name] = true
+		switch layer.Scope {
+		case "user", "tenant", "global":
+		default:
+			return fmt.Errorf("layer %q has unknown scope %q", layer.Name, layer.Scope)
+		}
+		if layer.Rate < 0 || layer.BucketSize <= 0 {
+			return fmt.Errorf("layer %q needs a non-negative rate and a positive bucket size", layer.Name)
+		}
+	}
+	// A cost larger than some layer's bucket could never be paid, so every
+	// request to that endpoint would be rejected.
+	for endpoint, cost := range c.EndpointCosts {
+		if cost <= 0 {
+			return fmt.Errorf("endpoint %q has non-positive cost %d", endpoint, cost)
+		}
+		for _, layer := range c.Layers {
+			if cost > layer.BucketSize {
+				return fmt.Errorf("endpoint %q costs %d, more than layer %q's bucket size %d", endpoint, cost, layer.Name, layer.BucketSize)
+			}
+		}
+	}
+	return nil
+}
+
+// Watch polls path every interval and calls onChange with the new config
+// whenever the file's contents change and it still parses. Contents are
+// compared by hash, since an edit can keep the modification time when the
+// clock is coarse or the file is restored with its old one. A broken edit
+// is reported through onError and the previous config stays in effect. The
+// returned function stops watching.
+func Watch(path string, interval time.Duration, onChange func(*QuotaConfig), onError func(error)) (stop func()) {
+	var last [sha256.Size]byte
+	if data, err := os.ReadFile(path); err == nil {
+		last = sha256.Sum256(data)
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				data, err := os.ReadFile(path)
+				if err != nil {
+					onError(err)
+					continue
+				}
+				sum := sha256.Sum256(data)
+				if sum == last {
+					continue
+				}
+				last = sum
+				cfg, err := parse(path, data)
+				if err != nil {
+					onError(err)
+					continue
+				}
+				onChange(cfg)
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
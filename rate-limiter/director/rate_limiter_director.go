@@ -1,7 +1,11 @@
 package director
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/rishu/design/rate-limiter/builder"
+	"github.com/rishu/design/rate-limiter/config"
 	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
 )
 
@@ -22,3 +26,39 @@ func (d *RateLimiterDirector) SetBuilder(builder builder.RateLimiterBuilder) {
 func (d *RateLimiterDirector) Construct() limiter.RateLimiter {
 	return d.Builder.SetRate(5).SetBucketSize(10).Build()
 }
+
+// ConstructLayered builds a layered limiter with the director's builder, which
+// must be a *builder.LayeredBuilder, from the YAML or JSON config file at
+// path and reloads it whenever the file changes. A reload that fails leaves
+// the previous limits in place and is passed to onError, which may be nil.
+// The watcher keeps using the builder, so don't share it until stop has
+// been called.
+func (d *RateLimiterDirector) ConstructLayered(path string, reloadEvery time.Duration, onError func(error)) (l *limiter.LayeredLimiter, stop func(), err error) {
+	b, ok := d.Builder.(*builder.LayeredBuilder)
+	if !ok {
+		return nil, nil, fmt.Errorf("layered limits need a *builder.LayeredBuilder, got %T", d.Builder)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := b.FromConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+	if l, err = b.BuildLayered(); err != nil {
+		return nil, nil, err
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	stop = config.Watch(path, reloadEvery, func(cfg *config.QuotaConfig) {
+		err := b.FromConfig(cfg)
+		if err == nil {
+			err = b.Apply(l)
+		}
+		if err != nil {
+			onError(err)
+		}
+	}, onError)
+	return l, stop, nil
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
 	builder2 "github.com/rishu/design/rate-limiter/builder"
 	director2 "github.com/rishu/design/rate-limiter/director"
-	"time"
+	"github.com/rishu/design/rate-limiter/middleware"
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
 )
 
 func main() {
@@ -20,4 +27,88 @@ func main() {
 		}
 		time.Sleep(1 * time.Millisecond)
 	}
+
+	hello := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello")
+	})
+	limited := middleware.RateLimit(limiter.NewKeyedTokenBucket(1, 2, 10000), middleware.FirstOf(middleware.HeaderKey("X-API-Key"), middleware.IPKey()))(hello)
+	for i, key := range []string{"key-1", "key-1", "key-1", "key-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		// expected: key-1 gets 200, 200, 429; key-2 has its own bucket and gets 200
+		fmt.Printf("HTTP %d %s (request %d) limit=%s remaining=%s reset=%s retry-after=%s\n", rec.Code, key, i+1,
+			rec.Header().Get("RateLimit-Limit"), rec.Header().Get("RateLimit-Remaining"),
+			rec.Header().Get("RateLimit-Reset"), rec.Header().Get("Retry-After"))
+	}
+
+	layeredDemo()
+	adaptiveDemo()
+}
+
+func layeredDemo() {
+	lb := builder2.NewLayeredBuilder().
+		AddLayer("per-user", limiter.ScopeUser, 1, 3).
+		AddLayer("per-tenant", limiter.ScopeTenant, 1, 4)
+	fmt.Println("free endpoint:", lb.SetEndpointCost("/health", 0)) // expected: non-positive cost
+	if err := lb.SetEndpointCost("/export", 2); err != nil {
+		fmt.Println(err)
+		return
+	}
+	layered, err := lb.BuildLayered()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for i, req := range []limiter.RequestInfo{
+		{UserId: "alice", TenantId: "acme", Endpoint: "/orders"},
+		{UserId: "alice", TenantId: "acme", Endpoint: "/export"},
+		{UserId: "bob", TenantId: "acme", Endpoint: "/export"}, // tenant has 1 token left: denied, bob keeps his
+		{UserId: "bob", TenantId: "acme", Endpoint: "/orders"},
+	} {
+		d := layered.AllowRequest(req)
+		fmt.Printf("Layered request %d (%s %s) allowed=%v remaining=%d\n", i+1, req.UserId, req.Endpoint, d.Allowed, d.Remaining)
+	}
+
+	src, err := os.ReadFile("rate-limiter/quotas.yaml")
+	if err != nil {
+		fmt.Println("run from the repository root to load rate-limiter/quotas.yaml")
+		return
+	}
+	path := filepath.Join(os.TempDir(), "quotas.yaml")
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fromFile, stop, err := director2.NewRateLimiterDirector(builder2.NewLayeredBuilder()).ConstructLayered(path, 20*time.Millisecond, func(err error) {
+		fmt.Println("rate limit config not reloaded:", err)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer stop()
+	search := limiter.RequestInfo{UserId: "carol", TenantId: "initech", Endpoint: "/search"}
+	fmt.Println("search allowed:", fromFile.AllowRequest(search).Allowed) // cost 5 of 10
+	// rejected: /search costs 5 but the bucket only holds 1, so the old config stays
+	replaceFile(path, "layers: [{name: per-user, scope: user, rate: 0, bucket_size: 1}]\nendpoint_costs: {/search: 5}\n")
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println("search allowed after bad edit:", fromFile.AllowRequest(search).Allowed) // cost 5 of the remaining 5
+	replaceFile(path, "layers: [{name: per-user, scope: user, rate: 0, bucket_size: 5}]\nendpoint_costs: {/search: 5}\n")
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println("search allowed after reload:", fromFile.AllowRequest(search).Allowed) // bucket empty, no refill
+}
+
+// replaceFile swaps in new contents by rename so the watcher never reads a
+// half-written file.
+func replaceFile(path, contents string) {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(contents), 0o644); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		fmt.Println(err)
+	}
 }
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+// KeyFunc extracts the client key a request is limited by. An empty key
+// means the function could not identify the client.
+type KeyFunc func(r *http.Request) string
+
+func HeaderKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+func IPKey() KeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// FirstOf tries each KeyFunc in turn, e.g. an API key header before the IP.
+func FirstOf(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, fn := range funcs {
+			if key := fn(r); key != "" {
+				return key
+			}
+		}
+		return ""
+	}
+}
+
+// RateLimit rejects requests the limiter denies with 429 and a Retry-After
+// header, and sets RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// on every response. Requests whose key cannot be determined are rejected
+// with 400, and clients the limiter forbids outright with 403.
+func RateLimit(l limiter.DecisionLimiter, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientId := key(r)
+			if clientId == "" {
+				http.Error(w, "cannot identify client", http.StatusBadRequest)
+				return
+			}
+
+			decision := l.Decide(clientId)
+			if decision.Forbidden {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			h.Set("RateLimit-Reset", seconds(decision.Reset))
+			if !decision.Allowed {
+				h.Set("Retry-After", seconds(decision.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds up so clients never retry before the limit has reset.
+func seconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
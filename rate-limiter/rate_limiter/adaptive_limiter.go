@@ -0,0 +1,111 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LimitAlgorithm turns the outcome of each request into a new concurrency
+// limit. rtt is the request's latency, inflight is how many requests were
+// running when it started and success is false for drops and timeouts.
+type LimitAlgorithm interface {
+	Update(rtt time.Duration, inflight int, success bool) int
+	Limit() int
+}
+
+// AdaptiveLimiter bounds the number of requests in flight instead of their
+// rate. The bound is not configured by hand; it follows the LimitAlgorithm
+// as latency rises and falls, so an overloaded downstream is noticed from
+// its response times.
+type AdaptiveLimiter struct {
+	algorithm LimitAlgorithm
+	limit     int
+	inflight  int
+	waiters   []chan struct{}
+	mu        sync.Mutex
+}
+
+func NewAdaptiveLimiter(algorithm LimitAlgorithm) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		algorithm: algorithm,
+		limit:     algorithm.Limit(),
+	}
+}
+
+// Acquire waits for a free slot or for ctx to end. On success the caller
+// must call release exactly once, reporting whether the request succeeded.
+func (a *AdaptiveLimiter) Acquire(ctx context.Context) (release func(success bool), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	for a.inflight >= a.limit {
+		wait := make(chan struct{})
+		a.waiters = append(a.waiters, wait)
+		a.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.dropWaiter(wait)
+			a.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		a.mu.Lock()
+	}
+	a.inflight++
+	inflight := a.inflight
+	a.mu.Unlock()
+
+	start := time.Now()
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			rtt := time.Since(start)
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.inflight--
+			a.limit = a.algorithm.Update(rtt, inflight, success)
+			a.wakeLocked()
+		})
+	}, nil
+}
+
+func (a *AdaptiveLimiter) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.limit
+}
+
+func (a *AdaptiveLimiter) InFlight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.inflight
+}
+
+// wakeLocked signals as many waiters, oldest first, as there are free slots.
+// A woken waiter re-checks the limit itself, so waking one too many is safe.
+func (a *AdaptiveLimiter) wakeLocked() {
+	free := a.limit - a.inflight
+	for free > 0 && len(a.waiters) > 0 {
+		close(a.waiters[0])
+		a.waiters = a.waiters[1:]
+		free--
+	}
+}
+
+func (a *AdaptiveLimiter) dropWaiter(wait chan struct{}) {
+	for i, w := range a.waiters {
+		if w == wait {
+			a.waiters = append(a.waiters[:i], a.waiters[i+1:]...)
+			return
+		}
+	}
+	// Already woken: pass the wake-up on so the slot is not lost.
+	a.wakeLocked()
+}
@@ -0,0 +1,23 @@
+package limiter
+
+import "time"
+
+// Decision is the full answer to a rate limit check, carrying what an HTTP
+// layer needs for RateLimit-* and Retry-After headers.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the client's quota is fully restored.
+	Reset time.Duration
+	// RetryAfter is how long a denied client should wait before trying
+	// again; it is zero when the request was allowed.
+	RetryAfter time.Duration
+	// Forbidden means the client is never allowed, so waiting will not
+	// help.
+	Forbidden bool
+}
+
+type DecisionLimiter interface {
+	Decide(clientId string) Decision
+}
@@ -0,0 +1,67 @@
+package limiter
+
+import (
+	"container/list"
+	"sync"
+)
+
+type keyedBucket struct {
+	key    string
+	bucket *TokenBucket
+}
+
+// KeyedTokenBucket gives every client its own TokenBucket. At most maxKeys
+// buckets are kept; past that the least recently used one is dropped, and
+// that client starts again with a full bucket.
+type KeyedTokenBucket struct {
+	rate       int
+	bucketSize int
+	maxKeys    int
+	order      *list.List
+	buckets    map[string]*list.Element
+	mu         sync.Mutex
+}
+
+func NewKeyedTokenBucket(rate, bucketSize, maxKeys int) *KeyedTokenBucket {
+	return &KeyedTokenBucket{
+		rate:       rate,
+		bucketSize: bucketSize,
+		maxKeys:    maxKeys,
+		order:      list.New(),
+		buckets:    make(map[string]*list.Element),
+	}
+}
+
+func (k *KeyedTokenBucket) IsRequestAllowed(clientId string) bool {
+	return k.Decide(clientId).Allowed
+}
+
+func (k *KeyedTokenBucket) Decide(clientId string) Decision {
+	return k.bucket(clientId).Decide(clientId)
+}
+
+// Len is the number of clients currently tracked.
+func (k *KeyedTokenBucket) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.order.Len()
+}
+
+func (k *KeyedTokenBucket) bucket(clientId string) *TokenBucket {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if elem, found := k.buckets[clientId]; found {
+		k.order.MoveToFront(elem)
+		return elem.Value.(*keyedBucket).bucket
+	}
+	b := NewTokenBucket(k.rate, k.bucketSize)
+	k.buckets[clientId] = k.order.PushFront(&keyedBucket{key: clientId, bucket: b})
+	for k.maxKeys > 0 && k.order.Len() > k.maxKeys {
+		oldest := k.order.Back()
+		k.order.Remove(oldest)
+		delete(k.buckets, oldest.Value.(*keyedBucket).key)
+	}
+	return b
+}
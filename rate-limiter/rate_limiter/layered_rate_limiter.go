@@ -0,0 +1,230 @@
+package limiter
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeUser   Scope = "user"
+	ScopeTenant Scope = "tenant"
+	ScopeGlobal Scope = "global"
+)
+
+// Layer is one token bucket level. Every distinct user (or tenant) gets its
+// own bucket in a user (or tenant) layer, while a global layer has one
+// bucket shared by all requests. Rate is in tokens per second.
+type Layer struct {
+	Name       string
+	Scope      Scope
+	Rate       float64
+	BucketSize int
+}
+
+type RequestInfo struct {
+	UserId   string
+	TenantId string
+	Endpoint string
+}
+
+type layerBucket struct {
+	id         string
+	layer      string
+	tokens     float64
+	lastRefill time.Time
+}
+
+// DefaultMaxBuckets bounds how many user and tenant buckets a LayeredLimiter
+// keeps when NewLayeredLimiter is given no limit.
+const DefaultMaxBuckets = 100000
+
+// LayeredLimiter admits a request only if every layer has enough tokens for
+// the endpoint's cost, and only then takes the tokens from all of them, so a
+// request rejected by one layer never drains another.
+//
+// At most maxBuckets buckets are kept. Past that the least recently used
+// one is dropped; it has usually refilled by then, and if not its subject
+// simply starts again with a full bucket.
+type LayeredLimiter struct {
+	layers     []Layer
+	costs      map[string]int
+	buckets    map[string]*list.Element
+	order      *list.List
+	maxBuckets int
+	mu         sync.Mutex
+}
+
+func NewLayeredLimiter(layers []Layer, costs map[string]int, maxBuckets int) (*LayeredLimiter, error) {
+	if err := ValidateCosts(layers, costs); err != nil {
+		return nil, err
+	}
+	if maxBuckets <= 0 {
+		maxBuckets = DefaultMaxBuckets
+	}
+	return &LayeredLimiter{
+		layers:     layers,
+		costs:      costs,
+		buckets:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxBuckets: maxBuckets,
+	}, nil
+}
+
+// ValidateCosts rejects endpoint costs that are not positive or that are
+// larger than some layer's bucket, which could never be paid.
+func ValidateCosts(layers []Layer, costs map[string]int) error {
+	for endpoint, cost := range costs {
+		if err := ValidateCost(layers, endpoint, cost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateCost(layers []Layer, endpoint string, cost int) error {
+	if cost <= 0 {
+		return fmt.Errorf("endpoint %q has non-positive cost %d", endpoint, cost)
+	}
+	for _, layer := range layers {
+		if cost > layer.BucketSize {
+			return fmt.Errorf("endpoint %q costs %d, more than layer %q's bucket size %d", endpoint, cost, layer.Name, layer.BucketSize)
+		}
+	}
+	return nil
+}
+
+func (l *LayeredLimiter) IsRequestAllowed(clientId string) bool {
+	return l.AllowRequest(RequestInfo{UserId: clientId}).Allowed
+}
+
+func (l *LayeredLimiter) Decide(clientId string) Decision {
+	return l.AllowRequest(RequestInfo{UserId: clientId})
+}
+
+func (l *LayeredLimiter) AllowRequest(req RequestInfo) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cost := 1
+	if c, ok := l.costs[req.Endpoint]; ok {
+		cost = c
+	}
+
+	type checked struct {
+		layer  Layer
+		bucket *layerBucket
+	}
+	var layers []checked
+	allowed := true
+	for _, layer := range l.layers {
+		key, ok := subject(layer.Scope, req)
+		if !ok {
+			continue
+		}
+		b := l.bucket(layer, key, now)
+		layers = append(layers, checked{layer, b})
+		if b.tokens < float64(cost) {
+			allowed = false
+		}
+	}
+
+	decision := Decision{Allowed: allowed, Remaining: math.MaxInt32}
+	for _, c := range layers {
+		if allowed {
+			c.bucket.tokens -= float64(cost)
+		}
+		if remaining := int(c.bucket.tokens) / cost; remaining < decision.Remaining {
+			decision.Remaining = remaining
+			decision.Limit = c.layer.BucketSize
+		}
+		if c.layer.Rate <= 0 {
+			continue
+		}
+		if reset := secondsToDuration((float64(c.layer.BucketSize) - c.bucket.tokens) / c.layer.Rate); reset > decision.Reset {
+			decision.Reset = reset
+		}
+		if !allowed && c.bucket.tokens < float64(cost) {
+			if wait := secondsToDuration((float64(cost) - c.bucket.tokens) / c.layer.Rate); wait > decision.RetryAfter {
+				decision.RetryAfter = wait
+			}
+		}
+	}
+	if len(layers) == 0 {
+		decision.Remaining = 0
+	}
+	return decision
+}
+
+// Reconfigure swaps in new layers and costs. Buckets of layers that keep
+// their name carry their tokens over, capped at the new bucket size. Costs
+// that fail ValidateCosts leave the old configuration in place.
+func (l *LayeredLimiter) Reconfigure(layers []Layer, costs map[string]int) error {
+	if err := ValidateCosts(layers, costs); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sizes := make(map[string]int, len(layers))
+	for _, layer := range layers {
+		sizes[layer.Name] = layer.BucketSize
+	}
+	for key, elem := range l.buckets {
+		b := elem.Value.(*layerBucket)
+		size, ok := sizes[b.layer]
+		if !ok {
+			l.order.Remove(elem)
+			delete(l.buckets, key)
+			continue
+		}
+		b.tokens = math.Min(b.tokens, float64(size))
+	}
+	l.layers = layers
+	l.costs = costs
+	return nil
+}
+
+func (l *LayeredLimiter) bucket(layer Layer, key string, now time.Time) *layerBucket {
+	id := layer.Name + "|" + key
+	elem, ok := l.buckets[id]
+	if !ok {
+		b := &layerBucket{id: id, layer: layer.Name, tokens: float64(layer.BucketSize), lastRefill: now}
+		l.buckets[id] = l.order.PushFront(b)
+		for l.order.Len() > l.maxBuckets {
+			oldest := l.order.Back()
+			l.order.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*layerBucket).id)
+		}
+		return b
+	}
+	l.order.MoveToFront(elem)
+	b := elem.Value.(*layerBucket)
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = math.Min(float64(layer.BucketSize), b.tokens+elapsed*layer.Rate)
+	b.lastRefill = now
+	return b
+}
+
+// subject picks the bucket key for a scope. Requests without a tenant skip
+// tenant layers rather than sharing one anonymous tenant bucket.
+func subject(scope Scope, req RequestInfo) (string, bool) {
+	switch scope {
+	case ScopeUser:
+		return req.UserId, req.UserId != ""
+	case ScopeTenant:
+		return req.TenantId, req.TenantId != ""
+	case ScopeGlobal:
+		return "", true
+	}
+	return "", false
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
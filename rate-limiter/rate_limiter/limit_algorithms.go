@@ -0,0 +1,228 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+type LimitBounds struct {
+	Initial int
+	Min     int
+	Max     int
+}
+
+func (b LimitBounds) clamp(limit float64) float64 {
+	return math.Max(float64(b.Min), math.Min(float64(b.Max), limit))
+}
+
+func (b LimitBounds) withDefaults() LimitBounds {
+	if b.Min <= 0 {
+		b.Min = 1
+	}
+	if b.Max <= 0 {
+		b.Max = 1000
+	}
+	if b.Initial <= 0 {
+		b.Initial = b.Min
+	}
+	return b
+}
+
+// AIMD grows the limit by one after each successful request that used most
+// of it and cuts it by BackoffRatio on a failure or a response slower than
+// Timeout, the same shape TCP congestion control uses. The limit climbs until
+// latency reaches Timeout, so set it just above the downstream's latency
+// when unloaded; a generous timeout lets the queue grow to match it.
+type AIMD struct {
+	bounds       LimitBounds
+	backoffRatio float64
+	timeout      time.Duration
+	limit        float64
+}
+
+func NewAIMD(bounds LimitBounds, backoffRatio float64, timeout time.Duration) *AIMD {
+	bounds = bounds.withDefaults()
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+	return &AIMD{
+		bounds:       bounds,
+		backoffRatio: backoffRatio,
+		timeout:      timeout,
+		limit:        float64(bounds.Initial),
+	}
+}
+
+func (a *AIMD) Update(rtt time.Duration, inflight int, success bool) int {
+	switch {
+	case !success || (a.timeout > 0 && rtt > a.timeout):
+		a.limit = a.bounds.clamp(a.limit * a.backoffRatio)
+	case float64(inflight)*2 >= a.limit:
+		a.limit = a.bounds.clamp(a.limit + 1)
+	}
+	return a.Limit()
+}
+
+func (a *AIMD) Limit() int {
+	return int(a.limit)
+}
+
+// Vegas estimates the queue at the downstream as
+// limit * (1 - minRTT/rtt), where minRTT is the latency seen with no load.
+// Below Alpha queued requests it grows the limit, above Beta it shrinks it,
+// in steps of log10(limit). With Alpha 1 and Beta 2 the limit settles one or
+// two requests above what the downstream serves without queueing.
+type Vegas struct {
+	bounds LimitBounds
+	alpha  float64
+	beta   float64
+	minRTT time.Duration
+	limit  float64
+}
+
+func NewVegas(bounds LimitBounds) *Vegas {
+	bounds = bounds.withDefaults()
+	return &Vegas{
+		bounds: bounds,
+		alpha:  1,
+		beta:   2,
+		limit:  float64(bounds.Initial),
+	}
+}
+
+func (v *Vegas) Update(rtt time.Duration, inflight int, success bool) int {
+	if rtt <= 0 {
+		return v.Limit()
+	}
+	if v.minRTT == 0 || rtt < v.minRTT {
+		v.minRTT = rtt
+	}
+	step := math.Max(1, math.Log10(v.limit))
+	if !success {
+		v.limit = v.bounds.clamp(v.limit - step)
+		return v.Limit()
+	}
+	// Only grow when the limit is actually being used.
+	if float64(inflight)*2 < v.limit {
+		return v.Limit()
+	}
+
+	queue := v.limit * (1 - float64(v.minRTT)/float64(rtt))
+	switch {
+	case queue < v.alpha:
+		v.limit = v.bounds.clamp(v.limit + step)
+	case queue > v.beta:
+		v.limit = v.bounds.clamp(v.limit - step)
+	}
+	return v.Limit()
+}
+
+func (v *Vegas) Limit() int {
+	return int(v.limit)
+}
+
+// Gradient compares a short-term latency average with a long-term baseline.
+// Their ratio, clamped to [0.5, 1], scales the limit down as latency rises,
+// and a queue allowance of log10(limit) lets it probe upwards when latency
+// is steady. The new limit is blended in with Smoothing. The baseline drops
+// quickly to faster samples but creeps up slowly, so sustained overload is
+// not mistaken for the new normal; the limit settles about one allowance
+// above what the downstream serves without queueing.
+type Gradient struct {
+	bounds    LimitBounds
+	smoothing float64
+	shortRTT  float64
+	longRTT   float64
+	limit     float64
+}
+
+func NewGradient(bounds LimitBounds, smoothing float64) *Gradient {
+	bounds = bounds.withDefaults()
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	return &Gradient{
+		bounds:    bounds,
+		smoothing: smoothing,
+		limit:     float64(bounds.Initial),
+	}
+}
+
+func (g *Gradient) Update(rtt time.Duration, inflight int, success bool) int {
+	sample := float64(rtt)
+	if g.longRTT == 0 {
+		g.shortRTT, g.longRTT = sample, sample
+	}
+	g.shortRTT = 0.9*g.shortRTT + 0.1*sample
+	if sample < g.longRTT {
+		g.longRTT = 0.9*g.longRTT + 0.1*sample
+	} else {
+		g.longRTT = 0.999*g.longRTT + 0.001*sample
+	}
+	if !success {
+		g.limit = g.bounds.clamp(g.limit * 0.9)
+		return g.Limit()
+	}
+	// Unused capacity says nothing about the downstream.
+	if float64(inflight)*2 < g.limit {
+		return g.Limit()
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.longRTT/g.shortRTT))
+	next := g.limit*gradient + math.Max(1, math.Log10(g.limit))
+	g.limit = g.bounds.clamp(g.limit*(1-g.smoothing) + next*g.smoothing)
+	return g.Limit()
+}
+
+func (g *Gradient) Limit() int {
+	return int(g.limit)
+}
+
+// Windowed batches samples and feeds the wrapped algorithm one aggregate
+// per window: the average latency, the highest inflight count and a failure
+// if any request in the window failed. Updating on every single response
+// makes the limit overreact to responses that started under an older limit.
+type Windowed struct {
+	delegate    LimitAlgorithm
+	window      time.Duration
+	minSamples  int
+	windowStart time.Time
+	samples     int
+	totalRTT    time.Duration
+	maxInflight int
+	failed      bool
+}
+
+func NewWindowed(delegate LimitAlgorithm, window time.Duration, minSamples int) *Windowed {
+	return &Windowed{
+		delegate:   delegate,
+		window:     window,
+		minSamples: minSamples,
+	}
+}
+
+func (w *Windowed) Update(rtt time.Duration, inflight int, success bool) int {
+	now := time.Now()
+	if w.samples == 0 {
+		w.windowStart = now
+	}
+	w.samples++
+	w.totalRTT += rtt
+	if inflight > w.maxInflight {
+		w.maxInflight = inflight
+	}
+	if !success {
+		w.failed = true
+	}
+	if w.samples < w.minSamples || now.Sub(w.windowStart) < w.window {
+		return w.delegate.Limit()
+	}
+
+	limit := w.delegate.Update(w.totalRTT/time.Duration(w.samples), w.maxInflight, !w.failed)
+	w.samples, w.totalRTT, w.maxInflight, w.failed = 0, 0, 0, false
+	return limit
+}
+
+func (w *Windowed) Limit() int {
+	return w.delegate.Limit()
+}
@@ -1,11 +1,13 @@
 package limiter
 
 import (
-	"math"
 	"sync"
 	"time"
 )
 
+// TokenBucket is a single bucket shared by every caller: the id passed to
+// IsRequestAllowed and Decide is ignored. Use KeyedTokenBucket to limit each
+// client separately.
 type TokenBucket struct {
 	Rate       int
 	BucketSize int
@@ -24,18 +26,40 @@ func NewTokenBucket(rate, bucketSize int) *TokenBucket {
 }
 
 func (t *TokenBucket) IsRequestAllowed(requestId string) bool {
+	return t.Decide(requestId).Allowed
+}
+
+func (t *TokenBucket) Decide(requestId string) Decision {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	now := time.Now()
-	elapsed := now.Sub(t.LastRefill).Seconds()
-	refillToken := int(elapsed) * t.Rate
-	if refillToken > 0 {
-		t.Tokens = int(math.Min(float64(refillToken), float64(t.BucketSize)))
-		t.LastRefill = now
+	elapsed := int(now.Sub(t.LastRefill) / time.Second)
+	if elapsed > 0 {
+		t.Tokens = min(t.Tokens+elapsed*t.Rate, t.BucketSize)
+		t.LastRefill = t.LastRefill.Add(time.Duration(elapsed) * time.Second)
 	}
+
+	decision := Decision{Limit: t.BucketSize}
 	if t.Tokens > 0 {
 		t.Tokens--
-		return true
+		decision.Allowed = true
+	}
+	decision.Remaining = t.Tokens
+
+	nextRefill := t.LastRefill.Add(time.Second).Sub(now)
+	if missing := t.BucketSize - t.Tokens; missing > 0 && t.Rate > 0 {
+		refills := (missing + t.Rate - 1) / t.Rate
+		decision.Reset = nextRefill + time.Duration(refills-1)*time.Second
+	}
+	if !decision.Allowed {
+		decision.RetryAfter = nextRefill
+	}
+	return decision
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
 	}
-	return false
+	return b
 }
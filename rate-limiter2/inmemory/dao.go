@@ -1,6 +1,7 @@
 package inmemory
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -32,3 +33,38 @@ func (m *MemoryDB) SetBucket(apiKey string, bucket *Bucket) {
 	defer m.mu.Unlock()
 	m.Buckets[apiKey] = bucket
 }
+
+// Take adds rate tokens for every whole second since the last refill, capped
+// at bucketSize, and then consumes one token if there is one. A rate of zero
+// or less never refills.
+func (m *MemoryDB) Take(ctx context.Context, apiKey string, rate, bucketSize int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	bucket := m.Buckets[apiKey]
+	if bucket == nil {
+		bucket = &Bucket{
+			Tokens:     bucketSize,
+			LastRefill: now,
+		}
+		m.Buckets[apiKey] = bucket
+	}
+	elapsed := int(now.Sub(bucket.LastRefill) / time.Second)
+	if elapsed > 0 && rate > 0 {
+		bucket.Tokens = min(bucket.Tokens+elapsed*rate, bucketSize)
+		bucket.LastRefill = bucket.LastRefill.Add(time.Duration(elapsed) * time.Second)
+	}
+	if bucket.Tokens > 0 {
+		bucket.Tokens--
+		return true, nil
+	}
+	return false, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,10 @@
+package limiter
+
+import "context"
+
+// BucketStore persists token buckets. Take refills the bucket for apiKey
+// and consumes one token if available, as a single atomic step, so several
+// limiter replicas can share one store without racing each other.
+type BucketStore interface {
+	Take(ctx context.Context, apiKey string, rate, bucketSize int) (bool, error)
+}
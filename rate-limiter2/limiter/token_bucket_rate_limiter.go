@@ -1,56 +1,55 @@
 package limiter
 
 import (
-	"github.com/rishu/design/rate-limiter2/inmemory"
-	"sync"
-	"time"
+	"context"
+	"log"
+	"sync/atomic"
 )
 
 type TokenBucket struct {
 	Rate       int
 	BucketSize int
-	MemoryDB   *inmemory.MemoryDB
-	mu         sync.Mutex
+	Store      BucketStore
+	// FailOpen decides what IsRequestAllowed answers when the store fails:
+	// true lets the request through rather than taking the API down with
+	// the store, false rejects it. NewTokenBucket sets it to true.
+	FailOpen bool
+	// OnStoreError is told about every store failure. Without it failures
+	// are logged.
+	OnStoreError func(apiKey string, err error)
+	storeErrors  atomic.Uint64
 }
 
-func NewTokenBucket(rate, bucketSize int, db *inmemory.MemoryDB) *TokenBucket {
+func NewTokenBucket(rate, bucketSize int, store BucketStore) *TokenBucket {
 	return &TokenBucket{
 		Rate:       rate,
 		BucketSize: bucketSize,
-		MemoryDB:   db,
+		Store:      store,
+		FailOpen:   true,
 	}
 }
 
+func (t *TokenBucket) Allow(ctx context.Context, apiKey string) (bool, error) {
+	return t.Store.Take(ctx, apiKey, t.Rate, t.BucketSize)
+}
+
+// IsRequestAllowed answers FailOpen when the store cannot be reached, after
+// reporting the error.
 func (t *TokenBucket) IsRequestAllowed(apiKey string) bool {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	bucket := t.MemoryDB.GetBucket(apiKey)
-	if bucket == nil {
-		bucket = &inmemory.Bucket{
-			Tokens:     t.BucketSize,
-			LastRefill: time.Now(),
+	allowed, err := t.Allow(context.Background(), apiKey)
+	if err != nil {
+		t.storeErrors.Add(1)
+		if t.OnStoreError != nil {
+			t.OnStoreError(apiKey, err)
+		} else {
+			log.Printf("rate limiter: store error for %q, fail open=%v: %v", apiKey, t.FailOpen, err)
 		}
-		t.MemoryDB.SetBucket(apiKey, bucket)
-	}
-	now := time.Now()
-	elapsed := now.Sub(bucket.LastRefill).Seconds()
-	refillTokens := int(elapsed) * t.Rate
-	if refillTokens > 0 {
-		bucket.Tokens = min(refillTokens, t.BucketSize)
-		bucket.LastRefill = now
-		t.MemoryDB.SetBucket(apiKey, bucket)
+		return t.FailOpen
 	}
-	if bucket.Tokens > 0 {
-		bucket.Tokens--
-		t.MemoryDB.SetBucket(apiKey, bucket)
-		return true
-	}
-	return false
+	return allowed
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+// StoreErrors is how many IsRequestAllowed calls hit a store failure.
+func (t *TokenBucket) StoreErrors() uint64 {
+	return t.storeErrors.Load()
 }
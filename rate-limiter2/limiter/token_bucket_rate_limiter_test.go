@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type downStore struct{}
+
+func (downStore) Take(ctx context.Context, apiKey string, rate, bucketSize int) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestStoreFailures(t *testing.T) {
+	for _, failOpen := range []bool{true, false} {
+		tb := NewTokenBucket(1, 5, downStore{})
+		tb.FailOpen = failOpen
+		var reported []string
+		tb.OnStoreError = func(apiKey string, err error) { reported = append(reported, apiKey) }
+
+		if got := tb.IsRequestAllowed("a"); got != failOpen {
+			t.Fatalf("FailOpen=%v: allowed = %v", failOpen, got)
+		}
+		if len(reported) != 1 || reported[0] != "a" || tb.StoreErrors() != 1 {
+			t.Fatalf("FailOpen=%v: reported %v, counted %d", failOpen, reported, tb.StoreErrors())
+		}
+	}
+}
+
+func TestFailsOpenByDefault(t *testing.T) {
+	if !NewTokenBucket(1, 5, downStore{}).IsRequestAllowed("a") {
+		t.Fatal("NewTokenBucket should fail open")
+	}
+}
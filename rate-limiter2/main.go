@@ -1,13 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
 	"github.com/rishu/design/rate-limiter2/inmemory"
 	"github.com/rishu/design/rate-limiter2/limiter"
-	"time"
+	"github.com/rishu/design/rate-limiter2/redisstore"
 )
 
 func main() {
+	redisAddr := flag.String("redis", "", "Redis address for the shared-store demo; skipped when empty")
+	flag.Parse()
+
 	memoryDB := inmemory.NewMemoryDB()
 	rateLimiter := limiter.NewTokenBucket(1, 10, memoryDB)
 	apiKey := "user-123"
@@ -19,4 +26,30 @@ func main() {
 		}
 		time.Sleep(500 * time.Millisecond)
 	}
+
+	if *redisAddr == "" {
+		fmt.Println("pass -redis host:port to run the shared Redis store demo")
+		return
+	}
+	client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	defer client.Close()
+
+	// Two API replicas sharing one Redis store enforce a single limit of 5.
+	store := redisstore.NewStore(client, "ratelimit:")
+	replicas := []*limiter.TokenBucket{
+		limiter.NewTokenBucket(1, 5, store),
+		limiter.NewTokenBucket(1, 5, store),
+	}
+	for _, replica := range replicas {
+		// Fail closed: a limiter that cannot reach Redis rejects traffic.
+		replica.FailOpen = false
+	}
+	for i := 0; i < 8; i++ {
+		replica := i % len(replicas)
+		if replicas[replica].IsRequestAllowed(apiKey) {
+			fmt.Printf("Request %d via replica %d allowed\n", i+1, replica)
+		} else {
+			fmt.Printf("Request %d via replica %d denied\n", i+1, replica)
+		}
+	}
 }
@@ -0,0 +1,79 @@
+package redisstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// takeScript mirrors inmemory.MemoryDB.Take. The bucket is a hash with the
+// token count and the last refill time in milliseconds. Time comes from the
+// Redis server so replicas with skewed clocks still agree. A ttl of 0 keeps
+// the bucket forever.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local size = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = size
+	ts = now
+end
+
+local elapsed = math.floor((now - ts) / 1000)
+if elapsed > 0 then
+	tokens = math.min(tokens + elapsed * rate, size)
+	ts = ts + elapsed * 1000
+end
+
+local allowed = 0
+if tokens > 0 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', ts)
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+else
+	redis.call('PERSIST', KEYS[1])
+end
+return allowed
+`)
+
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewStore(client *redis.Client, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Take runs the refill-and-take script. Idle buckets expire once they would
+// have refilled completely, since a fresh bucket starts full anyway. A rate
+// of zero or less never refills, so those buckets never expire either:
+// expiring them would hand out a full bucket again.
+func (s *Store) Take(ctx context.Context, apiKey string, rate, bucketSize int) (bool, error) {
+	var ttl time.Duration
+	if rate > 0 {
+		ttl = time.Duration(bucketSize/rate+1) * time.Second
+	} else {
+		rate = 0
+	}
+	allowed, err := takeScript.Run(ctx, s.client, []string{s.prefix + apiKey}, rate, bucketSize, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
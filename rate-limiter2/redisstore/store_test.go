@@ -0,0 +1,106 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Unix(1_700_000_000, 0))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(client, "rl:"), server
+}
+
+func take(t *testing.T, s *Store, key string, rate, size int) bool {
+	t.Helper()
+	allowed, err := s.Take(context.Background(), key, rate, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return allowed
+}
+
+func TestTakeUntilEmptyThenRefill(t *testing.T) {
+	s, server := newTestStore(t)
+
+	for i := 0; i < 3; i++ {
+		if !take(t, s, "a", 1, 3) {
+			t.Fatalf("request %d denied with tokens left", i+1)
+		}
+	}
+	if take(t, s, "a", 1, 3) {
+		t.Fatal("allowed with an empty bucket")
+	}
+	if !take(t, s, "b", 1, 3) {
+		t.Fatal("buckets are not per key")
+	}
+
+	server.SetTime(time.Unix(1_700_000_002, 0))
+	for i := 0; i < 2; i++ {
+		if !take(t, s, "a", 1, 3) {
+			t.Fatalf("refilled token %d denied", i+1)
+		}
+	}
+	if take(t, s, "a", 1, 3) {
+		t.Fatal("refilled more than rate per second")
+	}
+}
+
+func TestBucketsExpireOnceFull(t *testing.T) {
+	s, server := newTestStore(t)
+
+	take(t, s, "a", 2, 10)
+	if ttl := server.TTL("rl:a"); ttl != 6*time.Second {
+		t.Fatalf("ttl = %v, want the 6s it takes to refill completely", ttl)
+	}
+}
+
+func TestZeroRateNeverRefillsOrExpires(t *testing.T) {
+	s, server := newTestStore(t)
+
+	if !take(t, s, "a", 0, 1) {
+		t.Fatal("first request denied")
+	}
+	if ttl := server.TTL("rl:a"); ttl != 0 {
+		t.Fatalf("ttl = %v, a zero-rate bucket must not expire", ttl)
+	}
+	server.FastForward(time.Hour)
+	server.SetTime(time.Unix(1_700_003_600, 0))
+	if take(t, s, "a", 0, 1) {
+		t.Fatal("zero-rate bucket refilled")
+	}
+}
+
+func TestReplicasShareOneBucket(t *testing.T) {
+	s, server := newTestStore(t)
+	other := NewStore(redis.NewClient(&redis.Options{Addr: server.Addr()}), "rl:")
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		store := s
+		if i%2 == 1 {
+			store = other
+		}
+		if take(t, store, "a", 1, 5) {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Fatalf("allowed %d across replicas, want 5", allowed)
+	}
+}
+
+func TestRedisDown(t *testing.T) {
+	s, server := newTestStore(t)
+	server.Close()
+	if _, err := s.Take(context.Background(), "a", 1, 5); err == nil {
+		t.Fatal("want an error with Redis down")
+	}
+}
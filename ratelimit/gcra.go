@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+// GCRA is the generic cell rate algorithm, a leaky bucket that stores a
+// single "theoretical arrival time" per client. Requests drain at one per
+// Window/Request and up to Request of them may arrive back to back.
+type GCRA struct {
+	interval time.Duration
+	burst    time.Duration
+	tat      map[string]time.Time
+	mu       sync.Mutex
+}
+
+// NewGCRA fails unless the config allows at least one request and the
+// window is long enough to space them at least a nanosecond apart.
+func NewGCRA(config *Config) (*GCRA, error) {
+	if config.Request <= 0 {
+		return nil, fmt.Errorf("gcra: request limit must be positive, got %d", config.Request)
+	}
+	interval := config.Window / time.Duration(config.Request)
+	if interval <= 0 {
+		return nil, fmt.Errorf("gcra: window %v is too short for %d requests", config.Window, config.Request)
+	}
+	return &GCRA{
+		interval: interval,
+		burst:    interval * time.Duration(config.Request),
+		tat:      make(map[string]time.Time),
+	}, nil
+}
+
+func (g *GCRA) Allow(clientId string) bool {
+	return g.Decide(clientId).Allowed
+}
+
+func (g *GCRA) Decide(clientId string) limiter.Decision {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	tat, exists := g.tat[clientId]
+	if !exists || tat.Before(now) {
+		tat = now
+	}
+	limit := int(g.burst / g.interval)
+	decision := limiter.Decision{Limit: limit}
+	next := tat.Add(g.interval)
+	if next.Sub(now) > g.burst {
+		decision.RetryAfter = next.Add(-g.burst).Sub(now)
+	} else {
+		g.tat[clientId] = next
+		tat = next
+		decision.Allowed = true
+	}
+	decision.Remaining = int((g.burst - tat.Sub(now)) / g.interval)
+	decision.Reset = tat.Sub(now)
+	return decision
+}
+
+func (g *GCRA) Forget(clientId string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.tat, clientId)
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"time"
+
+	"github.com/rishu/design/rate-limiter/middleware"
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
 )
 
 type IRateLimiter interface {
@@ -14,6 +19,18 @@ type IStrategy interface {
 	Allow(clientId string) bool
 }
 
+// IDecider is implemented by strategies that can report remaining quota and
+// reset times rather than a bare yes or no.
+type IDecider interface {
+	Decide(clientId string) limiter.Decision
+}
+
+// Forgetter is implemented by strategies that keep per-client state, so an
+// idle or surplus client can be dropped from memory.
+type Forgetter interface {
+	Forget(clientId string)
+}
+
 type Config struct {
 	Request int
 	Window  time.Duration
@@ -31,6 +48,13 @@ func (r *RateLimiter) Allow(clientId string) bool {
 	return r.strategy.Allow(clientId)
 }
 
+func (r *RateLimiter) Decide(clientId string) limiter.Decision {
+	if d, ok := r.strategy.(IDecider); ok {
+		return d.Decide(clientId)
+	}
+	return limiter.Decision{Allowed: r.strategy.Allow(clientId)}
+}
+
 type FixedWindow struct {
 	requests  int
 	window    time.Duration
@@ -40,20 +64,39 @@ type FixedWindow struct {
 }
 
 func (f *FixedWindow) Allow(clientId string) bool {
+	return f.Decide(clientId).Allowed
+}
+
+func (f *FixedWindow) Decide(clientId string) limiter.Decision {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	now := time.Now()
+	decision := limiter.Decision{Limit: f.requests}
 	ts, exists := f.timestamp[clientId]
-	if !exists || time.Now().Sub(ts) > f.window {
-		f.timestamp[clientId] = time.Now()
+	if !exists || now.Sub(ts) > f.window {
+		ts = now
+		f.timestamp[clientId] = ts
 		f.count[clientId] = 1
-		return true
-	}
-	if f.count[clientId] < f.requests {
+		decision.Allowed = true
+	} else if f.count[clientId] < f.requests {
 		f.count[clientId]++
-		return true
+		decision.Allowed = true
+	}
+	decision.Remaining = f.requests - f.count[clientId]
+	decision.Reset = ts.Add(f.window).Sub(now)
+	if !decision.Allowed {
+		decision.RetryAfter = decision.Reset
 	}
-	return false
+	return decision
+}
+
+func (f *FixedWindow) Forget(clientId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.timestamp, clientId)
+	delete(f.count, clientId)
 }
 
 func NewFixedWindow(config *Config) *FixedWindow {
@@ -73,27 +116,41 @@ type SlidingWindow struct {
 }
 
 func (s *SlidingWindow) Allow(clientId string) bool {
+	return s.Decide(clientId).Allowed
+}
+
+func (s *SlidingWindow) Decide(clientId string) limiter.Decision {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	now := time.Now()
-	records, exists := s.records[clientId]
-	if !exists {
-		s.records[clientId] = []time.Time{now}
-		return true
-	}
 	var updatedRecord []time.Time
-	for _, ts := range records {
+	for _, ts := range s.records[clientId] {
 		if now.Sub(ts) <= s.window {
 			updatedRecord = append(updatedRecord, ts)
 		}
 	}
+	decision := limiter.Decision{Limit: s.requests}
 	if len(updatedRecord) < s.requests {
 		updatedRecord = append(updatedRecord, now)
-		s.records[clientId] = updatedRecord
-		return true
+		decision.Allowed = true
 	}
 	s.records[clientId] = updatedRecord
-	return false
+
+	decision.Remaining = s.requests - len(updatedRecord)
+	if len(updatedRecord) > 0 {
+		decision.Reset = updatedRecord[len(updatedRecord)-1].Add(s.window).Sub(now)
+		if !decision.Allowed {
+			decision.RetryAfter = updatedRecord[0].Add(s.window).Sub(now)
+		}
+	}
+	return decision
+}
+
+func (s *SlidingWindow) Forget(clientId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, clientId)
 }
 
 func NewSlidingWindow(config *Config) *SlidingWindow {
@@ -113,30 +170,51 @@ type TokenBucket struct {
 }
 
 func (t *TokenBucket) Allow(clientId string) bool {
+	return t.Decide(clientId).Allowed
+}
+
+func (t *TokenBucket) Decide(clientId string) limiter.Decision {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	now := time.Now()
 	ls, ok := t.lastAccess[clientId]
 	if !ok {
-		t.tokens[clientId] = t.cap - 1
+		t.tokens[clientId] = t.cap
 		t.lastAccess[clientId] = now
-		return true
+		ls = now
 	}
 	elapsed := now.Sub(ls).Seconds()
 	newTokens := int(elapsed * t.fillRate)
 	if newTokens > 0 {
 		t.lastAccess[clientId] = now
+		ls = now
 		t.tokens[clientId] = min(t.cap, t.tokens[clientId]+newTokens)
 	}
+
+	decision := limiter.Decision{Limit: t.cap}
 	if t.tokens[clientId] > 0 {
 		t.tokens[clientId]--
-		return true
+		decision.Allowed = true
+	}
+	decision.Remaining = t.tokens[clientId]
+	perToken := time.Duration(float64(time.Second) / t.fillRate)
+	decision.Reset = time.Duration(t.cap-t.tokens[clientId]) * perToken
+	if !decision.Allowed {
+		decision.RetryAfter = ls.Add(perToken).Sub(now)
 	}
-	return false
+	return decision
 }
 
-func NewTokenBucket(config *Config, lastAccess map[string]time.Time, mu sync.Mutex) *TokenBucket {
+func (t *TokenBucket) Forget(clientId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.tokens, clientId)
+	delete(t.lastAccess, clientId)
+}
+
+func NewTokenBucket(config *Config) *TokenBucket {
 	return &TokenBucket{
 		cap:        config.Request,
 		tokens:     make(map[string]int),
@@ -159,5 +237,54 @@ func main() {
 	}
 	fixed := NewFixedWindow(config)
 	rate := NewRateLimiter(fixed)
-	fmt.Print(rate.Allow("hell"))
+	fmt.Println(rate.Allow("hell"))
+
+	counter := NewRateLimiter(NewSlidingWindowCounter(config))
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if counter.Allow("client-1") {
+			allowed++
+		}
+	}
+	fmt.Println(allowed) // 6
+
+	g, err := NewGCRA(config)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	gcra := NewRateLimiter(g)
+	allowed = 0
+	for i := 0; i < 10; i++ {
+		if gcra.Allow("client-1") {
+			allowed++
+		}
+	}
+	fmt.Println(allowed) // 6
+
+	tracked := NewTrackedStrategy(NewSlidingWindowCounter(config), 1000, time.Minute)
+	tracked.StartReaper(10 * time.Second)
+	defer tracked.StopReaper()
+	for i := 0; i < 5000; i++ {
+		tracked.Allow(fmt.Sprintf("client-%d", i))
+	}
+	fmt.Println(tracked.Tracked()) // 1000
+
+	if _, err := NewGCRA(&Config{Request: 0, Window: time.Second}); err != nil {
+		fmt.Println(err) // gcra: request limit must be positive, got 0
+	}
+	g, err = NewGCRA(&Config{Request: 2, Window: 2 * time.Second})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	handler := middleware.RateLimit(NewRateLimiter(g), middleware.IPKey())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "ok")
+		}))
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		fmt.Println(rec.Code, rec.Header().Get("RateLimit-Remaining"), rec.Header().Get("Retry-After")) // 200 1, 200 0, 429 0 1
+	}
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+type windowCounter struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+// SlidingWindowCounter approximates a sliding window with two fixed windows:
+// the previous window's count is weighted by how much of it still overlaps
+// the sliding window. It keeps two integers per client instead of every
+// timestamp, and avoids the 2x burst FixedWindow allows at window edges.
+type SlidingWindowCounter struct {
+	requests int
+	window   time.Duration
+	counters map[string]*windowCounter
+	mu       sync.Mutex
+}
+
+func NewSlidingWindowCounter(config *Config) *SlidingWindowCounter {
+	return &SlidingWindowCounter{
+		requests: config.Request,
+		window:   config.Window,
+		counters: make(map[string]*windowCounter),
+	}
+}
+
+func (s *SlidingWindowCounter) Allow(clientId string) bool {
+	return s.Decide(clientId).Allowed
+}
+
+func (s *SlidingWindowCounter) Decide(clientId string) limiter.Decision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	start := now.Truncate(s.window)
+	c, exists := s.counters[clientId]
+	if !exists {
+		c = &windowCounter{start: start}
+		s.counters[clientId] = c
+	}
+	switch {
+	case start.Sub(c.start) >= 2*s.window:
+		c.previous, c.current = 0, 0
+		c.start = start
+	case start.Sub(c.start) >= s.window:
+		c.previous, c.current = c.current, 0
+		c.start = start
+	}
+
+	elapsed := now.Sub(start)
+	overlap := 1 - float64(elapsed)/float64(s.window)
+	decision := limiter.Decision{Limit: s.requests}
+	if float64(c.previous)*overlap+float64(c.current) < float64(s.requests) {
+		c.current++
+		decision.Allowed = true
+	}
+	estimate := float64(c.previous)*overlap + float64(c.current)
+	decision.Remaining = int(math.Max(0, math.Floor(float64(s.requests)-estimate)))
+
+	// The previous window stops counting at the end of this one and the
+	// current one stops counting a window later.
+	untilNext := s.window - elapsed
+	if c.current > 0 {
+		decision.Reset = untilNext + s.window
+	} else if c.previous > 0 {
+		decision.Reset = untilNext
+	}
+	if !decision.Allowed {
+		decision.RetryAfter = s.retryAfter(c, elapsed)
+	}
+	return decision
+}
+
+// retryAfter solves previous*(1-t/W) + current < requests for the earliest
+// t, rolling over into the next window if the current count alone is over.
+func (s *SlidingWindowCounter) retryAfter(c *windowCounter, elapsed time.Duration) time.Duration {
+	w := float64(s.window)
+	if c.current < s.requests && c.previous > 0 {
+		t := w * (1 - float64(s.requests-c.current)/float64(c.previous))
+		return time.Duration(t) - elapsed + 1
+	}
+	t := w * (1 - float64(s.requests)/float64(c.current))
+	if t < 0 {
+		t = 0
+	}
+	return s.window - elapsed + time.Duration(t) + 1
+}
+
+func (s *SlidingWindowCounter) Forget(clientId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, clientId)
+}
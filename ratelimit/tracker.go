@@ -0,0 +1,137 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
+)
+
+type trackedClient struct {
+	id       string
+	lastSeen time.Time
+}
+
+// TrackedStrategy wraps a strategy and bounds how many clients it remembers.
+// Clients are kept in least-recently-seen order: once maxClients is
+// exceeded the stalest one is forgotten, and the reaper drops clients that
+// have been idle longer than idleTimeout. A forgotten client simply starts
+// over with a fresh allowance. The wrapped strategy is only called with the
+// tracker's lock held, so a client cannot be forgotten between being seen
+// and being counted.
+type TrackedStrategy struct {
+	strategy    IStrategy
+	maxClients  int
+	idleTimeout time.Duration
+	order       *list.List
+	clients     map[string]*list.Element
+	stop        chan struct{}
+	done        chan struct{}
+	mu          sync.Mutex
+}
+
+func NewTrackedStrategy(strategy IStrategy, maxClients int, idleTimeout time.Duration) *TrackedStrategy {
+	return &TrackedStrategy{
+		strategy:    strategy,
+		maxClients:  maxClients,
+		idleTimeout: idleTimeout,
+		order:       list.New(),
+		clients:     make(map[string]*list.Element),
+	}
+}
+
+func (t *TrackedStrategy) Allow(clientId string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.touchLocked(clientId)
+	return t.strategy.Allow(clientId)
+}
+
+func (t *TrackedStrategy) Decide(clientId string) limiter.Decision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.touchLocked(clientId)
+	if d, ok := t.strategy.(IDecider); ok {
+		return d.Decide(clientId)
+	}
+	return limiter.Decision{Allowed: t.strategy.Allow(clientId)}
+}
+
+func (t *TrackedStrategy) touchLocked(clientId string) {
+	now := time.Now()
+	if elem, found := t.clients[clientId]; found {
+		elem.Value.(*trackedClient).lastSeen = now
+		t.order.MoveToFront(elem)
+	} else {
+		t.clients[clientId] = t.order.PushFront(&trackedClient{id: clientId, lastSeen: now})
+	}
+	for t.maxClients > 0 && t.order.Len() > t.maxClients {
+		t.removeOldestLocked()
+	}
+}
+
+func (t *TrackedStrategy) Tracked() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.order.Len()
+}
+
+// Reap forgets every client that has not been seen for idleTimeout.
+func (t *TrackedStrategy) Reap() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-t.idleTimeout)
+	for elem := t.order.Back(); elem != nil && elem.Value.(*trackedClient).lastSeen.Before(cutoff); elem = t.order.Back() {
+		t.removeOldestLocked()
+	}
+}
+
+func (t *TrackedStrategy) StartReaper(interval time.Duration) {
+	t.StopReaper()
+
+	t.mu.Lock()
+	stop, done := make(chan struct{}), make(chan struct{})
+	t.stop, t.done = stop, done
+	t.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				t.Reap()
+			}
+		}
+	}()
+}
+
+func (t *TrackedStrategy) StopReaper() {
+	t.mu.Lock()
+	stop, done := t.stop, t.done
+	t.stop, t.done = nil, nil
+	t.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (t *TrackedStrategy) removeOldestLocked() {
+	elem := t.order.Back()
+	t.order.Remove(elem)
+	id := elem.Value.(*trackedClient).id
+	delete(t.clients, id)
+	if f, ok := t.strategy.(Forgetter); ok {
+		f.Forget(id)
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"time"
+
+	"github.com/rishu/design/rate-limiter/middleware"
+	limiter "github.com/rishu/design/rate-limiter/rate_limiter"
 )
 
 type IRateLimiter interface {
@@ -18,75 +24,150 @@ type Config struct {
 	Window     time.Duration
 }
 
+func configFor(configs map[string]*Config, defaultConfig *Config, clientId string) *Config {
+	if cfg, ok := configs[clientId]; ok {
+		return cfg
+	}
+	return defaultConfig
+}
+
 type FixedWindow struct {
 	RequestCount map[string]int
 	TimeWindow   map[string]time.Time
 	ConfigMap    map[string]*Config
-	mu           sync.Mutex
+	// DefaultConfig applies to clients without an entry in ConfigMap. When
+	// it is nil such clients are always rejected as forbidden.
+	DefaultConfig *Config
+	mu            sync.Mutex
 }
 
-func NewFixedWindow() *FixedWindow {
+func NewFixedWindow(defaultConfig *Config) *FixedWindow {
 	return &FixedWindow{
-		RequestCount: make(map[string]int),
-		TimeWindow:   make(map[string]time.Time),
-		ConfigMap:    make(map[string]*Config),
+		RequestCount:  make(map[string]int),
+		TimeWindow:    make(map[string]time.Time),
+		ConfigMap:     make(map[string]*Config),
+		DefaultConfig: defaultConfig,
 	}
 }
 
 func (f *FixedWindow) IsAllow(clientId string) bool {
+	return f.Decide(clientId).Allowed
+}
+
+func (f *FixedWindow) Allow(clientId string) bool {
+	return f.IsAllow(clientId)
+}
+
+func (f *FixedWindow) Decide(clientId string) limiter.Decision {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	cfg := configFor(f.ConfigMap, f.DefaultConfig, clientId)
+	if cfg == nil {
+		return limiter.Decision{Forbidden: true}
+	}
+	now := time.Now()
+	decision := limiter.Decision{Limit: cfg.MaxRequest}
 	ts, ok := f.TimeWindow[clientId]
-	if !ok || time.Now().Sub(ts) > f.ConfigMap[clientId].Window {
-		f.TimeWindow[clientId] = time.Now()
+	if !ok || now.Sub(ts) > cfg.Window {
+		ts = now
+		f.TimeWindow[clientId] = ts
 		f.RequestCount[clientId] = 1
-		return true
-	}
-
-	if f.RequestCount[clientId] < f.ConfigMap[clientId].MaxRequest {
+		decision.Allowed = true
+	} else if f.RequestCount[clientId] < cfg.MaxRequest {
 		f.RequestCount[clientId]++
-		return true
+		decision.Allowed = true
+	}
+	decision.Remaining = cfg.MaxRequest - f.RequestCount[clientId]
+	decision.Reset = ts.Add(cfg.Window).Sub(now)
+	if !decision.Allowed {
+		decision.RetryAfter = decision.Reset
 	}
-	return false
+	return decision
 }
 
 type SlidingWindow struct {
 	TimeWindow map[string][]time.Time
 	ConfigMap  map[string]*Config
-	mu         sync.Mutex
+	// DefaultConfig applies to clients without an entry in ConfigMap. When
+	// it is nil such clients are always rejected as forbidden.
+	DefaultConfig *Config
+	mu            sync.Mutex
 }
 
-func NewSlidingWindow() *SlidingWindow {
+func NewSlidingWindow(defaultConfig *Config) *SlidingWindow {
 	return &SlidingWindow{
-		TimeWindow: make(map[string][]time.Time),
-		ConfigMap:  make(map[string]*Config),
+		TimeWindow:    make(map[string][]time.Time),
+		ConfigMap:     make(map[string]*Config),
+		DefaultConfig: defaultConfig,
 	}
 }
 
 func (s *SlidingWindow) IsAllow(clientId string) bool {
+	return s.Decide(clientId).Allowed
+}
+
+func (s *SlidingWindow) Allow(clientId string) bool {
+	return s.IsAllow(clientId)
+}
+
+func (s *SlidingWindow) Decide(clientId string) limiter.Decision {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
-
-	records, exist := s.TimeWindow[clientId]
-	if !exist {
-		s.TimeWindow[clientId] = []time.Time{time.Now()}
-		return true
+	cfg := configFor(s.ConfigMap, s.DefaultConfig, clientId)
+	if cfg == nil {
+		return limiter.Decision{Forbidden: true}
 	}
+	now := time.Now()
 
 	var updatedRecord []time.Time
-	for _, ts := range records {
-		if now.Sub(ts) <= s.ConfigMap[clientId].Window {
+	for _, ts := range s.TimeWindow[clientId] {
+		if now.Sub(ts) <= cfg.Window {
 			updatedRecord = append(updatedRecord, ts)
 		}
 	}
-	if len(updatedRecord) < s.ConfigMap[clientId].MaxRequest {
-		updatedRecord = append(updatedRecord, time.Now())
-		s.TimeWindow[clientId] = updatedRecord
-		return true
+	decision := limiter.Decision{Limit: cfg.MaxRequest}
+	if len(updatedRecord) < cfg.MaxRequest {
+		updatedRecord = append(updatedRecord, now)
+		decision.Allowed = true
 	}
 	s.TimeWindow[clientId] = updatedRecord
-	return false
+
+	decision.Remaining = cfg.MaxRequest - len(updatedRecord)
+	if len(updatedRecord) > 0 {
+		decision.Reset = updatedRecord[len(updatedRecord)-1].Add(cfg.Window).Sub(now)
+		if !decision.Allowed {
+			decision.RetryAfter = updatedRecord[0].Add(cfg.Window).Sub(now)
+		}
+	}
+	return decision
+}
+
+func main() {
+	sliding := NewSlidingWindow(&Config{MaxRequest: 10, Window: time.Minute})
+	sliding.ConfigMap["key-1"] = &Config{MaxRequest: 2, Window: 10 * time.Second}
+
+	handler := middleware.RateLimit(sliding, middleware.HeaderKey("X-API-Key"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "ok")
+		}))
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "key-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		fmt.Println(rec.Code, rec.Header().Get("RateLimit-Remaining"), rec.Header().Get("Retry-After")) // 200 1, 200 0, 429 0 10
+	}
+
+	// Without a default config, unknown clients are turned away for good.
+	strict := middleware.RateLimit(NewFixedWindow(nil), middleware.HeaderKey("X-API-Key"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "ok")
+		}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "stranger")
+	rec := httptest.NewRecorder()
+	strict.ServeHTTP(rec, req)
+	fmt.Println(rec.Code, rec.Header().Get("Retry-After")) // 403, no Retry-After
 }
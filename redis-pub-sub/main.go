@@ -2,98 +2,136 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
-	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rishu/design/pub-sub/pubsub"
+	"github.com/rishu/design/redis-pub-sub/streams"
 )
 
-// Message represents the data being sent in the pub-sub system.
-type Message struct {
-	Topic   string
-	Payload string
-}
-
-// Subscriber interface for any entity that wants to subscribe to topics.
-type Subscriber interface {
-	Process(msg Message)
-	GetID() string
-}
-
-// ConcreteSubscriber represents a subscriber in the distributed system.
+// ConcreteSubscriber acks everything it receives and reports it on a
+// channel.
 type ConcreteSubscriber struct {
-	id string
+	id       string
+	received chan pubsub.Message
+	ack      bool
 }
 
-func (cs *ConcreteSubscriber) Process(msg Message) {
+func (cs *ConcreteSubscriber) Consume(msg pubsub.Message) {
 	fmt.Printf("Subscriber %s received message: %s on topic: %s\n", cs.id, msg.Payload, msg.Topic)
+	if cs.ack {
+		msg.Ack()
+	}
+	cs.received <- msg
 }
 
-func (cs *ConcreteSubscriber) GetID() string {
+func (cs *ConcreteSubscriber) GetId() string {
 	return cs.id
 }
 
-// RedisPubSubService is the distributed implementation of the pub-sub system.
-type RedisPubSubService struct {
-	redisClient *redis.Client
-	lock        sync.RWMutex
+// PoisonSubscriber panics on every message, so it never acks.
+type PoisonSubscriber struct {
+	id       string
+	attempts chan struct{}
 }
 
-func NewRedisPubSubService(redisAddr string) *RedisPubSubService {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
+func (ps *PoisonSubscriber) Consume(msg pubsub.Message) {
+	ps.attempts <- struct{}{}
+	panic("cannot parse " + msg.Payload)
+}
 
-	return &RedisPubSubService{redisClient: client}
+func (ps *PoisonSubscriber) GetId() string {
+	return ps.id
 }
 
-func (rps *RedisPubSubService) Publish(topic string, payload string) {
-	err := rps.redisClient.Publish(context.Background(), topic, payload).Err()
-	if err != nil {
-		log.Printf("Failed to publish message: %v\n", err)
+// consume runs Consume in the background and returns a function that
+// cancels it and waits for it to return.
+func consume(b pubsub.Broker, topic, group string, sub pubsub.Subscriber) (stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Consume(ctx, topic, group, sub) }()
+	return func() error {
+		cancel()
+		return <-done
 	}
 }
 
-func (rps *RedisPubSubService) Subscribe(topic string, sub Subscriber) {
-	go func() {
-		pubsub := rps.redisClient.Subscribe(context.Background(), topic)
-		defer pubsub.Close()
-
-		// Listen for messages
-		for {
-			msg, err := pubsub.ReceiveMessage(context.Background())
-			if err != nil {
-				log.Printf("Error receiving message: %v\n", err)
-				continue
-			}
-			sub.Process(Message{Topic: msg.Channel, Payload: msg.Payload})
-		}
-	}()
+// fanOut publishes through any broker: two groups on one topic each get
+// every message, and the members of a group share them.
+func fanOut(b pubsub.Broker) int {
+	received := make(chan pubsub.Message, 16)
+	stops := []func() error{
+		consume(b, "orders", "billing", &ConcreteSubscriber{id: "billing-1", received: received, ack: true}),
+		consume(b, "orders", "billing", &ConcreteSubscriber{id: "billing-2", received: received, ack: true}),
+		consume(b, "orders", "shipping", &ConcreteSubscriber{id: "shipping-1", received: received, ack: true}),
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		b.Publish(pubsub.Message{Topic: "orders", Key: fmt.Sprintf("order-%d", i), Payload: fmt.Sprintf("order %d", i)})
+	}
+	for i := 0; i < 6; i++ {
+		<-received
+	}
+	for _, stop := range stops {
+		stop()
+	}
+	return 6
 }
 
 func main() {
-	// Initialize Redis Pub-Sub service
-	redisAddr := "localhost:6379" // Redis server address
-	pubSubService := NewRedisPubSubService(redisAddr)
-
-	// Create subscribers
-	sub1 := &ConcreteSubscriber{id: "sub1"}
-	sub2 := &ConcreteSubscriber{id: "sub2"}
-
-	// Subscribe to topics
-	pubSubService.Subscribe("topic1", sub1)
-	pubSubService.Subscribe("topic1", sub2)
-
-	// Simulate publishers
-	go func() {
-		for i := 1; i <= 5; i++ {
-			pubSubService.Publish("topic1", fmt.Sprintf("Message %d to topic1", i))
-			time.Sleep(1 * time.Second)
-		}
-	}()
-
-	// Keep the main function running to listen for messages
-	select {}
+	redisAddr := flag.String("redis", "", "Redis address for the Redis Streams demo; skipped when empty")
+	flag.Parse()
+
+	// The same code runs against the in-process broker and Redis Streams.
+	memory := pubsub.NewPubSubService(pubsub.Config{})
+	fmt.Println("in-process deliveries:", fanOut(memory)) // 6
+	memory.Close()
+
+	if *redisAddr == "" {
+		fmt.Println("pass -redis host:port to run the Redis Streams demo")
+		return
+	}
+	client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	defer client.Close()
+
+	broker := streams.NewBroker(client, streams.Config{
+		Block:         50 * time.Millisecond,
+		ClaimIdle:     200 * time.Millisecond,
+		ClaimInterval: 50 * time.Millisecond,
+		MaxDeliveries: 3,
+	})
+
+	fmt.Println("redis deliveries:", fanOut(broker)) // 6
+
+	// A consumer that dies before acking leaves its entries pending; another
+	// member of the group claims them once they have been idle long enough.
+	received := make(chan pubsub.Message, 16)
+	stopCrasher := consume(broker, "payments", "ledger", &ConcreteSubscriber{id: "crasher", received: received})
+	time.Sleep(100 * time.Millisecond)
+	broker.Publish(pubsub.Message{Topic: "payments", Payload: "payment 1"})
+	first := <-received
+	stopCrasher()
+
+	stopRescuer := consume(broker, "payments", "ledger", &ConcreteSubscriber{id: "rescuer", received: received, ack: true})
+	claimed := <-received
+	fmt.Println("claimed after crash:", claimed.ID == first.ID)         // true
+	fmt.Println("consume returns nil on cancel:", stopRescuer() == nil) // true
+
+	// A message that keeps failing is moved to the dead-letter stream.
+	attempts := make(chan struct{}, 16)
+	dead := make(chan pubsub.Message, 1)
+	stopDLQ := consume(broker, "$dlq.invoices", "ops", &ConcreteSubscriber{id: "ops", received: dead, ack: true})
+	consume(broker, "invoices", "mailer", &PoisonSubscriber{id: "mailer", attempts: attempts})
+	time.Sleep(100 * time.Millisecond)
+	broker.Publish(pubsub.Message{Topic: "invoices", Payload: "garbled"})
+	msg := <-dead
+	fmt.Println("dead-lettered after", len(attempts), "attempts:", msg.Payload) // 3 garbled
+	stopDLQ()
+
+	// Close stops every remaining consumer, here the poisoned mailer.
+	broker.Close()
+	fmt.Println("broker closed")
 }
@@ -0,0 +1,222 @@
+// Package streams implements pubsub.Broker on Redis Streams. Each topic is a
+// stream, each group a Redis consumer group and each subscriber a consumer,
+// so a message stays pending until it is acked and can be claimed by
+// another consumer if its owner dies.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+var ErrClosed = errors.New("streams: broker closed")
+
+type Config struct {
+	// Prefix is prepended to topic names to form stream keys.
+	Prefix string
+	// MaxLen caps each stream, trimming the oldest entries approximately;
+	// zero keeps everything.
+	MaxLen int64
+	// Batch is how many entries one read or claim fetches and Block how
+	// long a read waits for new ones.
+	Batch int64
+	Block time.Duration
+	// Pending entries idle for ClaimIdle are claimed by whichever consumer
+	// of the group checks next; each consumer checks every ClaimInterval.
+	ClaimIdle     time.Duration
+	ClaimInterval time.Duration
+	// After MaxDeliveries attempts an entry is moved to the topic's
+	// dead-letter stream, the topic behind DeadLetterPrefix.
+	MaxDeliveries    int64
+	DeadLetterPrefix string
+	// Timeout bounds Publish and each Ack.
+	Timeout time.Duration
+}
+
+type Broker struct {
+	client *redis.Client
+	cfg    Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed bool
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+var _ pubsub.Broker = (*Broker)(nil)
+
+func NewBroker(client *redis.Client, cfg Config) *Broker {
+	if cfg.Batch <= 0 {
+		cfg.Batch = 16
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = time.Second
+	}
+	if cfg.ClaimIdle <= 0 {
+		cfg.ClaimIdle = 30 * time.Second
+	}
+	if cfg.ClaimInterval <= 0 {
+		cfg.ClaimInterval = cfg.ClaimIdle / 2
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 5
+	}
+	if cfg.DeadLetterPrefix == "" {
+		cfg.DeadLetterPrefix = "$dlq."
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Broker{client: client, cfg: cfg, ctx: ctx, cancel: cancel}
+}
+
+func (b *Broker) stream(topic string) string {
+	return b.cfg.Prefix + topic
+}
+
+// Publish appends the message to the topic's stream and returns it with
+// the entry ID filled in.
+func (b *Broker) Publish(msg pubsub.Message) (pubsub.Message, error) {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return msg, err
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, b.cfg.Timeout)
+	defer cancel()
+
+	args := &redis.XAddArgs{
+		Stream: b.stream(msg.Topic),
+		Values: map[string]interface{}{
+			"payload":   msg.Payload,
+			"key":       msg.Key,
+			"headers":   string(headers),
+			"timestamp": msg.Timestamp.UnixNano(),
+		},
+	}
+	if b.cfg.MaxLen > 0 {
+		args.MaxLen = b.cfg.MaxLen
+		args.Approx = true
+	}
+	id, err := b.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return msg, err
+	}
+	msg.ID = id
+	return msg, nil
+}
+
+// Consume reads the topic as consumer sub.GetId() of the group until ctx
+// is cancelled or the broker is closed, handing entries to sub one at a
+// time. It first redelivers entries this consumer still had pending from a
+// previous run.
+//
+// An empty group means a private group named after the subscriber, so it
+// sees every message published from now on. That group is destroyed when
+// Consume returns; one left behind by a crashed process is picked up again
+// by the next Consume with the same subscriber id.
+func (b *Broker) Consume(ctx context.Context, topic, group string, sub pubsub.Subscriber) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.wg.Add(1)
+	b.mu.Unlock()
+	defer b.wg.Done()
+
+	private := group == ""
+	if private {
+		group = sub.GetId()
+	}
+	stream := b.stream(topic)
+	err := b.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		err = nil
+	}
+	// A create cut short by ctx may still have reached Redis.
+	if private && (err == nil || ctx.Err() != nil) {
+		defer b.destroyGroup(stream, group)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := mergeContexts(ctx, b.ctx)
+	defer cancel()
+
+	c := &consumer{broker: b, topic: topic, stream: stream, group: group, sub: sub}
+	err = c.run(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// destroyGroup removes a private group once its only consumer is done. It
+// runs after ctx is cancelled, so it gets a context of its own.
+func (b *Broker) destroyGroup(stream, group string) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	defer cancel()
+	if err := b.client.XGroupDestroy(ctx, stream, group).Err(); err != nil {
+		fmt.Printf("Failed to remove group %s of %s: %v\n", group, stream, err)
+	}
+}
+
+// Close stops every Consume call and waits for them to return. The Redis
+// client is left open.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
+
+func mergeContexts(a, b context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(a)
+	go func() {
+		select {
+		case <-b.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (b *Broker) deadLetter(msg pubsub.Message, group string) error {
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["dead-letter-group"] = group
+	headers["dead-letter-id"] = msg.ID
+	_, err := b.Publish(pubsub.Message{
+		Topic:     b.cfg.DeadLetterPrefix + msg.Topic,
+		Key:       msg.Key,
+		Headers:   headers,
+		Payload:   msg.Payload,
+		Timestamp: msg.Timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("dead-letter %s: %w", msg.ID, err)
+	}
+	return nil
+}
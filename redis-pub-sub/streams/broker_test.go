@@ -0,0 +1,228 @@
+package streams
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+type recorder struct {
+	id       string
+	received chan pubsub.Message
+	ack      bool
+	poison   bool
+}
+
+func (r *recorder) Consume(msg pubsub.Message) {
+	r.received <- msg
+	if r.poison {
+		panic("cannot parse " + msg.Payload)
+	}
+	if r.ack {
+		msg.Ack()
+	}
+}
+
+func (r *recorder) GetId() string {
+	return r.id
+}
+
+func newTestBroker(t *testing.T) (*Broker, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	b := NewBroker(client, Config{
+		Block:         20 * time.Millisecond,
+		ClaimIdle:     100 * time.Millisecond,
+		ClaimInterval: 20 * time.Millisecond,
+		MaxDeliveries: 3,
+	})
+	t.Cleanup(func() { b.Close() })
+	return b, client
+}
+
+// consume runs Consume in the background until the returned stop is called,
+// and waits until the group exists so nothing published afterwards is
+// missed.
+func consume(t *testing.T, b *Broker, client *redis.Client, topic, group string, sub pubsub.Subscriber) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Consume(ctx, topic, group, sub) }()
+	name := group
+	if name == "" {
+		name = sub.GetId()
+	}
+	waitFor(t, func() bool { return hasGroup(client, b.stream(topic), name) })
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+// hasGroup reads XINFO GROUPS by hand: go-redis v8 cannot parse the longer
+// Redis 7 reply the stand-in sends.
+func hasGroup(client *redis.Client, stream, group string) bool {
+	groups, err := client.Do(context.Background(), "XINFO", "GROUPS", stream).Slice()
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		fields, _ := g.([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "name" && fields[i+1] == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func receive(t *testing.T, ch <-chan pubsub.Message) pubsub.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return pubsub.Message{}
+	}
+}
+
+func TestGroupsFanOut(t *testing.T) {
+	b, client := newTestBroker(t)
+	billing := make(chan pubsub.Message, 16)
+	shipping := make(chan pubsub.Message, 16)
+	consume(t, b, client, "orders", "billing", &recorder{id: "billing-1", received: billing, ack: true})
+	consume(t, b, client, "orders", "billing", &recorder{id: "billing-2", received: billing, ack: true})
+	consume(t, b, client, "orders", "shipping", &recorder{id: "shipping-1", received: shipping, ack: true})
+
+	for i := 0; i < 4; i++ {
+		if _, err := b.Publish(pubsub.Message{Topic: "orders", Payload: "order"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		seen[receive(t, billing).ID] = true
+		receive(t, shipping)
+	}
+	if len(seen) != 4 {
+		t.Fatalf("billing got %d distinct messages, want each of the 4 once", len(seen))
+	}
+	select {
+	case msg := <-billing:
+		t.Fatalf("billing got %s twice", msg.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAckRemovesPendingEntry(t *testing.T) {
+	b, client := newTestBroker(t)
+	pending := func(group string) int64 {
+		p, err := client.XPending(context.Background(), b.stream("jobs"), group).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Count
+	}
+
+	acked := make(chan pubsub.Message, 1)
+	unacked := make(chan pubsub.Message, 1)
+	consume(t, b, client, "jobs", "acks", &recorder{id: "a", received: acked, ack: true})
+	consume(t, b, client, "jobs", "forgets", &recorder{id: "f", received: unacked})
+	b.Publish(pubsub.Message{Topic: "jobs", Payload: "job"})
+	receive(t, acked)
+	receive(t, unacked)
+
+	waitFor(t, func() bool { return pending("acks") == 0 })
+	if n := pending("forgets"); n != 1 {
+		t.Fatalf("unacked group has %d pending entries, want 1", n)
+	}
+}
+
+func TestClaimsDeadConsumersEntries(t *testing.T) {
+	b, client := newTestBroker(t)
+	received := make(chan pubsub.Message, 4)
+	stopCrasher := consume(t, b, client, "payments", "ledger", &recorder{id: "crasher", received: received})
+	b.Publish(pubsub.Message{Topic: "payments", Payload: "payment"})
+	first := receive(t, received)
+	stopCrasher()
+
+	consume(t, b, client, "payments", "ledger", &recorder{id: "rescuer", received: received, ack: true})
+	claimed := receive(t, received)
+	if claimed.ID != first.ID {
+		t.Fatalf("rescuer got %s, want the crashed consumer's pending %s", claimed.ID, first.ID)
+	}
+}
+
+func TestDeadLettersAfterMaxDeliveries(t *testing.T) {
+	b, client := newTestBroker(t)
+	attempts := make(chan pubsub.Message, 16)
+	dead := make(chan pubsub.Message, 1)
+	consume(t, b, client, "$dlq.invoices", "ops", &recorder{id: "ops", received: dead, ack: true})
+	consume(t, b, client, "invoices", "mailer", &recorder{id: "mailer", received: attempts, poison: true})
+	b.Publish(pubsub.Message{Topic: "invoices", Payload: "garbled"})
+
+	msg := receive(t, dead)
+	if msg.Payload != "garbled" || msg.Headers["dead-letter-group"] != "mailer" {
+		t.Fatalf("dead letter = %+v", msg)
+	}
+	if n := len(attempts); n != 3 {
+		t.Fatalf("delivered %d times before dead-lettering, want 3", n)
+	}
+}
+
+func TestConsumeStopsOnCancelAndClose(t *testing.T) {
+	b, client := newTestBroker(t)
+	received := make(chan pubsub.Message, 1)
+	stop := consume(t, b, client, "events", "g", &recorder{id: "c1", received: received})
+	if err := stop(); err != nil {
+		t.Fatalf("Consume after cancel = %v, want nil", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.Consume(context.Background(), "events", "g", &recorder{id: "c2", received: received}) }()
+	time.Sleep(50 * time.Millisecond)
+	b.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Consume after Close = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop Consume")
+	}
+	if err := b.Consume(context.Background(), "events", "g", &recorder{id: "c3", received: received}); err != ErrClosed {
+		t.Fatalf("Consume on a closed broker = %v, want ErrClosed", err)
+	}
+}
+
+func TestPrivateGroupIsRemoved(t *testing.T) {
+	b, client := newTestBroker(t)
+	received := make(chan pubsub.Message, 1)
+	stop := consume(t, b, client, "news", "", &recorder{id: "reader", received: received, ack: true})
+	b.Publish(pubsub.Message{Topic: "news", Payload: "headline"})
+	receive(t, received)
+	stop()
+
+	if hasGroup(client, b.stream("news"), "reader") {
+		t.Fatal("private group still exists after Consume returned")
+	}
+}
@@ -0,0 +1,179 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rishu/design/pub-sub/pubsub"
+)
+
+type consumer struct {
+	broker *Broker
+	topic  string
+	stream string
+	group  string
+	sub    pubsub.Subscriber
+}
+
+func (c *consumer) run(ctx context.Context) error {
+	// "0" reads back this consumer's own pending entries, ">" new ones.
+	for from := "0"; from != ""; {
+		var err error
+		if from, err = c.read(ctx, from); err != nil {
+			return err
+		}
+	}
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.broker.cfg.ClaimInterval {
+			if err := c.claim(ctx); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+		if _, err := c.read(ctx, ">"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read delivers one batch starting after from and returns the ID of its
+// last entry, or "" if there was none.
+func (c *consumer) read(ctx context.Context, from string) (string, error) {
+	res, err := c.broker.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.sub.GetId(),
+		Streams:  []string{c.stream, from},
+		Count:    c.broker.cfg.Batch,
+		Block:    c.broker.cfg.Block,
+	}).Result()
+	if err == redis.Nil || ctx.Err() != nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	last := ""
+	for _, s := range res {
+		for _, entry := range s.Messages {
+			c.deliver(entry)
+			last = entry.ID
+		}
+	}
+	return last, nil
+}
+
+// claim takes over entries other consumers of the group left pending for
+// longer than ClaimIdle, dead-lettering those already delivered
+// MaxDeliveries times.
+func (c *consumer) claim(ctx context.Context) error {
+	cfg := c.broker.cfg
+	client := c.broker.client
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.stream,
+		Group:  c.group,
+		Start:  "-",
+		End:    "+",
+		Count:  cfg.Batch,
+	}).Result()
+	if err == redis.Nil || ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var ids, exhausted []string
+	for _, p := range pending {
+		switch {
+		case p.Idle < cfg.ClaimIdle:
+		case p.RetryCount >= cfg.MaxDeliveries:
+			exhausted = append(exhausted, p.ID)
+		default:
+			ids = append(ids, p.ID)
+		}
+	}
+
+	if len(exhausted) > 0 {
+		entries, err := client.XClaim(ctx, c.claimArgs(exhausted)).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := c.broker.deadLetter(c.message(entry), c.group); err != nil {
+				return err
+			}
+			c.ack(entry.ID)
+		}
+	}
+	if len(ids) > 0 {
+		entries, err := client.XClaim(ctx, c.claimArgs(ids)).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			c.deliver(entry)
+		}
+	}
+	return nil
+}
+
+// claimArgs only claims entries still idle, in case another consumer got
+// to them first.
+func (c *consumer) claimArgs(ids []string) *redis.XClaimArgs {
+	return &redis.XClaimArgs{
+		Stream:   c.stream,
+		Group:    c.group,
+		Consumer: c.sub.GetId(),
+		MinIdle:  c.broker.cfg.ClaimIdle,
+		Messages: ids,
+	}
+}
+
+// deliver hands the entry to the subscriber. A subscriber that panics
+// leaves the entry pending, to be claimed again after ClaimIdle.
+func (c *consumer) deliver(entry redis.XMessage) {
+	msg := c.message(entry)
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Subscriber %s panicked on %s %s: %v\n", c.sub.GetId(), c.topic, msg.ID, r)
+		}
+	}()
+	c.sub.Consume(msg)
+}
+
+func (c *consumer) message(entry redis.XMessage) pubsub.Message {
+	msg := pubsub.Message{Topic: c.topic, ID: entry.ID}
+	msg.Payload, _ = entry.Values["payload"].(string)
+	msg.Key, _ = entry.Values["key"].(string)
+	if headers, ok := entry.Values["headers"].(string); ok {
+		json.Unmarshal([]byte(headers), &msg.Headers)
+	}
+	if ts, ok := entry.Values["timestamp"].(string); ok {
+		if nanos, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			msg.Timestamp = time.Unix(0, nanos)
+		}
+	}
+	return msg.WithAcker(c)
+}
+
+func (c *consumer) ack(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.broker.cfg.Timeout)
+	defer cancel()
+	if err := c.broker.client.XAck(ctx, c.stream, c.group, id).Err(); err != nil {
+		fmt.Printf("Failed to ack %s %s: %v\n", c.stream, id, err)
+	}
+}
+
+func (c *consumer) Ack(msg pubsub.Message) {
+	c.ack(msg.ID)
+}
+
+// Nack leaves the entry pending; Redis has no negative acknowledgement, so
+// it is redelivered once it has been idle for ClaimIdle.
+func (c *consumer) Nack(msg pubsub.Message) {}
@@ -0,0 +1,162 @@
+// Package snapshot reads and writes the checksummed snapshot files the LRU
+// caches use for warm restarts. It owns the framing and the crash-safe
+// write; each cache encodes its own entries.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File layout:
+//
+//	magic (4 bytes) | version byte | payload |
+//	crc32 (IEEE, 4 bytes big endian) of everything before it
+
+var ErrCorrupt = errors.New("corrupt snapshot")
+
+// WriteFile writes payload to path under the given magic and version. The
+// data goes to a temporary file that is synced before being renamed over
+// path, and the directory is synced after the rename, so a crash leaves
+// either the old snapshot or the complete new one.
+func WriteFile(path, magic string, version byte, payload []byte) error {
+	buf := bytes.NewBufferString(magic)
+	buf.WriteByte(version)
+	buf.Write(payload)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(sum[:])
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// ReadFile checks the framing and checksum of a snapshot written by
+// WriteFile and returns a reader over its payload.
+func ReadFile(path, magic string, version byte) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(magic)+1+4 {
+		return nil, ErrCorrupt
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	if string(body[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	if v := body[len(magic)]; v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCorrupt, v)
+	}
+	return &Reader{r: bytes.NewReader(body[len(magic)+1:])}, nil
+}
+
+// Writer builds a payload out of varints and length-prefixed strings.
+type Writer struct {
+	buf bytes.Buffer
+}
+
+func (w *Writer) Varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+func (w *Writer) String(s string) {
+	w.Varint(int64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// Reader decodes a payload built with Writer. Its errors wrap ErrCorrupt.
+type Reader struct {
+	r *bytes.Reader
+}
+
+func (r *Reader) Varint() (int64, error) {
+	v, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return v, nil
+}
+
+func (r *Reader) String() (string, error) {
+	n, err := r.Varint()
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > int64(r.r.Len()) {
+		return "", fmt.Errorf("%w: %v", ErrCorrupt, io.ErrUnexpectedEOF)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return string(b), nil
+}
+
+// Every calls save every interval until the returned stop function is
+// called; stop then waits for the goroutine and saves once more. Errors
+// from the periodic saves go to onError, if it is set; the final save's is
+// returned by stop.
+func Every(interval time.Duration, save func() error, onError func(error)) (stop func() error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				if err := save(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return func() error {
+		close(quit)
+		<-done
+		return save()
+	}
+}
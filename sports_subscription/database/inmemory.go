@@ -1,9 +1,14 @@
 package database
 
-import "github.com/rishu/design/sports_subscription/game"
+import (
+	"sync"
+
+	"github.com/rishu/design/sports_subscription/game"
+)
 
 type InMemoryDB struct {
 	Games map[string]*game.SubjectGame
+	mu    sync.RWMutex
 }
 
 func NewInMemoryDB() *InMemoryDB {
@@ -14,9 +19,13 @@ func NewInMemoryDB() *InMemoryDB {
 
 func (db *InMemoryDB) AddGame(g game.Game) {
 	subject := game.NewSubjectGame(g)
+	db.mu.Lock()
+	defer db.mu.Unlock()
 	db.Games[g.ID] = subject
 }
 
 func (db *InMemoryDB) GetGame(id string) *game.SubjectGame {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 	return db.Games[id]
 }
@@ -1,8 +1,15 @@
 package game
 
 type Game struct {
-	ID      string
-	Score   string
-	Status  string
-	History []string
+	ID      string   `json:"id"`
+	Score   string   `json:"score"`
+	Status  string   `json:"status"`
+	History []string `json:"history"`
+}
+
+// Clone copies the game so the copy's History can be read while the
+// original keeps changing.
+func (g Game) Clone() Game {
+	g.History = append([]string(nil), g.History...)
+	return g
 }
@@ -1,33 +1,88 @@
 package game
 
+import (
+	"sync"
+
+	"github.com/rishu/design/eventbus"
+)
+
 type Subject interface {
 	RegisterObserver(o Observer)
 	RemoveObserver(o Observer)
 	NotifyObservers()
 }
 
+// SubjectGame notifies observers with a copy of the game. Changes made
+// through Update and notifications are serialised, so every observer sees
+// every state in order; observers must not block.
 type SubjectGame struct {
 	Game      Game
-	Observers map[Observer]struct{}
+	bus       *eventbus.EventBus[Game]
+	observers map[Observer]eventbus.Subscription
+	mu        sync.Mutex
 }
 
 func NewSubjectGame(game Game) *SubjectGame {
 	return &SubjectGame{
 		Game:      game,
-		Observers: make(map[Observer]struct{}),
+		bus:       eventbus.NewEventBus[Game](eventbus.Options{}),
+		observers: make(map[Observer]eventbus.Subscription),
 	}
 }
 
 func (gs *SubjectGame) RegisterObserver(o Observer) {
-	gs.Observers[o] = struct{}{}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.registerLocked(o)
+}
+
+// Subscribe registers the observer and returns the game as it is at that
+// moment; the observer is notified of every change after it.
+func (gs *SubjectGame) Subscribe(o Observer) Game {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.registerLocked(o)
+	return gs.Game.Clone()
+}
+
+func (gs *SubjectGame) registerLocked(o Observer) {
+	if _, exists := gs.observers[o]; exists {
+		return
+	}
+	gs.observers[o] = gs.bus.Subscribe(o.Update)
 }
 
 func (gs *SubjectGame) RemoveObserver(o Observer) {
-	delete(gs.Observers, o)
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if sub, exists := gs.observers[o]; exists {
+		sub.Unsubscribe()
+		delete(gs.observers, o)
+	}
 }
 
 func (gs *SubjectGame) NotifyObservers() {
-	for o := range gs.Observers {
-		o.Update(gs.Game)
-	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.bus.Publish(gs.Game.Clone())
+}
+
+// Update applies change to the game and notifies observers.
+func (gs *SubjectGame) Update(change func(g *Game)) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	change(&gs.Game)
+	gs.bus.Publish(gs.Game.Clone())
+}
+
+func (gs *SubjectGame) Snapshot() Game {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.Game.Clone()
+}
+
+func (gs *SubjectGame) ObserverCount() int {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return len(gs.observers)
 }
@@ -0,0 +1,318 @@
+// Package live streams score updates of sports_subscription games to fans
+// over WebSocket (/games/{id}/ws) and Server-Sent Events
+// (/games/{id}/events).
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/sports_subscription/database"
+	"github.com/rishu/design/sports_subscription/game"
+)
+
+type Config struct {
+	// SendBuffer is how many updates may queue for one connection. A fan
+	// that falls further behind is disconnected rather than slowing the
+	// game down for everyone.
+	SendBuffer int
+	// WriteTimeout bounds one WebSocket write.
+	WriteTimeout time.Duration
+	// PingInterval is how often idle connections get a keep-alive.
+	PingInterval time.Duration
+	// AllowedOrigins lists the Origin headers WebSocket upgrades are
+	// accepted from; "*" accepts any. When empty only pages served from the
+	// same host may connect. Requests without an Origin, which browsers
+	// always send, are accepted.
+	AllowedOrigins []string
+}
+
+type Stats struct {
+	Connected int64
+	Dropped   int64
+}
+
+type Server struct {
+	db        *database.InMemoryDB
+	cfg       Config
+	upgrader  websocket.Upgrader
+	feeds     map[*game.SubjectGame]*feed
+	connected int64
+	dropped   int64
+	mu        sync.Mutex
+}
+
+func NewServer(db *database.InMemoryDB, cfg Config) *Server {
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = 64
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 5 * time.Second
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = 15 * time.Second
+	}
+	return &Server{
+		db:       db,
+		cfg:      cfg,
+		upgrader: websocket.Upgrader{CheckOrigin: checkOrigin(cfg.AllowedOrigins)},
+		feeds:    make(map[*game.SubjectGame]*feed),
+	}
+}
+
+// checkOrigin returns nil, gorilla's same-host check, when no origins are
+// configured.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(a, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (s *Server) Stats() Stats {
+	return Stats{
+		Connected: atomic.LoadInt64(&s.connected),
+		Dropped:   atomic.LoadInt64(&s.dropped),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "games" || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	g := s.db.GetGame(parts[1])
+	if g == nil {
+		http.Error(w, "game not found", http.StatusNotFound)
+		return
+	}
+	switch parts[2] {
+	case "ws":
+		s.serveWebSocket(w, r, g)
+	case "events":
+		s.serveEvents(w, r, g)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// feed is the one observer a game has however many fans watch it. Update
+// runs on the goroutine changing the game, so it builds and encodes each
+// delta once and hands the same bytes to every fan; each connection's own
+// goroutine does the writing.
+type feed struct {
+	fans map[*fan]struct{}
+	last game.Game
+	seq  uint64
+	mu   sync.Mutex
+}
+
+// fan is one connection's send queue.
+type fan struct {
+	out     chan message
+	slow    chan struct{}
+	dropped int32
+}
+
+type message struct {
+	event string
+	data  []byte
+}
+
+func newFan(size int) *fan {
+	return &fan{
+		out:  make(chan message, size),
+		slow: make(chan struct{}),
+	}
+}
+
+func (fd *feed) Update(g game.Game) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	u, changed := delta(fd.last, g, fd.seq+1)
+	fd.last = g
+	if !changed {
+		return
+	}
+	fd.seq++
+	msg, ok := encode(u)
+	if !ok {
+		return
+	}
+	for f := range fd.fans {
+		f.queue(msg)
+	}
+}
+
+// add queues the catch-up snapshot for the fan before any delta can reach
+// it.
+func (fd *feed) add(f *fan) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if msg, ok := encode(snapshot(fd.last, fd.seq)); ok {
+		f.queue(msg)
+	}
+	fd.fans[f] = struct{}{}
+}
+
+func encode(u Update) (message, bool) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return message{}, false
+	}
+	return message{event: u.Type, data: data}, true
+}
+
+func (f *fan) queue(msg message) {
+	if atomic.LoadInt32(&f.dropped) == 1 {
+		return
+	}
+	select {
+	case f.out <- msg:
+	default:
+		if atomic.CompareAndSwapInt32(&f.dropped, 0, 1) {
+			close(f.slow)
+		}
+	}
+}
+
+// attach joins the game's feed, starting one if this is its first fan.
+func (s *Server) attach(g *game.SubjectGame) *fan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fd, ok := s.feeds[g]
+	if !ok {
+		fd = &feed{fans: make(map[*fan]struct{})}
+		// Holding fd.mu keeps a change that lands right after Subscribe
+		// from diffing against an empty game.
+		fd.mu.Lock()
+		fd.last = g.Subscribe(fd)
+		fd.mu.Unlock()
+		s.feeds[g] = fd
+	}
+	f := newFan(s.cfg.SendBuffer)
+	fd.add(f)
+	atomic.AddInt64(&s.connected, 1)
+	return f
+}
+
+// detach leaves the feed and stops it once no fan is left.
+func (s *Server) detach(g *game.SubjectGame, f *fan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fd, ok := s.feeds[g]; ok {
+		fd.mu.Lock()
+		delete(fd.fans, f)
+		empty := len(fd.fans) == 0
+		fd.mu.Unlock()
+		if empty {
+			g.RemoveObserver(fd)
+			delete(s.feeds, g)
+		}
+	}
+	atomic.AddInt64(&s.connected, -1)
+	if atomic.LoadInt32(&f.dropped) == 1 {
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, g *game.SubjectGame) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	f := s.attach(g)
+	defer s.detach(g, f)
+
+	// Fans only listen; reading is how a close from their side is noticed.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(s.cfg.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-f.out:
+			conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg.data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.cfg.WriteTimeout)); err != nil {
+				return
+			}
+		case <-f.slow:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"),
+				time.Now().Add(s.cfg.WriteTimeout))
+			return
+		case <-gone:
+			return
+		}
+	}
+}
+
+// serveEvents streams Server-Sent Events. A fan that stops reading is
+// noticed through its send buffer filling up; a write already stuck on it is
+// only bounded by the http.Server's WriteTimeout.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, g *game.SubjectGame) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	f := s.attach(g)
+	defer s.detach(g, f)
+
+	ping := time.NewTicker(s.cfg.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-f.out:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.event, msg.data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-f.slow:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,175 @@
+package live
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/sports_subscription/database"
+	"github.com/rishu/design/sports_subscription/game"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*Server, *database.InMemoryDB, string) {
+	t.Helper()
+	db := database.NewInMemoryDB()
+	db.AddGame(game.Game{ID: "final", Score: "0-0", Status: "In Progress", History: []string{"Kick-off"}})
+	s := NewServer(db, cfg)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, db, strings.TrimPrefix(ts.URL, "http://")
+}
+
+func dial(t *testing.T, addr, id string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/games/"+id+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) Update {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var u Update
+	if err := conn.ReadJSON(&u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (s *Server) feedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.feeds)
+}
+
+// slowFans counts the fans marked to be dropped but not yet gone.
+func (s *Server) slowFans() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, fd := range s.feeds {
+		fd.mu.Lock()
+		for f := range fd.fans {
+			if atomic.LoadInt32(&f.dropped) == 1 {
+				n++
+			}
+		}
+		fd.mu.Unlock()
+	}
+	return n
+}
+
+func TestSnapshotCatchesUpOnConnect(t *testing.T) {
+	_, db, addr := newTestServer(t, Config{})
+	final := db.GetGame("final")
+	final.Update(func(g *game.Game) {
+		g.Score = "1-0"
+		g.History = append(g.History, "Goal")
+	})
+
+	u := read(t, dial(t, addr, "final"))
+	if u.Type != SnapshotUpdate || u.GameID != "final" {
+		t.Fatalf("first update is %s for %q, want a snapshot of final", u.Type, u.GameID)
+	}
+	if *u.Score != "1-0" || *u.Status != "In Progress" || !reflect.DeepEqual(u.History, []string{"Kick-off", "Goal"}) {
+		t.Fatalf("snapshot = %s %s %v, want the whole game", *u.Score, *u.Status, u.History)
+	}
+}
+
+func TestDeltaCarriesOnlyChanges(t *testing.T) {
+	_, db, addr := newTestServer(t, Config{})
+	conn := dial(t, addr, "final")
+	snap := read(t, conn)
+	final := db.GetGame("final")
+
+	final.Update(func(g *game.Game) { g.Score = "1-0" })
+	u := read(t, conn)
+	if u.Type != DeltaUpdate || u.Seq != snap.Seq+1 {
+		t.Fatalf("got %s #%d, want delta #%d", u.Type, u.Seq, snap.Seq+1)
+	}
+	if u.Score == nil || *u.Score != "1-0" || u.Status != nil || u.History != nil {
+		t.Fatalf("delta = %+v, want only the score", u)
+	}
+
+	// A change nobody can see sends nothing, so the next delta follows on.
+	final.Update(func(g *game.Game) {})
+	final.Update(func(g *game.Game) { g.History = append(g.History, "Goal") })
+	u = read(t, conn)
+	if u.Seq != snap.Seq+2 || u.Score != nil || !reflect.DeepEqual(u.History, []string{"Goal"}) {
+		t.Fatalf("delta = %+v, want #%d with only the new history entry", u, snap.Seq+2)
+	}
+}
+
+func TestSlowFanIsDropped(t *testing.T) {
+	s, db, addr := newTestServer(t, Config{SendBuffer: 4})
+	fast := dial(t, addr, "final")
+	read(t, fast)
+	// An SSE fan that never reads its response.
+	slow, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	fmt.Fprintf(slow, "GET /games/final/events HTTP/1.1\r\nHost: %s\r\n\r\n", addr)
+	waitFor(t, "both fans", func() bool { return s.Stats().Connected == 2 })
+
+	final := db.GetGame("final")
+	padding := strings.Repeat("x", 64<<10)
+	for i := 0; s.slowFans() == 0; i++ {
+		if i == 1000 {
+			t.Fatal("slow fan never fell behind")
+		}
+		final.Update(func(g *game.Game) { g.Score = fmt.Sprint(padding, i) })
+		read(t, fast)
+	}
+	// The write the slow fan is stuck in only ends with its connection or
+	// at the http.Server's WriteTimeout.
+	slow.Close()
+	waitFor(t, "the slow fan to go", func() bool { return s.Stats().Connected == 1 })
+	if got := s.Stats().Dropped; got != 1 {
+		t.Fatalf("Dropped = %d, want 1", got)
+	}
+
+	final.Update(func(g *game.Game) { g.Status = "Finished" })
+	if u := read(t, fast); u.Status == nil || *u.Status != "Finished" {
+		t.Fatalf("fast fan got %+v after the drop, want the final status", u)
+	}
+}
+
+func TestFeedStopsWithLastFan(t *testing.T) {
+	s, _, addr := newTestServer(t, Config{})
+	first := dial(t, addr, "final")
+	second := dial(t, addr, "final")
+	read(t, first)
+	read(t, second)
+	if n := s.feedCount(); n != 1 {
+		t.Fatalf("%d feeds for one game, want 1", n)
+	}
+
+	first.Close()
+	waitFor(t, "the first fan to leave", func() bool { return s.Stats().Connected == 1 })
+	if n := s.feedCount(); n != 1 {
+		t.Fatalf("feed stopped while a fan is still watching")
+	}
+	second.Close()
+	waitFor(t, "the feed to stop", func() bool { return s.feedCount() == 0 })
+}
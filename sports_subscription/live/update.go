@@ -0,0 +1,62 @@
+package live
+
+import "github.com/rishu/design/sports_subscription/game"
+
+// Update is one JSON message to a fan. The first one on a connection is a
+// snapshot carrying the whole game, History included, so the fan can catch
+// up; after that each is a delta with only the fields that changed and the
+// History entries added since the previous message.
+type Update struct {
+	Type    string   `json:"type"`
+	GameID  string   `json:"gameId"`
+	Seq     uint64   `json:"seq"`
+	Score   *string  `json:"score,omitempty"`
+	Status  *string  `json:"status,omitempty"`
+	History []string `json:"history,omitempty"`
+}
+
+const (
+	SnapshotUpdate = "snapshot"
+	DeltaUpdate    = "delta"
+)
+
+func snapshot(g game.Game, seq uint64) Update {
+	return Update{
+		Type:    SnapshotUpdate,
+		GameID:  g.ID,
+		Seq:     seq,
+		Score:   &g.Score,
+		Status:  &g.Status,
+		History: g.History,
+	}
+}
+
+// delta describes how next differs from prev, or returns false if nothing
+// a fan sees changed. History is append-only; if it was rewritten a fresh
+// snapshot is sent instead.
+func delta(prev, next game.Game, seq uint64) (Update, bool) {
+	if len(next.History) < len(prev.History) {
+		return snapshot(next, seq), true
+	}
+	for i := range prev.History {
+		if prev.History[i] != next.History[i] {
+			return snapshot(next, seq), true
+		}
+	}
+
+	u := Update{Type: DeltaUpdate, GameID: next.ID, Seq: seq}
+	changed := false
+	if next.Score != prev.Score {
+		u.Score = &next.Score
+		changed = true
+	}
+	if next.Status != prev.Status {
+		u.Status = &next.Status
+		changed = true
+	}
+	if len(next.History) > len(prev.History) {
+		u.History = next.History[len(prev.History):]
+		changed = true
+	}
+	return u, changed
+}
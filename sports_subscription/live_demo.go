@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rishu/design/sports_subscription/database"
+	"github.com/rishu/design/sports_subscription/game"
+	"github.com/rishu/design/sports_subscription/live"
+)
+
+// followWebSocket reads updates until the game is finished and returns how
+// many it got.
+func followWebSocket(base, id string) (int, error) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+base+"/games/"+id+"/ws", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	for n := 1; ; n++ {
+		var u live.Update
+		if err := conn.ReadJSON(&u); err != nil {
+			return n, err
+		}
+		if u.Status != nil && *u.Status == "Finished" {
+			return n, nil
+		}
+	}
+}
+
+func followEvents(base, id string) (int, error) {
+	resp, err := http.Get("http://" + base + "/games/" + id + "/events")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	n := 0
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == scanner.Text() {
+			continue
+		}
+		n++
+		var u live.Update
+		if err := json.Unmarshal([]byte(data), &u); err != nil {
+			return n, err
+		}
+		if u.Status != nil && *u.Status == "Finished" {
+			return n, nil
+		}
+	}
+	return n, scanner.Err()
+}
+
+func waitFor(cond func() bool) {
+	for deadline := time.Now().Add(10 * time.Second); !cond() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func liveDemo() {
+	db := database.NewInMemoryDB()
+	db.AddGame(game.Game{ID: "final", Score: "0-0", Status: "In Progress", History: []string{"Kick-off"}})
+	db.AddGame(game.Game{ID: "friendly", Score: "0-0", Status: "In Progress"})
+
+	server := live.NewServer(db, live.Config{SendBuffer: 16})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("listen:", err)
+		return
+	}
+	defer l.Close()
+	go http.Serve(l, server)
+	base := l.Addr().String()
+
+	// A thousand fans on each transport.
+	const fans = 1000
+	var wg sync.WaitGroup
+	counts := make(chan int, 2*fans)
+	for i := 0; i < fans; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			n, _ := followWebSocket(base, "final")
+			counts <- n
+		}()
+		go func() {
+			defer wg.Done()
+			n, _ := followEvents(base, "final")
+			counts <- n
+		}()
+	}
+	waitFor(func() bool { return server.Stats().Connected == 2*fans })
+	fmt.Println("connected fans:", server.Stats().Connected) // 2000
+
+	final := db.GetGame("final")
+	for i := 1; i <= 3; i++ {
+		final.Update(func(g *game.Game) {
+			g.Score = fmt.Sprintf("%d-0", i)
+			g.History = append(g.History, fmt.Sprintf("Team A scored goal %d", i))
+		})
+	}
+
+	// A fan joining late catches up from the snapshot.
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+base+"/games/final/ws", nil)
+	if err == nil {
+		var u live.Update
+		conn.ReadJSON(&u)
+		fmt.Println(u.Type, *u.Score, u.History) // snapshot 3-0 [Kick-off Team A scored goal 1 Team A scored goal 2 Team A scored goal 3]
+		conn.Close()
+	}
+
+	final.Update(func(g *game.Game) { g.Status = "Finished" })
+	wg.Wait()
+	close(counts)
+	complete := 0
+	for n := range counts {
+		if n == 5 {
+			complete++
+		}
+	}
+	fmt.Println("fans who saw all 5 updates:", complete) // 2000
+
+	// A fan that never reads is disconnected once its buffer is full,
+	// without holding up the game.
+	slow, err := net.Dial("tcp", base)
+	if err != nil {
+		return
+	}
+	defer slow.Close()
+	fmt.Fprintf(slow, "GET /games/friendly/events HTTP/1.1\r\nHost: %s\r\n\r\n", base)
+	waitFor(func() bool { return server.Stats().Connected == 1 })
+	friendly := db.GetGame("friendly")
+	padding := strings.Repeat("x", 1024)
+	for i := 0; i < 100000 && server.Stats().Dropped == 0; i++ {
+		friendly.Update(func(g *game.Game) { g.History = append(g.History, padding) })
+	}
+	waitFor(func() bool { return server.Stats().Connected == 0 })
+	fmt.Println("slow fans dropped:", server.Stats().Dropped) // 1
+}
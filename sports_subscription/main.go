@@ -22,9 +22,11 @@ func main() {
 	}
 
 	db.GetGame("1").RegisterObserver(subscriber1)
-	g.Score = "1-0"
-	g.Status = "In Progress"
-	g.History = append(g.History, "Team A Scored")
-	db.GetGame("1").Game = g
-	db.GetGame("1").NotifyObservers()
+	db.GetGame("1").Update(func(g *game.Game) {
+		g.Score = "1-0"
+		g.Status = "In Progress"
+		g.History = append(g.History, "Team A Scored")
+	})
+
+	liveDemo()
 }
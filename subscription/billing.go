@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SubscriptionStatus int32
+
+const (
+	TRIAL SubscriptionStatus = iota
+	ACTIVE
+	PAST_DUE
+	CANCELLED
+	EXPIRED
+)
+
+func (s SubscriptionStatus) String() string {
+	switch s {
+	case TRIAL:
+		return "trial"
+	case ACTIVE:
+		return "active"
+	case PAST_DUE:
+		return "past-due"
+	case CANCELLED:
+		return "cancelled"
+	case EXPIRED:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// A past-due subscription keeps its access for this long while the renewal
+// charge is retried, then expires.
+const pastDueGrace = 7 * 24 * time.Hour
+
+var (
+	ErrAlreadySubscribed = errors.New("user already has a subscription")
+	ErrNoSubscription    = errors.New("user has no subscription")
+	ErrNotEntitled       = errors.New("plan does not include this game type")
+	ErrInactive          = errors.New("subscription is not active")
+)
+
+type Invoice struct {
+	at          time.Time
+	description string
+	amountCents int64
+}
+
+func (i Invoice) String() string {
+	return fmt.Sprintf("%s %s $%d.%02d", i.at.Format("2006-01-02"), i.description, i.amountCents/100, i.amountCents%100)
+}
+
+type IPayment interface {
+	Charge(userId string, amountCents int64) error
+}
+
+// FakePayment accepts every charge except for users marked as declined.
+type FakePayment struct {
+	declined map[string]bool
+	charged  map[string]int64
+}
+
+func NewFakePayment() *FakePayment {
+	return &FakePayment{
+		declined: make(map[string]bool),
+		charged:  make(map[string]int64),
+	}
+}
+
+func (p *FakePayment) Decline(userId string, declined bool) {
+	p.declined[userId] = declined
+}
+
+func (p *FakePayment) Charge(userId string, amountCents int64) error {
+	if p.declined[userId] {
+		return errors.New("card declined")
+	}
+	p.charged[userId] += amountCents
+	return nil
+}
+
+// Subscribe starts the user on the plan: with a free trial if the plan has
+// one and the user has not had a trial before, otherwise by charging the
+// first period. Coming back after a subscription expired keeps its unused
+// credit and invoice history.
+func (s *Service) Subscribe(userID, planID string) (*Subscription, error) {
+	if _, err := s.userSvc.GetById(userID); err != nil {
+		return nil, err
+	}
+	plan, err := s.planSvc.GetById(planID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	sub := &Subscription{id: userID, userId: userID, planId: plan.id, plan: plan.term, periodStart: now}
+	if prev, err := s.subSvc.GetById(userID); err == nil {
+		if s.statusOf(prev) != EXPIRED {
+			return nil, ErrAlreadySubscribed
+		}
+		sub.creditCents = prev.creditCents
+		sub.invoices = prev.invoices
+	}
+	if plan.trialDays > 0 && !s.trialsUsed[userID] {
+		s.trialsUsed[userID] = true
+		sub.status = TRIAL
+		sub.expiresAt = now.AddDate(0, 0, plan.trialDays)
+	} else {
+		if err := s.charge(sub, plan.priceCents, "first period of "+plan.name); err != nil {
+			return nil, err
+		}
+		sub.status = ACTIVE
+		sub.expiresAt = plan.term.periodEnd(now)
+	}
+	return sub, s.subSvc.Add(sub)
+}
+
+// Cancel stops renewal. Access continues until the end of the trial or paid
+// period; an unpaid past-due subscription ends straight away.
+func (s *Service) Cancel(userID string) error {
+	sub, err := s.subscription(userID)
+	if err != nil {
+		return err
+	}
+	switch s.statusOf(sub) {
+	case TRIAL, ACTIVE:
+		sub.status = CANCELLED
+	case PAST_DUE:
+		sub.status = EXPIRED
+	default:
+		return ErrInactive
+	}
+	return nil
+}
+
+// ChangePlan moves the user to another plan straight away. During a trial
+// only the plan changes. Otherwise the unused part of the current period is
+// credited pro rata, a new period on the new plan starts now and its price
+// is charged less that credit; credit left over is kept for later invoices.
+func (s *Service) ChangePlan(userID, planID string) (Invoice, error) {
+	sub, err := s.subscription(userID)
+	if err != nil {
+		return Invoice{}, err
+	}
+	plan, err := s.planSvc.GetById(planID)
+	if err != nil {
+		return Invoice{}, err
+	}
+	old, err := s.planSvc.GetById(sub.planId)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	switch s.statusOf(sub) {
+	case TRIAL:
+		sub.planId, sub.plan = plan.id, plan.term
+		return Invoice{at: s.now(), description: "switch trial to " + plan.name}, nil
+	case ACTIVE:
+	default:
+		return Invoice{}, ErrInactive
+	}
+
+	now := s.now()
+	credit := prorate(old.priceCents, sub.periodStart, sub.expiresAt, now)
+	sub.creditCents += credit
+	if err := s.charge(sub, plan.priceCents, "change to "+plan.name); err != nil {
+		sub.creditCents -= credit
+		return Invoice{}, err
+	}
+	sub.planId, sub.plan = plan.id, plan.term
+	sub.periodStart = now
+	sub.expiresAt = plan.term.periodEnd(now)
+	return sub.invoices[len(sub.invoices)-1], nil
+}
+
+// prorate is the share of price for the part of [start, end) after now.
+func prorate(price int64, start, end, now time.Time) int64 {
+	total := int64(end.Sub(start) / time.Second)
+	remaining := int64(end.Sub(now) / time.Second)
+	if total <= 0 || remaining <= 0 {
+		return 0
+	}
+	return price * remaining / total
+}
+
+// RunBilling brings every subscription up to date: ends trials and periods,
+// charges renewals, retries past-due payments and expires what is over. It
+// is the only place that charges for a renewal; reads in between work out
+// the status from the clock instead.
+func (s *Service) RunBilling() {
+	for _, sub := range s.subSvc.All() {
+		s.settle(sub)
+	}
+}
+
+func (s *Service) settle(sub *Subscription) {
+	now := s.now()
+	for {
+		switch sub.status {
+		case TRIAL, ACTIVE:
+			if now.Before(sub.expiresAt) {
+				return
+			}
+			if !s.renew(sub, sub.expiresAt) {
+				return
+			}
+		case PAST_DUE:
+			if s.renew(sub, now) {
+				return
+			}
+			if !now.Before(sub.pastDueSince.Add(pastDueGrace)) {
+				sub.status = EXPIRED
+			}
+			return
+		case CANCELLED:
+			if !now.Before(sub.expiresAt) {
+				sub.status = EXPIRED
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+// renew charges a new period starting at start, marking the subscription
+// past due if the charge fails.
+func (s *Service) renew(sub *Subscription, start time.Time) bool {
+	plan, err := s.planSvc.GetById(sub.planId)
+	if err == nil {
+		err = s.charge(sub, plan.priceCents, "renewal of "+plan.name)
+	}
+	if err != nil {
+		if sub.status != PAST_DUE {
+			sub.status = PAST_DUE
+			sub.pastDueSince = start
+		}
+		return false
+	}
+	sub.status = ACTIVE
+	sub.periodStart = start
+	sub.expiresAt = sub.plan.periodEnd(start)
+	return true
+}
+
+// charge bills amount less any credit and records the invoice.
+func (s *Service) charge(sub *Subscription, amountCents int64, description string) error {
+	due := amountCents - sub.creditCents
+	credit := int64(0)
+	if due < 0 {
+		credit, due = -due, 0
+	}
+	if due > 0 {
+		if err := s.payment.Charge(sub.userId, due); err != nil {
+			return err
+		}
+	}
+	sub.creditCents = credit
+	sub.invoices = append(sub.invoices, Invoice{at: s.now(), description: description, amountCents: due})
+	return nil
+}
+
+func (s *Service) subscription(userID string) (*Subscription, error) {
+	sub, err := s.subSvc.GetById(userID)
+	if err != nil {
+		return nil, ErrNoSubscription
+	}
+	return sub, nil
+}
+
+// statusOf is the status the subscription has now, without charging or
+// changing it. A trial or period that ended before RunBilling got to it
+// counts as past due from its end, so access is the same as if the renewal
+// had been tried and declined.
+func (s *Service) statusOf(sub *Subscription) SubscriptionStatus {
+	now := s.now()
+	switch sub.status {
+	case TRIAL, ACTIVE:
+		if now.Before(sub.expiresAt) {
+			return sub.status
+		}
+		if now.Before(sub.expiresAt.Add(pastDueGrace)) {
+			return PAST_DUE
+		}
+		return EXPIRED
+	case PAST_DUE:
+		if now.Before(sub.pastDueSince.Add(pastDueGrace)) {
+			return PAST_DUE
+		}
+		return EXPIRED
+	case CANCELLED:
+		if now.Before(sub.expiresAt) {
+			return CANCELLED
+		}
+		return EXPIRED
+	}
+	return sub.status
+}
+
+// CanAccess reports whether the user's subscription currently covers games
+// of the given type. Trials, paid periods, cancelled subscriptions until
+// their period ends and past-due ones within the grace period all count.
+// It never charges; renewals are left to RunBilling.
+func (s *Service) CanAccess(userID string, gameType GameType) error {
+	sub, err := s.subscription(userID)
+	if err != nil {
+		return err
+	}
+	if s.statusOf(sub) == EXPIRED {
+		return ErrInactive
+	}
+	plan, err := s.planSvc.GetById(sub.planId)
+	if err != nil {
+		return err
+	}
+	if !plan.Entitles(gameType) {
+		return ErrNotEntitled
+	}
+	return nil
+}
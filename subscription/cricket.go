@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type batter struct {
+	name  string
+	runs  int
+	balls int
+	fours int
+	sixes int
+	out   bool
+}
+
+type bowler struct {
+	name     string
+	balls    int
+	conceded int
+	wickets  int
+}
+
+type innings struct {
+	team    string
+	runs    int
+	wickets int
+	balls   int
+	extras  int
+	batters []*batter
+	bowlers []*bowler
+	// crease holds the batters in, at most two. A new batter only comes in
+	// once a wicket has made room.
+	crease []*batter
+}
+
+func (in *innings) batter(name string) *batter {
+	for _, b := range in.batters {
+		if b.name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func (in *innings) atCrease(b *batter) bool {
+	for _, c := range in.crease {
+		if c == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (in *innings) dismiss(b *batter) {
+	for i, c := range in.crease {
+		if c == b {
+			in.crease = append(in.crease[:i], in.crease[i+1:]...)
+			return
+		}
+	}
+}
+
+func (in *innings) bowler(name string) *bowler {
+	for _, b := range in.bowlers {
+		if b.name == name {
+			return b
+		}
+	}
+	b := &bowler{name: name}
+	in.bowlers = append(in.bowlers, b)
+	return b
+}
+
+// CricketScore folds ball-by-ball events into a scorecard per innings.
+// Teams bat in the order they were given; the first ball of an innings
+// closes the one before it.
+type CricketScore struct {
+	innings []*innings
+	// batting is the index of the innings in progress.
+	batting int
+}
+
+func NewCricketScore(teams ...string) *CricketScore {
+	c := &CricketScore{}
+	for _, t := range teams {
+		c.innings = append(c.innings, &innings{team: t})
+	}
+	return c
+}
+
+// inningsOf finds the team's innings and checks it is the one in progress
+// or the next to start.
+func (c *CricketScore) inningsOf(team string) (int, error) {
+	for i, in := range c.innings {
+		if in.team != team {
+			continue
+		}
+		switch {
+		case i < c.batting:
+			return 0, fmt.Errorf("%s innings is over", team)
+		case i > c.batting+1:
+			return 0, fmt.Errorf("%s cannot bat before %s", team, c.innings[c.batting+1].team)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("%s is not playing", team)
+}
+
+func (c *CricketScore) Apply(e MatchEvent) error {
+	if e.kind != BALL && e.kind != WICKET {
+		return errors.New("not a cricket event")
+	}
+	i, err := c.inningsOf(e.team)
+	if err != nil {
+		return err
+	}
+	in := c.innings[i]
+	if in.wickets == 10 {
+		return fmt.Errorf("%s are all out", in.team)
+	}
+	if e.runs < 0 || e.extras < 0 {
+		return errors.New("runs cannot be negative")
+	}
+	if e.runs > 0 && e.extra != NO_EXTRA && e.extra != NO_BALL {
+		return errors.New("only a no ball can have runs off the bat as well as extras")
+	}
+	bat := in.batter(e.player)
+	if bat != nil && bat.out {
+		return fmt.Errorf("%s is already out", bat.name)
+	}
+	if bat == nil || !in.atCrease(bat) {
+		if len(in.crease) == 2 {
+			return fmt.Errorf("%s cannot bat while %s and %s are in", e.player, in.crease[0].name, in.crease[1].name)
+		}
+		if bat == nil {
+			bat = &batter{name: e.player}
+			in.batters = append(in.batters, bat)
+		}
+		in.crease = append(in.crease, bat)
+	}
+	bowl := in.bowler(e.other)
+	c.batting = i
+
+	legal := e.extra != WIDE && e.extra != NO_BALL
+	if legal {
+		in.balls++
+		bowl.balls++
+	}
+	if e.extra != WIDE {
+		bat.balls++
+	}
+	in.runs += e.runs + e.extras
+	in.extras += e.extras
+	bat.runs += e.runs
+	switch e.runs {
+	case 4:
+		bat.fours++
+	case 6:
+		bat.sixes++
+	}
+	// Byes and leg byes are not charged to the bowler.
+	bowl.conceded += e.runs
+	if e.extra == WIDE || e.extra == NO_BALL {
+		bowl.conceded += e.extras
+	}
+	if e.kind == WICKET {
+		bat.out = true
+		in.dismiss(bat)
+		in.wickets++
+		bowl.wickets++
+	}
+	return nil
+}
+
+func overs(balls int) string {
+	return fmt.Sprintf("%d.%d", balls/6, balls%6)
+}
+
+func (c *CricketScore) GetScore() string {
+	parts := make([]string, 0, len(c.innings))
+	for _, in := range c.innings {
+		parts = append(parts, fmt.Sprintf("%s %d/%d (%s)", in.team, in.runs, in.wickets, overs(in.balls)))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (c *CricketScore) GetStats() string {
+	var sb strings.Builder
+	for _, in := range c.innings {
+		if in.balls == 0 && in.runs == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s batting, extras %d\n", in.team, in.extras)
+		for _, b := range in.batters {
+			status := "not out"
+			if b.out {
+				status = "out"
+			}
+			fmt.Fprintf(&sb, "  %-10s %3d (%d) 4s:%d 6s:%d %s\n", b.name, b.runs, b.balls, b.fours, b.sixes, status)
+		}
+		for _, b := range in.bowlers {
+			fmt.Fprintf(&sb, "  %-10s %s-%d-%d\n", b.name, overs(b.balls), b.conceded, b.wickets)
+		}
+	}
+	return sb.String()
+}
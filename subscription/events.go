@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type EventKind int32
+
+const (
+	BALL EventKind = iota
+	WICKET
+	GOAL
+	CARD
+	SUBSTITUTION
+	CORRECTION
+)
+
+type ExtraType int32
+
+const (
+	NO_EXTRA ExtraType = iota
+	WIDE
+	NO_BALL
+	BYE
+	LEG_BYE
+)
+
+type Card int32
+
+const (
+	YELLOW Card = iota
+	RED
+)
+
+// MatchEvent is one entry in a game's event log. Which fields are used
+// depends on the kind; the constructors below set the right ones.
+type MatchEvent struct {
+	seq    int
+	kind   EventKind
+	team   string
+	player string // batter, scorer, booked player or player substituted off
+	other  string // bowler, or the player coming on
+	runs   int    // runs off the bat
+	extra  ExtraType
+	extras int
+	card   Card
+	minute int
+	// A correction replaces the event numbered amends; a nil replacement
+	// strikes it from the record.
+	amends      int
+	replacement *MatchEvent
+}
+
+func BallEvent(team, batter, bowler string, runs int) MatchEvent {
+	return MatchEvent{kind: BALL, team: team, player: batter, other: bowler, runs: runs}
+}
+
+// ExtraEvent is a ball that conceded extras. Only a no ball can also have
+// runs off the bat; for the other kinds runs must be zero.
+func ExtraEvent(team, batter, bowler string, extra ExtraType, extras, runs int) MatchEvent {
+	return MatchEvent{kind: BALL, team: team, player: batter, other: bowler, runs: runs, extra: extra, extras: extras}
+}
+
+func WicketEvent(team, batter, bowler string) MatchEvent {
+	return MatchEvent{kind: WICKET, team: team, player: batter, other: bowler}
+}
+
+func GoalEvent(team, player string, minute int) MatchEvent {
+	return MatchEvent{kind: GOAL, team: team, player: player, minute: minute}
+}
+
+func CardEvent(team, player string, card Card, minute int) MatchEvent {
+	return MatchEvent{kind: CARD, team: team, player: player, card: card, minute: minute}
+}
+
+func SubstitutionEvent(team, off, on string, minute int) MatchEvent {
+	return MatchEvent{kind: SUBSTITUTION, team: team, player: off, other: on, minute: minute}
+}
+
+func CorrectionEvent(seq int, replacement *MatchEvent) MatchEvent {
+	return MatchEvent{kind: CORRECTION, amends: seq, replacement: replacement}
+}
+
+func (e MatchEvent) Seq() int { return e.seq }
+
+func (e MatchEvent) String() string {
+	var s string
+	switch e.kind {
+	case BALL:
+		switch e.extra {
+		case WIDE:
+			s = fmt.Sprintf("%s to %s, %d wide", e.other, e.player, e.extras)
+		case NO_BALL:
+			s = fmt.Sprintf("%s to %s, no ball +%d, %d run(s)", e.other, e.player, e.extras, e.runs)
+		case BYE, LEG_BYE:
+			s = fmt.Sprintf("%s to %s, %d bye(s)", e.other, e.player, e.extras)
+		default:
+			s = fmt.Sprintf("%s to %s, %d run(s)", e.other, e.player, e.runs)
+		}
+	case WICKET:
+		s = fmt.Sprintf("%s to %s, OUT", e.other, e.player)
+	case GOAL:
+		s = fmt.Sprintf("%d' goal %s (%s)", e.minute, e.player, e.team)
+	case CARD:
+		colour := "yellow"
+		if e.card == RED {
+			colour = "red"
+		}
+		s = fmt.Sprintf("%d' %s card %s (%s)", e.minute, colour, e.player, e.team)
+	case SUBSTITUTION:
+		s = fmt.Sprintf("%d' %s on for %s (%s)", e.minute, e.other, e.player, e.team)
+	case CORRECTION:
+		if e.replacement == nil {
+			s = fmt.Sprintf("correction: #%d struck off", e.amends)
+		} else {
+			s = fmt.Sprintf("correction: #%d is %s", e.amends, *e.replacement)
+		}
+	}
+	if e.seq == 0 {
+		return s
+	}
+	return fmt.Sprintf("#%d %s", e.seq, s)
+}
+
+// EventLog is the append-only record of everything that happened in a
+// game. Entries are never rewritten; corrections are appended and applied
+// when the log is replayed.
+type EventLog struct {
+	events []MatchEvent
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{}
+}
+
+func (l *EventLog) Len() int {
+	return len(l.events)
+}
+
+// next numbers e as the next entry and checks that a correction refers to
+// an earlier match event.
+func (l *EventLog) next(e MatchEvent) (MatchEvent, error) {
+	if e.kind == CORRECTION {
+		if e.amends < 1 || e.amends > len(l.events) {
+			return MatchEvent{}, fmt.Errorf("correction of unknown event #%d", e.amends)
+		}
+		if l.events[e.amends-1].kind == CORRECTION {
+			return MatchEvent{}, errors.New("a correction cannot be corrected, amend the original event")
+		}
+		if e.replacement != nil && e.replacement.kind == CORRECTION {
+			return MatchEvent{}, errors.New("a correction cannot be replaced by another correction")
+		}
+	}
+	e.seq = len(l.events) + 1
+	return e, nil
+}
+
+func (l *EventLog) Append(e MatchEvent) (MatchEvent, error) {
+	e, err := l.next(e)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+	l.events = append(l.events, e)
+	return e, nil
+}
+
+// Events returns the raw log, corrections included.
+func (l *EventLog) Events() []MatchEvent {
+	return append([]MatchEvent(nil), l.events...)
+}
+
+// Latest asks Effective and ScoreAt for the whole log; 0 is the state
+// before the first entry.
+const Latest = -1
+
+// Effective replays the log as it stood after entry upto (the whole log if
+// upto is Latest) and returns the match events with the corrections
+// recorded by then applied. Later corrections of the same event win.
+func (l *EventLog) Effective(upto int) []MatchEvent {
+	if upto == Latest || upto > len(l.events) {
+		upto = len(l.events)
+	}
+	if upto < 0 {
+		upto = 0
+	}
+	return effective(l.events[:upto])
+}
+
+// Amended returns the match events as they would be with correction c
+// appended, without recording it.
+func (l *EventLog) Amended(c MatchEvent) ([]MatchEvent, error) {
+	c, err := l.next(c)
+	if err != nil {
+		return nil, err
+	}
+	return effective(append(l.Events(), c)), nil
+}
+
+func effective(log []MatchEvent) []MatchEvent {
+	amended := make(map[int]*MatchEvent)
+	for _, e := range log {
+		if e.kind == CORRECTION {
+			amended[e.amends] = e.replacement
+		}
+	}
+	events := make([]MatchEvent, 0, len(log))
+	for _, e := range log {
+		if e.kind == CORRECTION {
+			continue
+		}
+		if r, ok := amended[e.seq]; ok {
+			if r == nil {
+				continue
+			}
+			seq := e.seq
+			e = *r
+			e.seq = seq
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// fold applies events in order to a fresh score.
+func fold(score IScore, events []MatchEvent) error {
+	for _, e := range events {
+		if err := score.Apply(e); err != nil {
+			return fmt.Errorf("event #%d: %w", e.seq, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const maxSubstitutions = 5
+
+// FootballScore folds goals, cards and substitutions into the scoreline and
+// match stats.
+type FootballScore struct {
+	home    string
+	away    string
+	goals   map[string]int
+	subs    map[string]int
+	yellows map[string]int
+	off     map[string]bool // sent off or substituted, keyed by team/player
+	events  []MatchEvent
+}
+
+func NewFootballScore(home, away string) *FootballScore {
+	return &FootballScore{
+		home:    home,
+		away:    away,
+		goals:   make(map[string]int),
+		subs:    make(map[string]int),
+		yellows: make(map[string]int),
+		off:     make(map[string]bool),
+	}
+}
+
+func (f *FootballScore) Apply(e MatchEvent) error {
+	if e.team != f.home && e.team != f.away {
+		return fmt.Errorf("%s is not playing", e.team)
+	}
+	player := e.team + "/" + e.player
+	if f.off[player] {
+		return fmt.Errorf("%s is no longer on the pitch", e.player)
+	}
+	switch e.kind {
+	case GOAL:
+		f.goals[e.team]++
+	case CARD:
+		if e.card == YELLOW {
+			f.yellows[player]++
+		}
+		if e.card == RED || f.yellows[player] == 2 {
+			f.off[player] = true
+		}
+	case SUBSTITUTION:
+		if f.subs[e.team] == maxSubstitutions {
+			return fmt.Errorf("%s have no substitutions left", e.team)
+		}
+		f.subs[e.team]++
+		f.off[player] = true
+	default:
+		return errors.New("not a football event")
+	}
+	f.events = append(f.events, e)
+	return nil
+}
+
+func (f *FootballScore) GetScore() string {
+	return fmt.Sprintf("%s %d - %d %s", f.home, f.goals[f.home], f.goals[f.away], f.away)
+}
+
+func (f *FootballScore) GetStats() string {
+	var sb strings.Builder
+	for _, team := range []string{f.home, f.away} {
+		fmt.Fprintf(&sb, "%s goals %d, substitutions %d\n", team, f.goals[team], f.subs[team])
+		for _, e := range f.events {
+			if e.team == team {
+				e.seq = 0
+				fmt.Fprintf(&sb, "  %s\n", e)
+			}
+		}
+	}
+	return sb.String()
+}
@@ -22,10 +22,17 @@ const (
 )
 
 type Subscription struct {
-	id        string
-	plan      Plan
-	expiresAt time.Time
-	userId    string
+	id     string
+	plan   Plan
+	userId string
+	planId string
+	status SubscriptionStatus
+	// periodStart and expiresAt bound the current trial or paid period.
+	periodStart  time.Time
+	expiresAt    time.Time
+	pastDueSince time.Time
+	creditCents  int64
+	invoices     []Invoice
 }
 
 type GameType int32
@@ -35,48 +42,21 @@ const (
 	FOOTBALL
 )
 
+// IScore is a scoreboard derived by folding a game's events in order.
+// Apply rejects an event that is impossible in the current state without
+// changing it.
 type IScore interface {
-	UpdateScore(newScore string)
+	Apply(e MatchEvent) error
 	GetScore() string
-}
-
-type FootballScore struct {
-	score string
-}
-
-func (f *FootballScore) UpdateScore(newScore string) {
-	f.score = newScore
-}
-
-func (f *FootballScore) GetScore() string {
-	return f.score
-}
-
-func NewFootballScore() *FootballScore {
-	return &FootballScore{}
-}
-
-type CricketScore struct {
-	score string
-}
-
-func (f *CricketScore) UpdateScore(newScore string) {
-	f.score = newScore
-}
-
-func (f *CricketScore) GetScore() string {
-	return f.score
-}
-
-func NewCricketScore() *CricketScore {
-	return &CricketScore{}
+	GetStats() string
 }
 
 type Game struct {
 	id       string
 	gameType GameType
 	score    IScore
-	history  string
+	events   *EventLog
+	newScore func() IScore
 	status   string
 }
 
@@ -88,13 +68,15 @@ type IUserSvc interface {
 type ISubscription interface {
 	Add(subscription *Subscription) error
 	GetById(id string) (*Subscription, error)
+	All() []*Subscription
 }
 
 type IGame interface {
 	Add(game *Game) error
 	GetById(id string) (*Game, error)
-	UpdateScore(id, score string) error
-	GetHistory(id string) (string, error)
+	Record(id string, e MatchEvent) (MatchEvent, error)
+	GetHistory(id string) ([]MatchEvent, error)
+	ScoreAt(id string, seq int) (IScore, error)
 }
 
 type UserSvc struct {
@@ -143,6 +125,14 @@ func (s *SubscriptionSvc) GetById(id string) (*Subscription, error) {
 	return sub, nil
 }
 
+func (s *SubscriptionSvc) All() []*Subscription {
+	subs := make([]*Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
 type GameSvc struct {
 	games map[string]*Game
 }
@@ -165,38 +155,84 @@ func (g *GameSvc) GetById(id string) (*Game, error) {
 	return game, nil
 }
 
-func (g *GameSvc) UpdateScore(id, score string) error {
+// Record appends an event to the game's log. Match events are folded into
+// the live score; a correction rebuilds the score from the whole log and is
+// rejected if the amended history does not replay cleanly.
+func (g *GameSvc) Record(id string, e MatchEvent) (MatchEvent, error) {
 	game, exists := g.games[id]
 	if !exists {
-		return fmt.Errorf("game not found")
+		return MatchEvent{}, fmt.Errorf("game not found")
 	}
-	game.score.UpdateScore(score)
-	g.games[id] = game
-	return nil
+	if e.kind != CORRECTION {
+		if err := game.score.Apply(e); err != nil {
+			return MatchEvent{}, err
+		}
+		return game.events.Append(e)
+	}
+
+	events, err := game.events.Amended(e)
+	if err != nil {
+		return MatchEvent{}, err
+	}
+	score := game.newScore()
+	if err := fold(score, events); err != nil {
+		return MatchEvent{}, err
+	}
+	game.score = score
+	return game.events.Append(e)
+}
+
+func (g *GameSvc) GetHistory(id string) ([]MatchEvent, error) {
+	game, exists := g.games[id]
+	if !exists {
+		return nil, fmt.Errorf("game not found")
+	}
+	return game.events.Events(), nil
 }
 
-func (g *GameSvc) GetHistory(id string) (string, error) {
+// ScoreAt replays the game as it stood after log entry seq: 0 is before
+// anything happened and Latest is now.
+func (g *GameSvc) ScoreAt(id string, seq int) (IScore, error) {
 	game, exists := g.games[id]
 	if !exists {
-		return "", fmt.Errorf("game not found")
+		return nil, fmt.Errorf("game not found")
 	}
-	return game.history, nil
+	if seq != Latest && (seq < 0 || seq > game.events.Len()) {
+		return nil, fmt.Errorf("no event #%d", seq)
+	}
+	score := game.newScore()
+	if err := fold(score, game.events.Effective(seq)); err != nil {
+		return nil, err
+	}
+	return score, nil
 }
 
 type Service struct {
-	userSvc IUserSvc
-	gameSvc IGame
-	subSvc  ISubscription
+	userSvc    IUserSvc
+	gameSvc    IGame
+	subSvc     ISubscription
+	planSvc    IPlan
+	payment    IPayment
+	now        func() time.Time
+	trialsUsed map[string]bool
 }
 
-func NewService() *Service {
+func NewService(payment IPayment) *Service {
 	return &Service{
-		userSvc: NewUserSvc(),
-		gameSvc: NewGameSvc(),
-		subSvc:  NewSubscriptionSvc(),
+		userSvc:    NewUserSvc(),
+		gameSvc:    NewGameSvc(),
+		subSvc:     NewSubscriptionSvc(),
+		planSvc:    NewPlanSvc(),
+		payment:    payment,
+		now:        time.Now,
+		trialsUsed: make(map[string]bool),
 	}
 }
 
+func (s *Service) AddPlan(plan *PlanDefinition) error {
+	return s.planSvc.Add(plan)
+}
+
 func (s *Service) RegisterUser(user *User) error {
 	return s.userSvc.Register(user)
 }
@@ -217,38 +253,170 @@ func (s *Service) AddGame(game *Game) error {
 	return s.gameSvc.Add(game)
 }
 
-func (s *Service) GetGameByID(gameID string) (*Game, error) {
-	return s.gameSvc.GetById(gameID)
+// GetGameByID returns the game if the user's subscription covers its type.
+func (s *Service) GetGameByID(userID, gameID string) (*Game, error) {
+	game, err := s.gameSvc.GetById(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.CanAccess(userID, game.gameType); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *Service) GetGameScore(userID, gameID string) (string, error) {
+	game, err := s.GetGameByID(userID, gameID)
+	if err != nil {
+		return "", err
+	}
+	return game.score.GetScore(), nil
 }
 
-func (s *Service) UpdateGameScore(gameID string, score string) error {
-	return s.gameSvc.UpdateScore(gameID, score)
+func (s *Service) RecordGameEvent(gameID string, e MatchEvent) (MatchEvent, error) {
+	return s.gameSvc.Record(gameID, e)
 }
 
-func (s *Service) GetGameHistory(gameID string) (string, error) {
+func (s *Service) GetGameStats(userID, gameID string) (string, error) {
+	game, err := s.GetGameByID(userID, gameID)
+	if err != nil {
+		return "", err
+	}
+	return game.score.GetStats(), nil
+}
+
+func (s *Service) GetGameScoreAt(userID, gameID string, seq int) (string, error) {
+	if _, err := s.GetGameByID(userID, gameID); err != nil {
+		return "", err
+	}
+	score, err := s.gameSvc.ScoreAt(gameID, seq)
+	if err != nil {
+		return "", err
+	}
+	return score.GetScore(), nil
+}
+
+func (s *Service) GetGameHistory(userID, gameID string) ([]MatchEvent, error) {
+	if _, err := s.GetGameByID(userID, gameID); err != nil {
+		return nil, err
+	}
 	return s.gameSvc.GetHistory(gameID)
 }
 
 type GameFactory struct {
 }
 
-func (gf *GameFactory) CreateGame(id string, gameType GameType) *Game {
-	var score IScore
+func (gf *GameFactory) CreateGame(id string, gameType GameType, home, away string) *Game {
+	var newScore func() IScore
 	switch gameType {
 	case CRICKET:
-		score = &CricketScore{}
+		newScore = func() IScore { return NewCricketScore(home, away) }
 	case FOOTBALL:
-		score = &FootballScore{}
+		newScore = func() IScore { return NewFootballScore(home, away) }
 	}
 	return &Game{
 		id:       id,
 		gameType: gameType,
-		score:    score,
-		history:  "none",
+		score:    newScore(),
+		events:   NewEventLog(),
+		newScore: newScore,
 		status:   "Pending",
 	}
 }
 
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
 func main() {
+	const day = 24 * time.Hour
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	payment := NewFakePayment()
+	s := NewService(payment)
+	s.now = clock.Now
+
+	s.AddPlan(NewPlanDefinition("cricket", "Cricket Monthly", ONE_MONTH, 1000, 7, CRICKET))
+	s.AddPlan(NewPlanDefinition("all", "All Sports Monthly", ONE_MONTH, 1500, 0, CRICKET, FOOTBALL))
+	s.AddPlan(NewPlanDefinition("football-year", "Football Yearly", TWELVE_MONTHS, 9000, 0, FOOTBALL))
+	s.RegisterUser(&User{id: "alice", name: "Alice"})
+	s.RegisterUser(&User{id: "bob", name: "Bob"})
+
+	factory := &GameFactory{}
+	s.AddGame(factory.CreateGame("ind-aus", CRICKET, "IND", "AUS"))
+	s.AddGame(factory.CreateGame("ars-che", FOOTBALL, "ARS", "CHE"))
+	s.RecordGameEvent("ind-aus", BallEvent("IND", "Rohit", "Starc", 4))
+	s.RecordGameEvent("ind-aus", WicketEvent("IND", "Rohit", "Starc"))
+	s.RecordGameEvent("ars-che", GoalEvent("ARS", "Saka", 12))
+
+	status := func(userID string) {
+		sub, _ := s.GetSubscriptionByUserID(userID)
+		fmt.Printf("%s: %s until %s, credit %d\n", userID, sub.status, sub.expiresAt.Format("2006-01-02"), sub.creditCents)
+	}
+	access := func(userID, gameID string) {
+		score, err := s.GetGameScore(userID, gameID)
+		if err != nil {
+			fmt.Printf("%s -> %s: %v\n", userID, gameID, err)
+			return
+		}
+		fmt.Printf("%s -> %s: %s\n", userID, gameID, score)
+	}
 
+	// Trial, then the first charge when it ends.
+	s.Subscribe("alice", "cricket")
+	status("alice")            // expected: trial until 2026-01-08
+	access("alice", "ind-aus") // expected: IND 4/1 (0.2) | AUS 0/0 (0.0)
+	access("alice", "ars-che") // expected: plan does not include this game type
+	clock.Advance(8 * day)
+	s.RunBilling()
+	status("alice") // expected: active until 2026-02-08
+
+	// Upgrade half way through the period: the unused half is credited.
+	clock.Advance(14*day + 12*time.Hour)
+	invoice, _ := s.ChangePlan("alice", "all")
+	fmt.Println("upgrade:", invoice) // expected: $10.00 (15.00 less 5.00 credit)
+	access("alice", "ars-che")       // expected: ARS 1 - 0 CHE
+
+	// Downgrade straight away: the credit exceeds the new price.
+	invoice, _ = s.ChangePlan("alice", "cricket")
+	fmt.Println("downgrade:", invoice) // expected: $0.00
+	status("alice")                    // expected: credit 500
+
+	// Cancel keeps access until the period ends.
+	s.Cancel("alice")
+	access("alice", "ind-aus") // expected: IND 4/1 (0.2) | AUS 0/0 (0.0)
+	clock.Advance(32 * day)
+	s.RunBilling()
+	status("alice")            // expected: expired
+	access("alice", "ind-aus") // expected: subscription is not active
+
+	// Coming back spends the credit left from the downgrade.
+	s.Subscribe("alice", "cricket")
+	status("alice") // expected: active, credit 0
+	sub, _ := s.GetSubscriptionByUserID("alice")
+	fmt.Println("rejoin:", sub.invoices[len(sub.invoices)-1]) // expected: $5.00 (10.00 less 5.00 credit)
+
+	// No trial on the yearly plan; the renewal fails and the grace period runs out.
+	s.Subscribe("bob", "football-year")
+	payment.Decline("bob", true)
+	clock.Advance(366 * day)
+	s.RunBilling()
+	status("bob")            // expected: past-due
+	access("bob", "ars-che") // expected: still allowed during grace
+	clock.Advance(8 * day)
+	s.RunBilling()
+	status("bob")            // expected: expired
+	access("bob", "ars-che") // expected: subscription is not active
+
+	// A declined card cannot start a paid plan.
+	_, err := s.Subscribe("bob", "all")
+	fmt.Println("resubscribe:", err) // expected: card declined
+	payment.Decline("bob", false)
+	s.Subscribe("bob", "all")
+	status("bob") // expected: active
+	fmt.Println("charged:", payment.charged)
+
+	scoringDemo(s, "bob")
 }
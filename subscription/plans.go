@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Months is the length of one billing period on the plan term.
+func (p Plan) Months() int {
+	switch p {
+	case THREE_MONTH:
+		return 3
+	case SIX_MONTHS:
+		return 6
+	case TWELVE_MONTHS:
+		return 12
+	}
+	return 1
+}
+
+func (p Plan) periodEnd(start time.Time) time.Time {
+	return start.AddDate(0, p.Months(), 0)
+}
+
+// PlanDefinition is a plan users can subscribe to: what it costs per
+// billing period and which game types it unlocks.
+type PlanDefinition struct {
+	id           string
+	name         string
+	term         Plan
+	priceCents   int64
+	trialDays    int
+	entitlements map[GameType]bool
+}
+
+func NewPlanDefinition(id, name string, term Plan, priceCents int64, trialDays int, gameTypes ...GameType) *PlanDefinition {
+	entitlements := make(map[GameType]bool, len(gameTypes))
+	for _, t := range gameTypes {
+		entitlements[t] = true
+	}
+	return &PlanDefinition{
+		id:           id,
+		name:         name,
+		term:         term,
+		priceCents:   priceCents,
+		trialDays:    trialDays,
+		entitlements: entitlements,
+	}
+}
+
+func (p *PlanDefinition) Entitles(gameType GameType) bool {
+	return p.entitlements[gameType]
+}
+
+type IPlan interface {
+	Add(plan *PlanDefinition) error
+	GetById(id string) (*PlanDefinition, error)
+}
+
+type PlanSvc struct {
+	plans map[string]*PlanDefinition
+}
+
+func NewPlanSvc() *PlanSvc {
+	return &PlanSvc{plans: make(map[string]*PlanDefinition)}
+}
+
+func (p *PlanSvc) Add(plan *PlanDefinition) error {
+	if plan.priceCents < 0 {
+		return errors.New("plan price cannot be negative")
+	}
+	p.plans[plan.id] = plan
+	return nil
+}
+
+func (p *PlanSvc) GetById(id string) (*PlanDefinition, error) {
+	plan, ok := p.plans[id]
+	if !ok {
+		return nil, errors.New("plan not found")
+	}
+	return plan, nil
+}
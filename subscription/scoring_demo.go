@@ -0,0 +1,87 @@
+package main
+
+import "fmt"
+
+func scoringDemo(s *Service, userID string) {
+	factory := &GameFactory{}
+	s.AddGame(factory.CreateGame("eng-nz", CRICKET, "ENG", "NZ"))
+	s.AddGame(factory.CreateGame("liv-mci", FOOTBALL, "LIV", "MCI"))
+
+	record := func(gameID string, e MatchEvent) MatchEvent {
+		e, err := s.RecordGameEvent(gameID, e)
+		if err != nil {
+			fmt.Printf("%s rejected: %v\n", gameID, err)
+		}
+		return e
+	}
+	score := func(gameID string) {
+		score, _ := s.GetGameScore(userID, gameID)
+		fmt.Println(score)
+	}
+
+	// Ball by ball: extras count for the team but only legal balls advance
+	// the over.
+	record("eng-nz", BallEvent("ENG", "Root", "Boult", 1))
+	record("eng-nz", BallEvent("ENG", "Brook", "Boult", 4))
+	record("eng-nz", ExtraEvent("ENG", "Brook", "Boult", WIDE, 1, 0))
+	record("eng-nz", BallEvent("ENG", "Brook", "Boult", 6))
+	wicket := record("eng-nz", WicketEvent("ENG", "Brook", "Boult"))
+	legByes := record("eng-nz", ExtraEvent("ENG", "Stokes", "Boult", LEG_BYE, 2, 0))
+	record("eng-nz", BallEvent("ENG", "Stokes", "Boult", 0))
+	score("eng-nz") // expected: ENG 14/1 (1.0) | NZ 0/0 (0.0)
+	record("eng-nz", BallEvent("ENG", "Brook", "Southee", 4))
+	// expected: eng-nz rejected: Brook is already out
+
+	// Overturning the wicket now would leave Root, Brook and Stokes all in.
+	dot := BallEvent("ENG", "Brook", "Boult", 0)
+	record("eng-nz", CorrectionEvent(wicket.Seq(), &dot))
+	// expected: eng-nz rejected: event #6: Stokes cannot bat while Root and Brook are in
+
+	// The scorer fixes the leg byes: Stokes hit them.
+	two := BallEvent("ENG", "Stokes", "Boult", 2)
+	record("eng-nz", CorrectionEvent(legByes.Seq(), &two))
+	score("eng-nz") // expected: ENG 14/1 (1.0) | NZ 0/0 (0.0)
+	record("eng-nz", BallEvent("ENG", "Stokes", "Southee", 4))
+	score("eng-nz") // expected: ENG 18/1 (1.1) | NZ 0/0 (0.0)
+
+	// Point in time: before the first ball, after the wicket and now.
+	start, _ := s.GetGameScoreAt(userID, "eng-nz", 0)
+	before, _ := s.GetGameScoreAt(userID, "eng-nz", wicket.Seq())
+	after, _ := s.GetGameScoreAt(userID, "eng-nz", Latest)
+	fmt.Println("at the start:", start)      // expected: ENG 0/0 (0.0) | NZ 0/0 (0.0)
+	fmt.Println("after the wicket:", before) // expected: ENG 12/1 (0.4) | NZ 0/0 (0.0)
+	fmt.Println("now:", after)               // expected: ENG 18/1 (1.1) | NZ 0/0 (0.0)
+	stats, _ := s.GetGameStats(userID, "eng-nz")
+	fmt.Print(stats)
+
+	// NZ's first ball closes England's innings.
+	record("eng-nz", ExtraEvent("NZ", "Conway", "Wood", NO_BALL, 1, 4))
+	record("eng-nz", BallEvent("ENG", "Root", "Southee", 1))
+	// expected: eng-nz rejected: ENG innings is over
+	score("eng-nz") // expected: ENG 18/1 (1.1) | NZ 5/0 (0.0)
+
+	goal := record("liv-mci", GoalEvent("LIV", "Salah", 23))
+	booking := record("liv-mci", CardEvent("MCI", "Rodri", YELLOW, 40))
+	record("liv-mci", GoalEvent("MCI", "Haaland", 55))
+	record("liv-mci", SubstitutionEvent("LIV", "Salah", "Gakpo", 70))
+	record("liv-mci", CardEvent("MCI", "Rodri", YELLOW, 78))
+	record("liv-mci", GoalEvent("MCI", "Rodri", 85))
+	// expected: liv-mci rejected: Rodri is no longer on the pitch
+	score("liv-mci") // expected: LIV 1 - 1 MCI
+
+	// VAR rules the goal out; striking it off leaves the rest intact.
+	record("liv-mci", CorrectionEvent(goal.Seq(), nil))
+	score("liv-mci") // expected: LIV 0 - 1 MCI
+	// Corrections that would make the log inconsistent are refused.
+	// A straight red at 40' would mean Rodri could not be booked at 78'.
+	red := CardEvent("MCI", "Rodri", RED, 40)
+	record("liv-mci", CorrectionEvent(booking.Seq(), &red))
+	// expected: liv-mci rejected: event #5: Rodri is no longer on the pitch
+	stats, _ = s.GetGameStats(userID, "liv-mci")
+	fmt.Print(stats)
+
+	history, _ := s.GetGameHistory(userID, "liv-mci")
+	for _, e := range history {
+		fmt.Println(e)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStateStore is a StateStore backed by an append-only journal: every
+// save appends the workflow's full snapshot as one JSON line and is synced
+// before SaveWorkflow returns. Opening the journal replays it, keeping the
+// last snapshot of each workflow, drops a torn final line left by a crash
+// or any line that does not decode, and compacts the file down to one line
+// per workflow.
+type FileStateStore struct {
+	path string
+	file *os.File
+	// size is where the journal ends after the last complete snapshot.
+	size int64
+	// failed is set once the journal may hold bytes that could not be
+	// cleaned up; every later save returns it.
+	failed    error
+	workflows map[string]*Workflow
+	mu        sync.Mutex
+}
+
+func OpenFileStateStore(path string) (*FileStateStore, error) {
+	s := &FileStateStore{
+		path:      path,
+		workflows: make(map[string]*Workflow),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStateStore) replay() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything after the last newline was never fully written.
+			if len(line) > 0 {
+				log.Printf("%s: dropping torn line %d", s.path, n)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// A bad line loses one snapshot; an earlier or later one of the
+		// same workflow still stands, and compaction drops the line.
+		wf := &Workflow{}
+		if err := json.Unmarshal(line, wf); err != nil {
+			log.Printf("%s: skipping line %d: %v", s.path, n, err)
+			continue
+		}
+		if wf.ID == "" {
+			log.Printf("%s: skipping line %d: no workflow id", s.path, n)
+			continue
+		}
+		s.workflows[wf.ID] = wf
+	}
+}
+
+// compact rewrites the journal with the current snapshots and reopens it
+// for appending. The new file replaces the old one by rename, so a crash
+// leaves one or the other.
+func (s *FileStateStore) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, wf := range s.workflows {
+		n, err := writeSnapshot(f, wf)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		size += int64(n)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(s.path))
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	dir.Close()
+	if err != nil {
+		return err
+	}
+	s.size = size
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	return err
+}
+
+func writeSnapshot(w io.Writer, wf *Workflow) (int, error) {
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(append(data, '\n'))
+}
+
+func (s *FileStateStore) SaveWorkflow(wf *Workflow) error {
+	clone, err := cloneWorkflow(wf)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("state store is closed")
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	n, err := writeSnapshot(s.file, clone)
+	if err != nil {
+		// Cut off whatever part of the line made it, so the next snapshot
+		// does not land on the end of it.
+		if terr := s.file.Truncate(s.size); terr != nil {
+			s.failed = fmt.Errorf("state store failed: %v; truncating the journal: %v", err, terr)
+		}
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		// Whether the snapshot reached the disk is unknown now.
+		s.failed = fmt.Errorf("state store failed: %w", err)
+		return err
+	}
+	s.size += int64(n)
+	s.workflows[wf.ID] = clone
+	return nil
+}
+
+func (s *FileStateStore) GetWorkflow(id string) (*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wf, ok := s.workflows[id]
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+	return cloneWorkflow(wf)
+}
+
+func (s *FileStateStore) ListWorkflows(status WorkflowStatus) ([]*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedWorkflows(s.workflows, status)
+}
+
+func (s *FileStateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
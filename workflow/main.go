@@ -2,8 +2,13 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
-	"sync"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -45,49 +50,65 @@ type Step struct {
 	Parameters map[string]interface{}
 	RetryCount int
 	MaxRetries int
+	// Output is recorded together with the COMPLETED status, so a resumed
+	// workflow never runs a finished step again.
+	Output map[string]interface{}
 }
 
-type StateStore struct {
-	workflows map[string]*Workflow
-	mu        sync.Mutex
-}
-
-func NewStateStore() *StateStore {
-	return &StateStore{
-		workflows: make(map[string]*Workflow),
+// intParam reads an integer parameter. Parameters restored from JSON hold
+// numbers as float64.
+func (s *Step) intParam(name string) (int, bool) {
+	switch v := s.Parameters[name].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
 	}
-}
-
-func (s *StateStore) SaveWorkflow(wf *Workflow) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.workflows[wf.ID] = wf
+	return 0, false
 }
 
 type TaskExecutor struct {
+	// crash, if set, is called before each step runs; the demo uses it to
+	// kill the process part way through a workflow.
+	crash func(key string)
+	// fail, if set, can fail a step before it runs; the demo uses it for a
+	// service that is down.
+	fail func(key string) error
 }
 
-func (ts *TaskExecutor) Execute(task *Step) error {
+// Execute runs one step. key identifies the step within its workflow and
+// stays the same when a step is retried or resumed after a crash, so
+// remote calls can use it to deduplicate.
+func (ts *TaskExecutor) Execute(key string, task *Step) (map[string]interface{}, error) {
+	if ts.crash != nil {
+		ts.crash(key)
+	}
+	if ts.fail != nil {
+		if err := ts.fail(key); err != nil {
+			return nil, err
+		}
+	}
 	switch task.TaskType {
 	case TaskTypeHttp:
-		fmt.Println("making api call")
+		fmt.Println("making api call", key)
 		time.Sleep(1 * time.Second)
+		return map[string]interface{}{"status": 200, "idempotency_key": key}, nil
 	case TaskTypeDelay:
-		if delay, ok := task.Parameters["duration"].(int); ok {
+		if delay, ok := task.intParam("duration"); ok {
 			time.Sleep(time.Second * time.Duration(delay))
 		}
+		return map[string]interface{}{}, nil
 	default:
-		return errors.New("fat gaya")
+		return nil, errors.New("fat gaya")
 	}
-	return nil
 }
 
 type WorkflowManager struct {
-	stateStore   *StateStore
+	stateStore   StateStore
 	taskExecutor *TaskExecutor
 }
 
-func NewWorkflowManager(stateStore *StateStore, taskExecutor *TaskExecutor) *WorkflowManager {
+func NewWorkflowManager(stateStore StateStore, taskExecutor *TaskExecutor) *WorkflowManager {
 	return &WorkflowManager{
 		stateStore:   stateStore,
 		taskExecutor: taskExecutor,
@@ -96,61 +117,180 @@ func NewWorkflowManager(stateStore *StateStore, taskExecutor *TaskExecutor) *Wor
 
 func (w *WorkflowManager) StartWorkflow(wf *Workflow) error {
 	wf.WorkflowStatus = WorkflowStatusRunning
-	w.stateStore.SaveWorkflow(wf)
+	return w.run(wf)
+}
+
+// run executes wf from CurrentStep, saving it before and after every step
+// so that it can be resumed from the store at any point.
+func (w *WorkflowManager) run(wf *Workflow) error {
+	wf.UpdatedAt = time.Now()
+	if err := w.stateStore.SaveWorkflow(wf); err != nil {
+		return err
+	}
 
 	for wf.CurrentStep < len(wf.Steps) {
 		step := wf.Steps[wf.CurrentStep]
+		if step.StepStatus == StepStatusCompleted {
+			wf.CurrentStep++
+			continue
+		}
 		step.StepStatus = StepStatusRunning
-		err := w.taskExecutor.Execute(step)
+		wf.UpdatedAt = time.Now()
+		if err := w.stateStore.SaveWorkflow(wf); err != nil {
+			return err
+		}
+
+		output, err := w.taskExecutor.Execute(wf.ID+"/"+step.ID, step)
 		if err != nil {
+			// The attempt is saved before the retry so a restart does not
+			// hand the step a fresh set of retries.
+			if step.RetryCount < step.MaxRetries {
+				step.RetryCount++
+				wf.UpdatedAt = time.Now()
+				if err := w.stateStore.SaveWorkflow(wf); err != nil {
+					return err
+				}
+				continue
+			}
 			step.StepStatus = StepStatusFailed
 			wf.WorkflowStatus = WorkflowStatusFailed
-			w.stateStore.SaveWorkflow(wf)
+			wf.UpdatedAt = time.Now()
+			if saveErr := w.stateStore.SaveWorkflow(wf); saveErr != nil {
+				return saveErr
+			}
 			return err
 		}
+		step.Output = output
 		step.StepStatus = StepStatusCompleted
 		wf.CurrentStep++
-		w.stateStore.SaveWorkflow(wf)
+		wf.UpdatedAt = time.Now()
+		if err := w.stateStore.SaveWorkflow(wf); err != nil {
+			return err
+		}
 	}
 	wf.WorkflowStatus = WorkflowStatusCompleted
-	w.stateStore.SaveWorkflow(wf)
-	return nil
+	wf.UpdatedAt = time.Now()
+	return w.stateStore.SaveWorkflow(wf)
 }
 
-func main() {
-	store := NewStateStore()
-	executor := &TaskExecutor{}
-
-	manager := NewWorkflowManager(store, executor)
+// Recover resumes every workflow the store still has as RUNNING, which
+// after a restart means the previous process died while running it. Each
+// continues from CurrentStep; a step caught mid-run is executed again with
+// the same key. A workflow that fails is logged and left FAILED in the
+// returned list, and the rest still run; only a store error stops
+// recovery.
+func (w *WorkflowManager) Recover() ([]*Workflow, error) {
+	running, err := w.stateStore.ListWorkflows(WorkflowStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	for _, wf := range running {
+		err := w.run(wf)
+		if err == nil {
+			continue
+		}
+		if wf.WorkflowStatus != WorkflowStatusFailed {
+			return running, fmt.Errorf("resume %s: %w", wf.ID, err)
+		}
+		log.Printf("resume %s: %v", wf.ID, err)
+	}
+	return running, nil
+}
 
-	wf := &Workflow{
-		ID:             "id",
-		Name:           "name",
+func newOrder(id string) *Workflow {
+	return &Workflow{
+		ID:             id,
+		Name:           "order",
 		WorkflowStatus: WorkflowStatusPending,
 		Steps: []*Step{
-			{
-				ID:         "1",
-				Name:       "n",
-				TaskType:   TaskTypeHttp,
-				StepStatus: StepStatusPending,
-				Parameters: map[string]interface{}{},
-				RetryCount: 0,
-				MaxRetries: 3,
-			},
-			{
-				ID:         "2",
-				Name:       "nn",
-				TaskType:   TaskTypeDelay,
-				StepStatus: StepStatusPending,
-				Parameters: map[string]interface{}{
-					"duration": 2,
-				},
-				RetryCount: 0,
-				MaxRetries: 3,
-			},
+			{ID: "charge", Name: "charge card", TaskType: TaskTypeHttp, StepStatus: StepStatusPending, Parameters: map[string]interface{}{}, MaxRetries: 3},
+			{ID: "wait", Name: "wait for warehouse", TaskType: TaskTypeDelay, StepStatus: StepStatusPending, Parameters: map[string]interface{}{"duration": 1}, MaxRetries: 3},
+			{ID: "ship", Name: "book courier", TaskType: TaskTypeHttp, StepStatus: StepStatusPending, Parameters: map[string]interface{}{}, MaxRetries: 3},
 		},
-		CurrentStep: 0,
+		CreatedAt: time.Now(),
 	}
-	store.SaveWorkflow(wf)
-	manager.StartWorkflow(wf)
-}
\ No newline at end of file
+}
+
+// runUntilCrash is a process that starts the given orders in turn and dies
+// at crashAt, leaving that workflow RUNNING in the journal.
+func runUntilCrash(journal, crashAt string, orders []string) {
+	store, err := OpenFileStateStore(journal)
+	if err != nil {
+		log.Fatal(err)
+	}
+	executor := &TaskExecutor{crash: func(key string) {
+		if key == crashAt {
+			fmt.Println("process killed during", key)
+			os.Exit(3)
+		}
+	}}
+	manager := NewWorkflowManager(store, executor)
+	for _, id := range orders {
+		manager.StartWorkflow(newOrder(id))
+	}
+}
+
+func main() {
+	journal := flag.String("journal", "", "path of the workflow journal")
+	crashAt := flag.String("crash-at", "", "exit while running this workflow/step")
+	orders := flag.String("orders", "", "comma-separated orders to start before crashing")
+	flag.Parse()
+	if *crashAt != "" {
+		runUntilCrash(*journal, *crashAt, strings.Split(*orders, ","))
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "workflow")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "workflows.journal")
+
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Two processes die part way through an order each, after the first
+	// has finished order-1.
+	for _, run := range [][]string{{"order-1,order-2", "order-2/ship"}, {"order-3", "order-3/ship"}} {
+		cmd := exec.Command(self, "-journal", path, "-orders", run[0], "-crash-at", run[1])
+		cmd.Stdout = os.Stdout
+		err = cmd.Run()
+		fmt.Println("process exited:", err) // expected: exit status 3
+	}
+
+	// Restart: reopen the journal and resume what was running. The courier
+	// is down for order-2, which fails; order-3 still completes.
+	store, err := OpenFileStateStore(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	executor := &TaskExecutor{fail: func(key string) error {
+		if key == "order-2/ship" {
+			return errors.New("courier unavailable")
+		}
+		return nil
+	}}
+	manager := NewWorkflowManager(store, executor)
+	resumed, err := manager.Recover()
+	if err != nil {
+		fmt.Println("recovery stopped:", err)
+	}
+	for _, wf := range resumed {
+		fmt.Println("resumed", wf.ID, wf.WorkflowStatus) // expected: order-2 FAILED, order-3 COMPLETED; charge and wait are not run again
+	}
+
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		wf, err := store.GetWorkflow(id)
+		if err != nil {
+			fmt.Println(id, err)
+			continue
+		}
+		fmt.Printf("%s %s\n", wf.ID, wf.WorkflowStatus)
+		for _, step := range wf.Steps {
+			fmt.Printf("  %s %s retries %d %v\n", step.ID, step.StepStatus, step.RetryCount, step.Output)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// StateStore keeps the latest snapshot of every workflow. SaveWorkflow
+// copies the workflow, so callers can keep mutating theirs.
+type StateStore interface {
+	SaveWorkflow(wf *Workflow) error
+	GetWorkflow(id string) (*Workflow, error)
+	ListWorkflows(status WorkflowStatus) ([]*Workflow, error)
+	Close() error
+}
+
+func cloneWorkflow(wf *Workflow) (*Workflow, error) {
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return nil, err
+	}
+	clone := &Workflow{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+func sortedWorkflows(workflows map[string]*Workflow, status WorkflowStatus) ([]*Workflow, error) {
+	list := []*Workflow{}
+	for _, wf := range workflows {
+		if wf.WorkflowStatus != status {
+			continue
+		}
+		clone, err := cloneWorkflow(wf)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, clone)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// MemoryStateStore is a StateStore that lives only as long as the process.
+type MemoryStateStore struct {
+	workflows map[string]*Workflow
+	mu        sync.Mutex
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		workflows: make(map[string]*Workflow),
+	}
+}
+
+func (s *MemoryStateStore) SaveWorkflow(wf *Workflow) error {
+	clone, err := cloneWorkflow(wf)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflows[wf.ID] = clone
+	return nil
+}
+
+func (s *MemoryStateStore) GetWorkflow(id string) (*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wf, ok := s.workflows[id]
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+	return cloneWorkflow(wf)
+}
+
+func (s *MemoryStateStore) ListWorkflows(status WorkflowStatus) ([]*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedWorkflows(s.workflows, status)
+}
+
+func (s *MemoryStateStore) Close() error {
+	return nil
+}